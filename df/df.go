@@ -6,6 +6,9 @@ package df
 import (
 	"fmt"
 	"github.com/LDCS/genutil"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 )
@@ -13,17 +16,20 @@ import (
 // Dfdata holds df data
 type Dfdata struct {
 	Name_       string // e.g. /dev/md1p1
-	DevName_    string // e.g. /dev/md1 (inferred)
+	DevName_    string // e.g. /dev/md1 (inferred from sysfs)
 	Type_       string // e.g. local, network, etc
 	Mountpoint_ string // e.g. /
 	Sizegb_     string // e.g. 25G
 	Usedgb_     string // e.g. 10G
 	Availgb_    string // e.g. 15G
 	Usepct_     string // e.g. 43%
+	Majmin_     string // e.g. 9:1 (from mountinfo)
+	Root_       string // e.g. / or /@home for a btrfs subvolume (from mountinfo)
+	Class_      string // e.g. primary, bind, subvolume, overlay, pseudo
 }
 
 const (
-	names     = "name,type,mountpoint,sizegb,usedgb,availgb,usepct"
+	names     = "name,type,mountpoint,sizegb,usedgb,availgb,usepct,majmin,root,class"
 	hdrprefix = ",df."
 	semi      = ";"
 )
@@ -74,7 +80,7 @@ func (self *Dfdata) Csv() string {
 	if self == nil {
 		return commaString
 	}
-	return fmt.Sprintf(pctString, self.Name_, self.Type_, self.Mountpoint_, self.Sizegb_, self.Usedgb_, self.Availgb_, self.Usepct_)
+	return fmt.Sprintf(pctString, self.Name_, self.Type_, strings.Replace(self.Mountpoint_, ",", semi, -1), self.Sizegb_, self.Usedgb_, self.Availgb_, self.Usepct_, self.Majmin_, strings.Replace(self.Root_, ",", semi, -1), self.Class_)
 }
 
 // Sprint is generic
@@ -82,7 +88,7 @@ func (self *Dfdata) Sprint() string {
 	if self == nil {
 		return ""
 	}
-	return fmt.Sprintf(namePctString, self.Name_, self.Type_, self.Mountpoint_, self.Sizegb_, self.Usedgb_, self.Availgb_, self.Usepct_)
+	return fmt.Sprintf(namePctString, self.Name_, self.Type_, self.Mountpoint_, self.Sizegb_, self.Usedgb_, self.Availgb_, self.Usepct_, self.Majmin_, self.Root_, self.Class_)
 }
 
 // Print is generic
@@ -96,33 +102,95 @@ func (self *Dfdata) Print() {
 // New is generic
 func New() *Dfdata { return new(Dfdata) }
 
-// inferDevName is generic
-func inferDevName(_name string) string {
-	switch {
-	case strings.Contains(_name, "/md"):
-		parts := strings.Split(_name, "/md")
-		if len(parts) != 2 {
-			return _name
-		}
-		pre, post := parts[0], parts[1]
-		partitionBeg := strings.Index(post, "p")
-		if partitionBeg < 1 {
-			return _name
-		}
-		return pre + "/md" + post[:partitionBeg]
-	case strings.Contains(_name, "/sd"):
-		parts := strings.Split(_name, "/sd")
-		if len(parts) != 2 {
-			return _name
+// sysClassBlock is where the kernel lists every block device, partitions included
+var sysClassBlock = "/sys/class/block"
+
+// sysBlockName maps a device path such as /dev/mapper/vg-root or /dev/disk/by-uuid/... to its kernel name (e.g. dm-3), or "" if it is not a block device
+func sysBlockName(_name string) string {
+	if !strings.HasPrefix(_name, "/dev/") {
+		return ""
+	}
+	devpath, err := filepath.EvalSymlinks(_name)
+	if err != nil {
+		devpath = _name
+	}
+	base := filepath.Base(devpath)
+	if _, err := os.Stat(filepath.Join(sysClassBlock, base)); err != nil {
+		return ""
+	}
+	return base
+}
+
+// sysBlockList lists the kernel names in a slaves or holders dir of a block device
+func sysBlockList(_base, _dir string) []string {
+	entries, err := ioutil.ReadDir(filepath.Join(sysClassBlock, _base, _dir))
+	if err != nil {
+		return nil
+	}
+	list := []string{}
+	for _, entry := range entries {
+		list = append(list, entry.Name())
+	}
+	sort.Strings(list)
+	return list
+}
+
+// sysParentDisks walks a kernel block device down to the disk(s) it lives on.
+// Partitions (those with a partition file) resolve to their parent, device-mapper nodes (those with a dm dir) to their slaves.
+// md and loop devices are kept as disks, since that is how parted and scsi name them.
+func sysParentDisks(_base string, _depth int) []string {
+	if _depth > 16 { // guards against a malformed sysfs
+		return []string{_base}
+	}
+	if _, err := os.Stat(filepath.Join(sysClassBlock, _base, "partition")); err == nil {
+		if target, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock, _base)); err == nil {
+			return sysParentDisks(filepath.Base(filepath.Dir(target)), _depth+1)
 		}
-		pre, post := parts[0], parts[1]
-		partitionBeg := strings.IndexAny(post, "0123456789")
-		if partitionBeg < 1 {
-			return _name
+	}
+	if _, err := os.Stat(filepath.Join(sysClassBlock, _base, "dm")); err == nil {
+		slaves := sysBlockList(_base, "slaves")
+		if len(slaves) > 0 {
+			disks := []string{}
+			seen := map[string]bool{}
+			for _, slave := range slaves {
+				for _, disk := range sysParentDisks(slave, _depth+1) {
+					if !seen[disk] {
+						seen[disk] = true
+						disks = append(disks, disk)
+					}
+				}
+			}
+			return disks
 		}
-		return pre + "/sd" + post[:partitionBeg]
 	}
-	return _name
+	return []string{_base}
+}
+
+// Holders lists the devices stacked directly on top of a device (e.g. the md or dm-N using /dev/sda2), as /dev paths
+func Holders(_name string) []string {
+	base := sysBlockName(_name)
+	if base == "" {
+		return nil
+	}
+	holders := sysBlockList(base, "holders")
+	for ii := range holders {
+		holders[ii] = "/dev/" + holders[ii]
+	}
+	return holders
+}
+
+// inferDevName finds the disk under a df Name using sysfs, e.g. /dev/nvme0n1p2 -> /dev/nvme0n1, /dev/mapper/vg-root -> /dev/md1
+// Devices spanning several disks (e.g. a striped LV) are joined with semi, and anything not in sysfs (nfs, tmpfs) is returned unchanged
+func inferDevName(_name string) string {
+	base := sysBlockName(_name)
+	if base == "" {
+		return _name
+	}
+	disks := sysParentDisks(base, 0)
+	for ii := range disks {
+		disks[ii] = "/dev/" + disks[ii]
+	}
+	return strings.Join(disks, semi)
 }
 
 // Df collects df data
@@ -171,5 +239,6 @@ func Df(_localOnly, _verbose bool) (smap map[string][]*Dfdata) {
 			fmt.Printf("line%d: lenitems=%d item0(%s) %s\n", ii, len(items), items[0], strings.Join(items, "#"))
 		}
 	}
+	classifyMounts(smap, _verbose)
 	return smap
 }
@@ -0,0 +1,149 @@
+package df
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mksysfs builds a sysfs tree the way the kernel lays it out: class/block/<name> links to the device dir,
+// which for a partition sits inside its disk's dir. It returns the class/block dir
+//
+//	sda: sda1, and sda2 under LVM as dm-0
+//	sdb: sdb1 and sdb2 in md0; sdc: sdc1 and sdc2 in md1
+//	dm-1: an LV striped over md0 and md1
+//	dm-2: crypt on dm-0; dm-3: an LV over sda1 and sda2
+//	dm-4: a dm device without slaves, e.g. one being torn down
+func mksysfs(t *testing.T) string {
+	root, err := ioutil.TempDir("", "df")
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := map[string]string{
+		"sda":  "devices/pci0/block/sda",
+		"sda1": "devices/pci0/block/sda/sda1",
+		"sda2": "devices/pci0/block/sda/sda2",
+		"sdb":  "devices/pci0/block/sdb",
+		"sdb1": "devices/pci0/block/sdb/sdb1",
+		"sdb2": "devices/pci0/block/sdb/sdb2",
+		"sdc":  "devices/pci0/block/sdc",
+		"sdc1": "devices/pci0/block/sdc/sdc1",
+		"sdc2": "devices/pci0/block/sdc/sdc2",
+		"md0":  "devices/virtual/block/md0",
+		"md1":  "devices/virtual/block/md1",
+		"dm-0": "devices/virtual/block/dm-0",
+		"dm-1": "devices/virtual/block/dm-1",
+		"dm-2": "devices/virtual/block/dm-2",
+		"dm-3": "devices/virtual/block/dm-3",
+		"dm-4": "devices/virtual/block/dm-4",
+	}
+	files := []string{
+		"sda1/partition", "sda2/partition", "sdb1/partition", "sdb2/partition", "sdc1/partition", "sdc2/partition",
+		"dm-0/dm/name", "dm-1/dm/name", "dm-2/dm/name", "dm-3/dm/name", "dm-4/dm/name",
+		"dm-0/slaves/sda2", "sda2/holders/dm-0",
+		"md0/slaves/sdb1", "md0/slaves/sdb2", "sdb1/holders/md0", "sdb2/holders/md0",
+		"md1/slaves/sdc1", "md1/slaves/sdc2", "sdc1/holders/md1", "sdc2/holders/md1",
+		"dm-1/slaves/md1", "dm-1/slaves/md0", "md0/holders/dm-1", "md1/holders/dm-1",
+		"dm-2/slaves/dm-0", "dm-0/holders/dm-2",
+		"dm-3/slaves/sda1", "dm-3/slaves/sda2", "sda1/holders/dm-3", "sda2/holders/dm-3",
+	}
+	classBlock := filepath.Join(root, "class", "block")
+	if err := os.MkdirAll(classBlock, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, dir := range devices {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..", "..", dir), filepath.Join(classBlock, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		items := strings.SplitN(file, "/", 2)
+		path := filepath.Join(root, devices[items[0]], items[1])
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return classBlock
+}
+
+func TestInferDevName(t *testing.T) {
+	classBlock := mksysfs(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(classBlock)))
+	saved := sysClassBlock
+	defer func() { sysClassBlock = saved }()
+	sysClassBlock = classBlock
+	tests := []struct {
+		name, base, want string
+	}{
+		{"/dev/sda1", "sda1", "/dev/sda"},          // a partition
+		{"/dev/sda", "sda", "/dev/sda"},            // a whole disk
+		{"/dev/dm-0", "dm-0", "/dev/sda"},          // dm on a partition
+		{"/dev/md0", "md0", "/dev/md0"},            // md is kept as the disk
+		{"/dev/dm-1", "dm-1", "/dev/md0;/dev/md1"}, // an LV over two md arrays
+		{"/dev/dm-2", "dm-2", "/dev/sda"},          // dm on dm
+		{"/dev/dm-3", "dm-3", "/dev/sda"},          // two partitions of one disk, listed once
+		{"/dev/dm-4", "dm-4", "/dev/dm-4"},         // no slaves
+		{"/dev/sdz1", "", "/dev/sdz1"},             // not in sysfs
+		{"tmpfs", "", "tmpfs"},                     // not a device
+		{"server:/export", "", "server:/export"},   // nfs
+	}
+	for _, tt := range tests {
+		if got := sysBlockName(tt.name); got != tt.base {
+			t.Errorf("sysBlockName(%s) = %q, want %q", tt.name, got, tt.base)
+		}
+		if got := inferDevName(tt.name); got != tt.want {
+			t.Errorf("inferDevName(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHolders(t *testing.T) {
+	classBlock := mksysfs(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(classBlock)))
+	saved := sysClassBlock
+	defer func() { sysClassBlock = saved }()
+	sysClassBlock = classBlock
+	tests := []struct {
+		name, want string
+	}{
+		{"/dev/sda2", "/dev/dm-0 /dev/dm-3"},
+		{"/dev/sdb1", "/dev/md0"},
+		{"/dev/md1", "/dev/dm-1"},
+		{"/dev/dm-0", "/dev/dm-2"},
+		{"/dev/dm-2", ""},
+		{"/dev/sdz1", ""},
+		{"tmpfs", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Holders(tt.name), " "); got != tt.want {
+			t.Errorf("Holders(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSysParentDisksLoop(t *testing.T) {
+	classBlock := mksysfs(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(classBlock)))
+	saved := sysClassBlock
+	defer func() { sysClassBlock = saved }()
+	sysClassBlock = classBlock
+	// a malformed tree where dm-4 is its own slave must end
+	dm4, _ := filepath.EvalSymlinks(filepath.Join(classBlock, "dm-4"))
+	if err := os.MkdirAll(filepath.Join(dm4, "slaves"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dm4, "slaves", "dm-4"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := sysParentDisks("dm-4", 0); (len(got) != 1) || (got[0] != "dm-4") {
+		t.Errorf("sysParentDisks(dm-4) = %v", got)
+	}
+}
@@ -0,0 +1,224 @@
+package df
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dfsample is one dated df observation of a mountpoint, e.g. a row of a nightly Dfdata csv
+type Dfsample struct {
+	Time_ time.Time
+	Df_   *Dfdata
+}
+
+// Dfforecast holds the projected fill of one mountpoint
+type Dfforecast struct {
+	Mountpoint_      string // e.g. /
+	Name_            string // e.g. /dev/md1p1, from the latest sample
+	Samples_         string // e.g. 30, number of samples fitted
+	Sizegb_          string // e.g. 25, from the latest sample
+	Usedgb_          string // e.g. 10, from the latest sample
+	Growthgbperday_  string // e.g. 0.25, slope of the least squares fit of used vs time
+	Confidence_      string // e.g. 0.93, r-squared of that fit
+	Daystofull_      string // e.g. 60, 0 if already full, empty if not growing
+	Fulldate_        string // e.g. 2014-12-31, empty if not growing or more than maxForecastDays away
+	Thresholdpct_    string // e.g. 90
+	Daystothreshold_ string // e.g. 50, 0 if already over, empty if not growing
+}
+
+const (
+	namesForecast     = "Mountpoint,Name,Samples,Sizegb,Usedgb,Growthgbperday,Confidence,Daystofull,Fulldate,Thresholdpct,Daystothreshold"
+	hdrprefixForecast = ",dff."
+	dateLayout        = "2006-01-02"
+	maxForecastDays   = 36500 // Fulldate_ is left empty further out
+)
+
+var (
+	headerStringForecast  string
+	commaStringForecast   string
+	pctStringForecast     string
+	namePctStringForecast string
+)
+
+// init  is generic
+func init() {
+	headerStringForecast = (hdrprefixForecast + strings.Join(strings.Split(namesForecast, ","), hdrprefixForecast))[1:]
+	commaStringForecast = strings.Repeat(",", strings.Count(headerStringForecast, ","))
+	pctStringForecast = strings.Repeat(",%s", 1+strings.Count(headerStringForecast, ","))[1:]
+	namePctStringForecast = strings.Replace(namesForecast, ",", "=%s ", -1) + "=%s\n"
+}
+
+// SortedKeys_String2PtrDfforecast is generic
+func SortedKeys_String2PtrDfforecast(_mp *map[string]*Dfforecast) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForecastHeader is generic
+func ForecastHeader() string { return headerStringForecast }
+
+// Csv is generic
+func (self *Dfforecast) Csv() string {
+	if self == nil {
+		return commaStringForecast
+	}
+	return fmt.Sprintf(pctStringForecast, self.Mountpoint_, self.Name_, self.Samples_, self.Sizegb_, self.Usedgb_, self.Growthgbperday_, self.Confidence_, self.Daystofull_, self.Fulldate_, self.Thresholdpct_, self.Daystothreshold_)
+}
+
+// Sprint is generic
+func (self *Dfforecast) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringForecast, self.Mountpoint_, self.Name_, self.Samples_, self.Sizegb_, self.Usedgb_, self.Growthgbperday_, self.Confidence_, self.Daystofull_, self.Fulldate_, self.Thresholdpct_, self.Daystothreshold_)
+}
+
+// Print is generic
+func (self *Dfforecast) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// FromCsv rebuilds a Dfdata from a line written by Csv, so that saved csvs can be fed to Forecast.
+// Csvs saved before the majmin,root,class columns were added are read too
+func FromCsv(_line string) *Dfdata {
+	items := strings.Split(strings.TrimSpace(_line), ",")
+	if (len(items) != 1+strings.Count(headerString, ",")) && (len(items) != 7) {
+		return nil
+	}
+	dfd := new(Dfdata)
+	dfd.Name_, dfd.Type_, dfd.Mountpoint_, dfd.Sizegb_, dfd.Usedgb_, dfd.Availgb_, dfd.Usepct_ = items[0], items[1], items[2], items[3], items[4], items[5], items[6]
+	if len(items) > 7 {
+		dfd.Majmin_, dfd.Root_, dfd.Class_ = items[7], items[8], items[9]
+	}
+	dfd.DevName_ = dfd.Name_
+	return dfd
+}
+
+// gbFloat parses a size column such as 25 or 25G
+func gbFloat(_str string) (float64, bool) {
+	ff, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(_str), "G"), 64)
+	return ff, err == nil
+}
+
+// ForecastOne fits used space against time for one mountpoint and projects when it reaches the threshold and 100%
+func ForecastOne(_samples []*Dfsample, _thresholdPct float64) *Dfforecast {
+	samples := []*Dfsample{}
+	for _, sample := range _samples {
+		if (sample == nil) || (sample.Df_ == nil) {
+			continue
+		}
+		if _, ok := gbFloat(sample.Df_.Usedgb_); !ok {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) < 1 {
+		return nil
+	}
+	sort.Slice(samples, func(ii, jj int) bool { return samples[ii].Time_.Before(samples[jj].Time_) })
+	last := samples[len(samples)-1]
+	fc := new(Dfforecast)
+	fc.Mountpoint_ = last.Df_.Mountpoint_
+	fc.Name_ = last.Df_.Name_
+	fc.Samples_ = strconv.Itoa(len(samples))
+	fc.Sizegb_ = last.Df_.Sizegb_
+	fc.Usedgb_ = last.Df_.Usedgb_
+	fc.Thresholdpct_ = strconv.FormatFloat(_thresholdPct, 'f', -1, 64)
+	size, okSize := gbFloat(last.Df_.Sizegb_)
+	used, _ := gbFloat(last.Df_.Usedgb_)
+	threshold := size * _thresholdPct / 100
+	if okSize && (used >= threshold) { // already over, whatever the trend
+		fc.Daystothreshold_ = "0"
+	}
+	if okSize && (used >= size) {
+		fc.Daystofull_ = "0"
+		fc.Fulldate_ = last.Time_.Format(dateLayout)
+	}
+	if len(samples) < 2 {
+		return fc
+	}
+
+	// least squares fit of used = aa + bb * days
+	first := samples[0].Time_
+	nn := float64(len(samples))
+	var sx, sy, sxx, sxy, syy float64
+	for _, sample := range samples {
+		xx := sample.Time_.Sub(first).Hours() / 24
+		yy, _ := gbFloat(sample.Df_.Usedgb_)
+		sx += xx
+		sy += yy
+		sxx += xx * xx
+		sxy += xx * yy
+		syy += yy * yy
+	}
+	varx := nn*sxx - sx*sx
+	if varx <= 0 { // all samples taken at the same time
+		return fc
+	}
+	bb := (nn*sxy - sx*sy) / varx
+	vary := nn*syy - sy*sy
+	rsq := 1.0
+	if vary > 0 {
+		rsq = (nn*sxy - sx*sy) * (nn*sxy - sx*sy) / (varx * vary)
+	}
+	fc.Growthgbperday_ = strconv.FormatFloat(bb, 'f', 3, 64)
+	fc.Confidence_ = strconv.FormatFloat(rsq, 'f', 2, 64)
+
+	if !okSize || (bb <= 0) {
+		return fc
+	}
+	if used < size {
+		daysToFull := (size - used) / bb
+		fc.Daystofull_ = strconv.FormatFloat(math.Ceil(daysToFull), 'f', 0, 64)
+		if daysToFull <= maxForecastDays { // beyond it the date would overflow time.Duration, and mean nothing anyway
+			fc.Fulldate_ = last.Time_.AddDate(0, 0, int(math.Ceil(daysToFull))).Format(dateLayout)
+		}
+	}
+	if used < threshold {
+		fc.Daystothreshold_ = strconv.FormatFloat(math.Ceil((threshold-used)/bb), 'f', 0, 64)
+	}
+	return fc
+}
+
+// Forecast runs ForecastOne over a time series of samples keyed by mountpoint
+func Forecast(_series map[string][]*Dfsample, _thresholdPct float64) (smap map[string]*Dfforecast) {
+	smap = make(map[string]*Dfforecast)
+	for mountpoint, samples := range _series {
+		if fc := ForecastOne(samples, _thresholdPct); fc != nil {
+			smap[mountpoint] = fc
+		}
+	}
+	return smap
+}
+
+// ForecastReport lists, soonest first, the mountpoints projected to cross their threshold within _days days
+func ForecastReport(_forecasts map[string]*Dfforecast, _days int) []*Dfforecast {
+	report := []*Dfforecast{}
+	for _, kk := range SortedKeys_String2PtrDfforecast(&_forecasts) {
+		fc := _forecasts[kk]
+		days, err := strconv.Atoi(fc.Daystothreshold_)
+		if (err != nil) || (days > _days) {
+			continue
+		}
+		report = append(report, fc)
+	}
+	sort.SliceStable(report, func(ii, jj int) bool {
+		di, _ := strconv.Atoi(report[ii].Daystothreshold_)
+		dj, _ := strconv.Atoi(report[jj].Daystothreshold_)
+		return di < dj
+	})
+	return report
+}
@@ -0,0 +1,77 @@
+package df
+
+import (
+	"testing"
+	"time"
+)
+
+// series builds daily samples of one mountpoint from used GB values
+func series(_size string, _used ...string) []*Dfsample {
+	start := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []*Dfsample{}
+	for ii, used := range _used {
+		samples = append(samples, &Dfsample{Time_: start.AddDate(0, 0, ii), Df_: &Dfdata{Mountpoint_: "/data", Name_: "/dev/sdb1", Sizegb_: _size, Usedgb_: used}})
+	}
+	return samples
+}
+
+func TestForecastOne(t *testing.T) {
+	tests := []struct {
+		name            string
+		samples         []*Dfsample
+		growth          string
+		confidence      string
+		daystofull      string
+		fulldate        string
+		daystothreshold string
+	}{
+		{"linear", series("100", "10", "12", "14", "16", "18"), "2.000", "1.00", "41", "2014-02-15", "36"},
+		{"noisy", series("100", "10", "13", "13", "16", "18"), "1.900", "0.95", "44", "2014-02-18", "38"},
+		{"suffixed", series("100G", "10G", "12G", "14G", "16G", "18G"), "2.000", "1.00", "41", "2014-02-15", "36"},
+		{"flat", series("100", "50", "50", "50"), "0.000", "1.00", "", "", ""},
+		{"shrinking", series("100", "60", "55", "50"), "-5.000", "1.00", "", "", ""},
+		{"over flat", series("100", "95", "95", "95"), "0.000", "1.00", "", "", "0"},
+		{"over shrinking", series("100", "99", "97", "95"), "-2.000", "1.00", "", "", "0"},
+		{"full", series("100", "100", "100"), "0.000", "1.00", "0", "2014-01-02", "0"},
+		{"one sample over", series("100", "92"), "", "", "", "", "0"},
+		{"one sample", series("100", "10"), "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		fc := ForecastOne(tt.samples, 90)
+		if fc == nil {
+			t.Errorf("%s: nil forecast", tt.name)
+			continue
+		}
+		got := []string{fc.Growthgbperday_, fc.Confidence_, fc.Daystofull_, fc.Fulldate_, fc.Daystothreshold_}
+		want := []string{tt.growth, tt.confidence, tt.daystofull, tt.fulldate, tt.daystothreshold}
+		for ii := range want {
+			if got[ii] != want[ii] {
+				t.Errorf("%s: growth,confidence,daystofull,fulldate,daystothreshold = %q, want %q", tt.name, got, want)
+				break
+			}
+		}
+	}
+	// a tiny positive slope puts the full date past what time.Duration holds
+	if fc := ForecastOne(series("100000", "1", "1.000001"), 90); (fc.Fulldate_ != "") || (len(fc.Daystofull_) < 11) || (len(fc.Daystothreshold_) < 11) {
+		t.Errorf("tiny slope: %s", fc.Sprint())
+	}
+	if fc := ForecastOne([]*Dfsample{nil, {Df_: &Dfdata{Usedgb_: "-"}}}, 90); fc != nil {
+		t.Errorf("unusable samples gave %s", fc.Sprint())
+	}
+}
+
+func TestForecastReport(t *testing.T) {
+	forecasts := Forecast(map[string][]*Dfsample{
+		"/growing": series("100", "10", "12", "14", "16", "18"),
+		"/over":    series("100", "95", "95", "95"),
+		"/flat":    series("100", "50", "50", "50"),
+		"/slow":    series("100", "10", "10.1"),
+	}, 90)
+	report := ForecastReport(forecasts, 60)
+	if (len(report) != 2) || (report[0].Daystothreshold_ != "0") || (report[1].Daystothreshold_ != "36") {
+		for _, fc := range report {
+			t.Log(fc.Sprint())
+		}
+		t.Fatalf("got %d rows, want the over and growing mounts, soonest first", len(report))
+	}
+}
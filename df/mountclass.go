@@ -0,0 +1,100 @@
+package df
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"github.com/LDCS/qslinux/mounts"
+	"sort"
+)
+
+// Mount classes stored in Dfdata.Class_
+const (
+	ClassPrimary   = "primary"   // the mount of the filesystem root, counted in capacity rollups
+	ClassBind      = "bind"      // another view of a filesystem already mounted elsewhere
+	ClassSubvolume = "subvolume" // a btrfs subvolume of a filesystem already mounted elsewhere
+	ClassOverlay   = "overlay"   // overlay/aufs, whose space is used on the underlying filesystems
+	ClassPseudo    = "pseudo"    // memory or kernel filesystems, e.g. tmpfs, proc
+)
+
+var (
+	pseudoFstypes  = map[string]bool{"tmpfs": true, "devtmpfs": true, "ramfs": true, "proc": true, "sysfs": true, "devpts": true, "cgroup": true, "cgroup2": true, "securityfs": true, "debugfs": true, "tracefs": true, "configfs": true, "pstore": true, "mqueue": true, "hugetlbfs": true, "autofs": true, "rpc_pipefs": true, "binfmt_misc": true, "fusectl": true, "bpf": true, "nsfs": true}
+	overlayFstypes = map[string]bool{"overlay": true, "aufs": true}
+)
+
+// classifyMounts fills Majmin_, Root_ and Class_ of the df rows from this process's mountinfo, see classify
+func classifyMounts(_smap map[string][]*Dfdata, _verbose bool) {
+	tree, err := mounts.Tree(false)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("classifyMounts: %s\n", err)
+		}
+		return
+	}
+	classify(_smap, tree.Mounts_, _verbose)
+}
+
+// classify fills Majmin_, Root_ and Class_ of the df rows from a list of mounts in kernel order.
+// Within each major:minor the mount of root / (else the lowest mount id) is primary, the rest are bind mounts or btrfs subvolumes.
+func classify(_smap map[string][]*Dfdata, _mis []*mounts.Mountdata, _verbose bool) {
+	byMountpoint := map[string]*mounts.Mountdata{}
+	for _, mi := range _mis {
+		byMountpoint[mi.Mountpoint_] = mi // later lines are over-mounts, and hide the earlier ones
+	}
+	byMajmin := map[string][]*mounts.Mountdata{}
+	for _, mi := range byMountpoint {
+		byMajmin[mi.Majmin_] = append(byMajmin[mi.Majmin_], mi)
+	}
+	primaries := map[string]*mounts.Mountdata{}
+	for majmin, group := range byMajmin {
+		sort.Slice(group, func(ii, jj int) bool {
+			if (group[ii].Root_ == "/") != (group[jj].Root_ == "/") {
+				return group[ii].Root_ == "/"
+			}
+			return group[ii].Id_ < group[jj].Id_
+		})
+		primaries[majmin] = group[0]
+	}
+	for _, dfds := range _smap {
+		for _, dfd := range dfds {
+			mi := byMountpoint[dfd.Mountpoint_]
+			if mi == nil {
+				continue
+			}
+			dfd.Majmin_ = mi.Majmin_
+			dfd.Root_ = mi.Root_
+			switch {
+			case pseudoFstypes[mi.Fstype_]:
+				dfd.Class_ = ClassPseudo
+			case overlayFstypes[mi.Fstype_]:
+				dfd.Class_ = ClassOverlay
+			case primaries[mi.Majmin_] == mi:
+				dfd.Class_ = ClassPrimary
+			case (mi.Fstype_ == "btrfs") && (mi.Root_ != primaries[mi.Majmin_].Root_) && mi.HasOption("subvol="+mi.Root_):
+				dfd.Class_ = ClassSubvolume
+			default:
+				dfd.Class_ = ClassBind
+			}
+			if _verbose {
+				fmt.Printf("classify: mountpoint=%s majmin=%s root=%s class=%s\n", dfd.Mountpoint_, dfd.Majmin_, dfd.Root_, dfd.Class_)
+			}
+		}
+	}
+}
+
+// Dedup returns one row per underlying filesystem, keyed by major:minor (or Name_ when mountinfo was unavailable), for capacity rollups.
+// Pseudo and overlay mounts are left out, since they hold no disk space of their own.
+func Dedup(_smap map[string][]*Dfdata) (dmap map[string]*Dfdata) {
+	dmap = make(map[string]*Dfdata)
+	for _, kk := range SortedKeys_String2PtrDfdata(&_smap) {
+		for _, dfd := range _smap[kk] {
+			if (dfd.Class_ == ClassPseudo) || (dfd.Class_ == ClassOverlay) {
+				continue
+			}
+			key := genutil.StrTernary(len(dfd.Majmin_) > 0, dfd.Majmin_, dfd.Name_)
+			if (dmap[key] == nil) || (dfd.Class_ == ClassPrimary) {
+				dmap[key] = dfd
+			}
+		}
+	}
+	return dmap
+}
@@ -0,0 +1,137 @@
+package df
+
+import (
+	"github.com/LDCS/qslinux/mounts"
+	"strings"
+	"testing"
+)
+
+// classMountinfo has a bind mount, btrfs subvolumes with and without the top of the filesystem mounted, an overlay, a tmpfs and a stacked mount
+const classMountinfo = `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+2 1 0:22 / /run rw,nosuid - tmpfs tmpfs rw
+3 1 8:2 / /data rw - xfs /dev/sdb1 rw
+4 1 8:2 /export /srv/export rw - xfs /dev/sdb1 rw
+5 1 0:40 /@home /home rw - btrfs /dev/sdc1 rw,space_cache,subvol=/@home
+6 1 0:40 / /mnt/pool rw - btrfs /dev/sdc1 rw,space_cache,subvolid=5,subvol=/
+7 1 0:41 /@ /sys1 rw - btrfs /dev/sdd1 rw,subvol=/@
+8 1 0:41 /@var /sys1var rw - btrfs /dev/sdd1 rw,subvol=/@var
+9 1 0:50 / /var/lib/docker/overlay2/abc/merged rw - overlay overlay rw,lowerdir=/l,upperdir=/u,workdir=/w
+10 1 8:3 / /mnt rw - ext4 /dev/sde1 rw
+11 1 8:4 / /mnt rw - ext4 /dev/sdf1 rw
+`
+
+// classRows are df rows for the mounts above, keyed the way Df keys them
+func classRows() map[string][]*Dfdata {
+	rows := []*Dfdata{
+		{Name_: "/dev/sda1", DevName_: "/dev/sda", Mountpoint_: "/"},
+		{Name_: "tmpfs", DevName_: "tmpfs", Mountpoint_: "/run"},
+		{Name_: "/dev/sdb1", DevName_: "/dev/sdb", Mountpoint_: "/data"},
+		{Name_: "/dev/sdb1", DevName_: "/dev/sdb", Mountpoint_: "/srv/export"},
+		{Name_: "/dev/sdc1", DevName_: "/dev/sdc", Mountpoint_: "/home"},
+		{Name_: "/dev/sdc1", DevName_: "/dev/sdc", Mountpoint_: "/mnt/pool"},
+		{Name_: "/dev/sdd1", DevName_: "/dev/sdd", Mountpoint_: "/sys1"},
+		{Name_: "/dev/sdd1", DevName_: "/dev/sdd", Mountpoint_: "/sys1var"},
+		{Name_: "overlay", DevName_: "overlay", Mountpoint_: "/var/lib/docker/overlay2/abc/merged"},
+		{Name_: "/dev/sdf1", DevName_: "/dev/sdf", Mountpoint_: "/mnt"},
+		{Name_: "nfs:/vol", DevName_: "nfs:/vol", Mountpoint_: "/net/vol"}, // not in mountinfo
+	}
+	smap := map[string][]*Dfdata{}
+	for _, dfd := range rows {
+		smap[dfd.DevName_] = append(smap[dfd.DevName_], dfd)
+	}
+	return smap
+}
+
+// byMountpoint finds a df row
+func byMountpoint(_smap map[string][]*Dfdata, _mountpoint string) *Dfdata {
+	for _, dfds := range _smap {
+		for _, dfd := range dfds {
+			if dfd.Mountpoint_ == _mountpoint {
+				return dfd
+			}
+		}
+	}
+	return nil
+}
+
+func TestClassify(t *testing.T) {
+	smap := classRows()
+	classify(smap, mounts.ParseMountinfo(classMountinfo, false), false)
+	tests := []struct {
+		mountpoint, majmin, root, class string
+	}{
+		{"/", "8:1", "/", ClassPrimary},
+		{"/run", "0:22", "/", ClassPseudo},
+		{"/data", "8:2", "/", ClassPrimary},
+		{"/srv/export", "8:2", "/export", ClassBind},
+		{"/home", "0:40", "/@home", ClassSubvolume},
+		{"/mnt/pool", "0:40", "/", ClassPrimary},      // the top of the filesystem beats a lower mount id
+		{"/sys1", "0:41", "/@", ClassPrimary},         // no top mounted: the lowest id is primary
+		{"/sys1var", "0:41", "/@var", ClassSubvolume}, // ...and the other subvolumes hang off it
+		{"/var/lib/docker/overlay2/abc/merged", "0:50", "/", ClassOverlay},
+		{"/mnt", "8:4", "/", ClassPrimary}, // the visible one of the stacked mounts
+		{"/net/vol", "", "", ""},
+	}
+	for _, tt := range tests {
+		dfd := byMountpoint(smap, tt.mountpoint)
+		if (dfd.Majmin_ != tt.majmin) || (dfd.Root_ != tt.root) || (dfd.Class_ != tt.class) {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", tt.mountpoint, dfd.Majmin_, dfd.Root_, dfd.Class_, tt.majmin, tt.root, tt.class)
+		}
+	}
+}
+
+func TestClassifyBtrfsBindOfSubvolume(t *testing.T) {
+	// /srv/home shows a dir inside the @home subvolume, mounted with bind: its root is not a subvol= option, so it is a bind
+	mis := mounts.ParseMountinfo(`1 0 0:40 / /pool rw - btrfs /dev/sdc1 rw,subvol=/
+2 1 0:40 /@home/alice /srv/alice rw - btrfs /dev/sdc1 rw,subvol=/@home
+`, false)
+	smap := map[string][]*Dfdata{"/dev/sdc": {{Name_: "/dev/sdc1", Mountpoint_: "/pool"}, {Name_: "/dev/sdc1", Mountpoint_: "/srv/alice"}}}
+	classify(smap, mis, false)
+	if got := smap["/dev/sdc"][1].Class_; got != ClassBind {
+		t.Errorf("/srv/alice class %s, want %s", got, ClassBind)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	smap := classRows()
+	classify(smap, mounts.ParseMountinfo(classMountinfo, false), false)
+	dmap := Dedup(smap)
+	want := map[string]string{"8:1": "/", "8:2": "/data", "0:40": "/mnt/pool", "0:41": "/sys1", "8:4": "/mnt", "nfs:/vol": "/net/vol"}
+	if len(dmap) != len(want) {
+		got := []string{}
+		for kk := range dmap {
+			got = append(got, kk)
+		}
+		t.Errorf("got keys %v, want %d", got, len(want))
+	}
+	for key, mountpoint := range want {
+		if (dmap[key] == nil) || (dmap[key].Mountpoint_ != mountpoint) {
+			t.Errorf("%s: got %+v, want %s", key, dmap[key], mountpoint)
+		}
+	}
+}
+
+func TestCsvClassColumns(t *testing.T) {
+	dfd := &Dfdata{Name_: "/dev/sdb1", Type_: "xfs", Mountpoint_: "/srv/a,b", Sizegb_: "100", Usedgb_: "10", Availgb_: "90", Usepct_: "10", Majmin_: "8:2", Root_: "/export", Class_: ClassBind}
+	csv := dfd.Csv()
+	if strings.Count(csv, ",") != strings.Count(Header(), ",") {
+		t.Fatalf("Csv %q does not match the header %q", csv, Header())
+	}
+	if !strings.HasSuffix(Header(), "df.majmin,df.root,df.class") || !strings.HasSuffix(csv, ",8:2,/export,bind") {
+		t.Errorf("Csv %q, header %q", csv, Header())
+	}
+	if !strings.Contains(dfd.Sprint(), "class=bind") {
+		t.Errorf("Sprint %q", dfd.Sprint())
+	}
+	back := FromCsv(csv)
+	if (back == nil) || (back.Mountpoint_ != "/srv/a;b") || (back.Class_ != ClassBind) || (back.Majmin_ != "8:2") {
+		t.Errorf("FromCsv = %+v", back)
+	}
+	old := FromCsv("/dev/sdb1,xfs,/data,100,10,90,10")
+	if (old == nil) || (old.Usepct_ != "10") || (old.Class_ != "") {
+		t.Errorf("FromCsv of a 7 column csv = %+v", old)
+	}
+	if FromCsv("/dev/sdb1,xfs,/data") != nil {
+		t.Errorf("FromCsv of a short line")
+	}
+}
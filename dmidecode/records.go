@@ -0,0 +1,436 @@
+package dmidecode
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dmisection is one "Handle ..." block of dmidecode output
+type Dmisection struct {
+	Handle_ string            // e.g. 0x0011
+	Type_   int               // e.g. 17
+	Title_  string            // e.g. Memory Device
+	Items_  map[string]string // e.g. Locator -> DIMM_A1, values already cleaned
+}
+
+// Biosdata holds SMBIOS type 0 data
+type Biosdata struct {
+	Vendor_      string // e.g. American Megatrends Inc.
+	Version_     string // e.g. 2.0a
+	Releasedate_ string // e.g. 09/01/2011
+}
+
+// Baseboarddata holds SMBIOS type 2 data
+type Baseboarddata struct {
+	Manufacturer_ string // e.g. Supermicro
+	Productname_  string // e.g. X8DTL
+	Version_      string
+	Serialnumber_ string
+	Assettag_     string
+}
+
+// Chassisdata holds SMBIOS type 3 data
+type Chassisdata struct {
+	Manufacturer_ string
+	Type_         string // e.g. Main Server Chassis
+	Version_      string
+	Serialnumber_ string
+	Assettag_     string
+}
+
+// Processordata holds SMBIOS type 4 data
+type Processordata struct {
+	Socket_       string // e.g. CPU1
+	Manufacturer_ string // e.g. Intel
+	Model_        string // e.g. Intel(R) Xeon(R) CPU E5620 @ 2.40GHz
+	Corecount_    string // e.g. 4
+	Threadcount_  string // e.g. 8
+	Maxspeed_     string // e.g. 2400 MHz
+	Status_       string // e.g. Populated; Enabled
+}
+
+// Memorydevicedata holds SMBIOS type 17 data
+type Memorydevicedata struct {
+	Locator_      string // e.g. DIMM_A1
+	Banklocator_  string // e.g. BANK0
+	Size_         string // e.g. 8192 MB, or No Module Installed
+	Type_         string // e.g. DDR3
+	Speed_        string // e.g. 1333 MT/s, see memorySpeed
+	Manufacturer_ string
+	Partnumber_   string
+	Serialnumber_ string
+	Ecc_          string // e.g. Multi-bit ECC, from the owning type 16 array
+}
+
+const (
+	namesBios         = "Vendor,Version,Releasedate"
+	namesBaseboard    = "Manufacturer,Productname,Version,Serialnumber,Assettag"
+	namesChassis      = "Manufacturer,Type,Version,Serialnumber,Assettag"
+	namesProcessor    = "Socket,Manufacturer,Model,Corecount,Threadcount,Maxspeed,Status"
+	namesMemorydevice = "Locator,Banklocator,Size,Type,Speed,Manufacturer,Partnumber,Serialnumber,Ecc"
+	hdrprefixBios     = ",dcb."
+	hdrprefixBoard    = ",dcbb."
+	hdrprefixChassis  = ",dcc."
+	hdrprefixCpu      = ",dcp."
+	hdrprefixMem      = ",dcm."
+)
+
+var (
+	headerStringBios          string
+	headerStringBaseboard     string
+	headerStringChassis       string
+	headerStringProcessor     string
+	headerStringMemorydevice  string
+	commaStringBios           string
+	commaStringBaseboard      string
+	commaStringChassis        string
+	commaStringProcessor      string
+	commaStringMemorydevice   string
+	pctStringBios             string
+	pctStringBaseboard        string
+	pctStringChassis          string
+	pctStringProcessor        string
+	pctStringMemorydevice     string
+	namePctStringBios         string
+	namePctStringBaseboard    string
+	namePctStringChassis      string
+	namePctStringProcessor    string
+	namePctStringMemorydevice string
+)
+
+// init  is generic
+func init() {
+	headerStringBios = (hdrprefixBios + strings.Join(strings.Split(namesBios, ","), hdrprefixBios))[1:]
+	headerStringBaseboard = (hdrprefixBoard + strings.Join(strings.Split(namesBaseboard, ","), hdrprefixBoard))[1:]
+	headerStringChassis = (hdrprefixChassis + strings.Join(strings.Split(namesChassis, ","), hdrprefixChassis))[1:]
+	headerStringProcessor = (hdrprefixCpu + strings.Join(strings.Split(namesProcessor, ","), hdrprefixCpu))[1:]
+	headerStringMemorydevice = (hdrprefixMem + strings.Join(strings.Split(namesMemorydevice, ","), hdrprefixMem))[1:]
+	commaStringBios = strings.Repeat(",", strings.Count(headerStringBios, ","))
+	commaStringBaseboard = strings.Repeat(",", strings.Count(headerStringBaseboard, ","))
+	commaStringChassis = strings.Repeat(",", strings.Count(headerStringChassis, ","))
+	commaStringProcessor = strings.Repeat(",", strings.Count(headerStringProcessor, ","))
+	commaStringMemorydevice = strings.Repeat(",", strings.Count(headerStringMemorydevice, ","))
+	pctStringBios = strings.Repeat(",%s", 1+strings.Count(headerStringBios, ","))[1:]
+	pctStringBaseboard = strings.Repeat(",%s", 1+strings.Count(headerStringBaseboard, ","))[1:]
+	pctStringChassis = strings.Repeat(",%s", 1+strings.Count(headerStringChassis, ","))[1:]
+	pctStringProcessor = strings.Repeat(",%s", 1+strings.Count(headerStringProcessor, ","))[1:]
+	pctStringMemorydevice = strings.Repeat(",%s", 1+strings.Count(headerStringMemorydevice, ","))[1:]
+	namePctStringBios = strings.Replace(namesBios, ",", "=%s ", -1) + "=%s\n"
+	namePctStringBaseboard = strings.Replace(namesBaseboard, ",", "=%s ", -1) + "=%s\n"
+	namePctStringChassis = strings.Replace(namesChassis, ",", "=%s ", -1) + "=%s\n"
+	namePctStringProcessor = strings.Replace(namesProcessor, ",", "=%s ", -1) + "=%s\n"
+	namePctStringMemorydevice = strings.Replace(namesMemorydevice, ",", "=%s ", -1) + "=%s\n"
+}
+
+// BiosHeader is generic
+func BiosHeader() string { return headerStringBios }
+
+// BaseboardHeader is generic
+func BaseboardHeader() string { return headerStringBaseboard }
+
+// ChassisHeader is generic
+func ChassisHeader() string { return headerStringChassis }
+
+// ProcessorHeader is generic
+func ProcessorHeader() string { return headerStringProcessor }
+
+// MemorydeviceHeader is generic
+func MemorydeviceHeader() string { return headerStringMemorydevice }
+
+// Csv is generic
+func (self *Biosdata) Csv() string {
+	if self == nil {
+		return commaStringBios
+	}
+	return fmt.Sprintf(pctStringBios, self.Vendor_, self.Version_, self.Releasedate_)
+}
+
+// Sprint is generic
+func (self *Biosdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringBios, self.Vendor_, self.Version_, self.Releasedate_)
+}
+
+// Csv is generic
+func (self *Baseboarddata) Csv() string {
+	if self == nil {
+		return commaStringBaseboard
+	}
+	return fmt.Sprintf(pctStringBaseboard, self.Manufacturer_, self.Productname_, self.Version_, self.Serialnumber_, self.Assettag_)
+}
+
+// Sprint is generic
+func (self *Baseboarddata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringBaseboard, self.Manufacturer_, self.Productname_, self.Version_, self.Serialnumber_, self.Assettag_)
+}
+
+// Csv is generic
+func (self *Chassisdata) Csv() string {
+	if self == nil {
+		return commaStringChassis
+	}
+	return fmt.Sprintf(pctStringChassis, self.Manufacturer_, self.Type_, self.Version_, self.Serialnumber_, self.Assettag_)
+}
+
+// Sprint is generic
+func (self *Chassisdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringChassis, self.Manufacturer_, self.Type_, self.Version_, self.Serialnumber_, self.Assettag_)
+}
+
+// Csv is generic
+func (self *Processordata) Csv() string {
+	if self == nil {
+		return commaStringProcessor
+	}
+	return fmt.Sprintf(pctStringProcessor, self.Socket_, self.Manufacturer_, self.Model_, self.Corecount_, self.Threadcount_, self.Maxspeed_, self.Status_)
+}
+
+// Sprint is generic
+func (self *Processordata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringProcessor, self.Socket_, self.Manufacturer_, self.Model_, self.Corecount_, self.Threadcount_, self.Maxspeed_, self.Status_)
+}
+
+// Csv is generic
+func (self *Memorydevicedata) Csv() string {
+	if self == nil {
+		return commaStringMemorydevice
+	}
+	return fmt.Sprintf(pctStringMemorydevice, self.Locator_, self.Banklocator_, self.Size_, self.Type_, self.Speed_, self.Manufacturer_, self.Partnumber_, self.Serialnumber_, self.Ecc_)
+}
+
+// Sprint is generic
+func (self *Memorydevicedata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringMemorydevice, self.Locator_, self.Banklocator_, self.Size_, self.Type_, self.Speed_, self.Manufacturer_, self.Partnumber_, self.Serialnumber_, self.Ecc_)
+}
+
+// SortedKeys_String2PtrProcessordata is generic
+func SortedKeys_String2PtrProcessordata(_mp *map[string]*Processordata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SortedKeys_String2PtrMemorydevicedata is generic
+func SortedKeys_String2PtrMemorydevicedata(_mp *map[string]*Memorydevicedata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseSections splits dmidecode output into its Handle blocks, e.g.
+//
+//	Handle 0x0011, DMI type 17, 28 bytes
+//	Memory Device
+//		Locator: DIMM_A1
+//
+// Multi-line values (e.g. Characteristics) are not kept
+func ParseSections(_out string, _verbose bool) []*Dmisection {
+	sections := []*Dmisection{}
+	var lastds *Dmisection
+	for ii, lineraw := range strings.Split(_out, "\n") {
+		line := strings.TrimSpace(lineraw)
+		switch {
+		case len(line) == 0:
+			continue
+		case strings.HasPrefix(line, "Handle "):
+			// Handle 0x0011, DMI type 17, 28 bytes
+			items := strings.Split(line, ",")
+			lastds = new(Dmisection)
+			lastds.Handle_ = strings.TrimSpace(strings.TrimPrefix(items[0], "Handle "))
+			if len(items) > 1 {
+				lastds.Type_, _ = strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(items[1]), "DMI type "))
+			}
+			lastds.Items_ = map[string]string{}
+			sections = append(sections, lastds)
+		case lastds == nil:
+			continue
+		case len(lastds.Title_) == 0:
+			lastds.Title_ = line
+		default:
+			kv := strings.SplitN(line, ":", 2)
+			if (len(kv) < 2) || (len(strings.TrimSpace(kv[1])) == 0) {
+				if _verbose {
+					fmt.Printf("line%d: handle=%s %s\n", ii, lastds.Handle_, line)
+				}
+				continue
+			}
+			lastds.Items_[strings.TrimSpace(kv[0])] = cleanItem(kv[1])
+		}
+	}
+	return sections
+}
+
+// dmidecodeSections runs dmidecode for a comma separated list of types and returns their sections
+func dmidecodeSections(_types string, _verbose bool) []*Dmisection {
+	out := genutil.BashExecOrDie(_verbose, "/usr/bin/timeout 10 /usr/sbin/dmidecode -t "+_types, ".")
+	if _verbose {
+		fmt.Println(out)
+	}
+	return ParseSections(out, _verbose)
+}
+
+// firstSection returns the first section of a type, or nil
+func firstSection(_sections []*Dmisection, _type int) *Dmisection {
+	for _, ds := range _sections {
+		if ds.Type_ == _type {
+			return ds
+		}
+	}
+	return nil
+}
+
+// SystemFromSections extracts type 1
+func SystemFromSections(_sections []*Dmisection) *Dmidecodedata {
+	ds := firstSection(_sections, 1)
+	if ds == nil {
+		return nil
+	}
+	dc := new(Dmidecodedata)
+	dc.Manufacturer_, dc.Productname_, dc.Serialnumber_, dc.Uuid_ = ds.Items_["Manufacturer"], ds.Items_["Product Name"], ds.Items_["Serial Number"], ds.Items_["UUID"]
+	dc.Manufacturer_ = NormalizeVendor(dc.Manufacturer_)
+	dc.Modelfamily_ = ModelFamily(dc.Manufacturer_, dc.Productname_)
+	return dc
+}
+
+// BiosFromSections extracts type 0
+func BiosFromSections(_sections []*Dmisection) *Biosdata {
+	ds := firstSection(_sections, 0)
+	if ds == nil {
+		return nil
+	}
+	bios := new(Biosdata)
+	bios.Vendor_, bios.Version_, bios.Releasedate_ = ds.Items_["Vendor"], ds.Items_["Version"], ds.Items_["Release Date"]
+	return bios
+}
+
+// BaseboardFromSections extracts type 2
+func BaseboardFromSections(_sections []*Dmisection) *Baseboarddata {
+	ds := firstSection(_sections, 2)
+	if ds == nil {
+		return nil
+	}
+	board := new(Baseboarddata)
+	board.Manufacturer_, board.Productname_, board.Version_ = ds.Items_["Manufacturer"], ds.Items_["Product Name"], ds.Items_["Version"]
+	board.Serialnumber_, board.Assettag_ = ds.Items_["Serial Number"], ds.Items_["Asset Tag"]
+	board.Manufacturer_ = NormalizeVendor(board.Manufacturer_)
+	return board
+}
+
+// ChassisFromSections extracts type 3
+func ChassisFromSections(_sections []*Dmisection) *Chassisdata {
+	ds := firstSection(_sections, 3)
+	if ds == nil {
+		return nil
+	}
+	chassis := new(Chassisdata)
+	chassis.Manufacturer_, chassis.Type_, chassis.Version_ = ds.Items_["Manufacturer"], ds.Items_["Type"], ds.Items_["Version"]
+	chassis.Serialnumber_, chassis.Assettag_ = ds.Items_["Serial Number"], ds.Items_["Asset Tag"]
+	chassis.Manufacturer_ = NormalizeVendor(chassis.Manufacturer_)
+	return chassis
+}
+
+// ProcessorsFromSections extracts type 4, keyed by socket
+func ProcessorsFromSections(_sections []*Dmisection) (smap map[string]*Processordata) {
+	smap = make(map[string]*Processordata)
+	for _, ds := range _sections {
+		if ds.Type_ != 4 {
+			continue
+		}
+		cpu := new(Processordata)
+		cpu.Socket_, cpu.Manufacturer_, cpu.Model_ = ds.Items_["Socket Designation"], NormalizeVendor(ds.Items_["Manufacturer"]), ds.Items_["Version"]
+		cpu.Corecount_, cpu.Threadcount_, cpu.Maxspeed_, cpu.Status_ = ds.Items_["Core Count"], ds.Items_["Thread Count"], ds.Items_["Max Speed"], ds.Items_["Status"]
+		smap[genutil.StrTernary(len(cpu.Socket_) > 0, cpu.Socket_, ds.Handle_)] = cpu
+	}
+	return smap
+}
+
+// memorySpeed gives a type 17 speed in MT/s, the unit SMBIOS defines it in; dmidecode before 3.3 labels the same number MHz
+func memorySpeed(_speed string) string {
+	if strings.HasSuffix(_speed, " MHz") {
+		return strings.TrimSuffix(_speed, " MHz") + " MT/s"
+	}
+	return _speed
+}
+
+// MemorydevicesFromSections extracts type 17, keyed by locator, taking Ecc from the type 16 array each device belongs to
+func MemorydevicesFromSections(_sections []*Dmisection) (smap map[string]*Memorydevicedata) {
+	smap = make(map[string]*Memorydevicedata)
+	arrays := map[string]*Dmisection{}
+	for _, ds := range _sections {
+		if ds.Type_ == 16 {
+			arrays[ds.Handle_] = ds
+		}
+	}
+	for _, ds := range _sections {
+		if ds.Type_ != 17 {
+			continue
+		}
+		dimm := new(Memorydevicedata)
+		dimm.Locator_, dimm.Banklocator_, dimm.Size_, dimm.Type_ = ds.Items_["Locator"], ds.Items_["Bank Locator"], ds.Items_["Size"], ds.Items_["Type"]
+		dimm.Speed_, dimm.Manufacturer_ = memorySpeed(ds.Items_["Speed"]), NormalizeVendor(ds.Items_["Manufacturer"])
+		dimm.Partnumber_, dimm.Serialnumber_ = ds.Items_["Part Number"], ds.Items_["Serial Number"]
+		if array := arrays[ds.Items_["Array Handle"]]; array != nil {
+			dimm.Ecc_ = array.Items_["Error Correction Type"]
+		} else if (len(ds.Items_["Total Width"]) > 0) && (ds.Items_["Total Width"] != ds.Items_["Data Width"]) {
+			dimm.Ecc_ = "ECC"
+		}
+		key := genutil.StrTernary(len(dimm.Locator_) > 0, dimm.Locator_, ds.Handle_)
+		if smap[key] != nil { // some boards reuse a locator across banks
+			key = dimm.Banklocator_ + "/" + key
+		}
+		smap[key] = dimm
+	}
+	return smap
+}
+
+// Bios extracts dmidecode bios data
+func Bios(_verbose bool) *Biosdata {
+	return BiosFromSections(dmidecodeSections("0", _verbose))
+}
+
+// Baseboard extracts dmidecode baseboard data
+func Baseboard(_verbose bool) *Baseboarddata {
+	return BaseboardFromSections(dmidecodeSections("2", _verbose))
+}
+
+// Chassis extracts dmidecode chassis data
+func Chassis(_verbose bool) *Chassisdata {
+	return ChassisFromSections(dmidecodeSections("3", _verbose))
+}
+
+// Processors extracts dmidecode processor data
+func Processors(_verbose bool) map[string]*Processordata {
+	return ProcessorsFromSections(dmidecodeSections("4", _verbose))
+}
+
+// Memorydevices extracts dmidecode memory device data
+func Memorydevices(_verbose bool) map[string]*Memorydevicedata {
+	return MemorydevicesFromSections(dmidecodeSections("16,17", _verbose))
+}
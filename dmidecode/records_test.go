@@ -0,0 +1,192 @@
+package dmidecode
+
+import (
+	"strings"
+	"testing"
+)
+
+// textOut is dmidecode 2.12 output (as on CentOS 7) for types 0, 1, 4, 16 and 17; speeds are labelled MHz
+const textOut = `# dmidecode 2.12
+SMBIOS 2.7 present.
+
+Handle 0x0000, DMI type 0, 24 bytes
+BIOS Information
+	Vendor: American Megatrends Inc.
+	Version: 3.0a
+	Release Date: 02/19/2014
+	Characteristics:
+		PCI is supported
+		BIOS is upgradeable
+
+Handle 0x0001, DMI type 1, 27 bytes
+System Information
+	Manufacturer: Supermicro
+	Product Name: X9DRi-LN4+/X9DR3-LN4+
+	Serial Number: 0123456789
+	UUID: 00000000-0000-0000-0000-0CC47A000000
+
+Handle 0x0004, DMI type 4, 42 bytes
+Processor Information
+	Socket Designation: CPU1
+	Manufacturer: Intel
+	Version: Intel(R) Xeon(R) CPU E5-2620 v2 @ 2.10GHz
+	Max Speed: 4000 MHz
+	Status: Populated, Enabled
+	Core Count: 6
+	Thread Count: 12
+
+Handle 0x002C, DMI type 16, 23 bytes
+Physical Memory Array
+	Location: System Board Or Motherboard
+	Error Correction Type: Multi-bit ECC
+	Number Of Devices: 4
+
+Handle 0x002E, DMI type 17, 34 bytes
+Memory Device
+	Array Handle: 0x002C
+	Total Width: 72 bits
+	Data Width: 64 bits
+	Size: 16384 MB
+	Locator: P1-DIMMA1
+	Bank Locator: P0_Node0_Channel0_Dimm0
+	Type: DDR3
+	Speed: 1600 MHz
+	Manufacturer: Hynix Semiconductor
+	Serial Number: 12345678
+	Part Number: HMT42GR7AFR4A-PB
+
+Handle 0x0030, DMI type 17, 34 bytes
+Memory Device
+	Array Handle: 0x002C
+	Total Width: Unknown
+	Data Width: Unknown
+	Size: No Module Installed
+	Locator: P1-DIMMA2
+	Bank Locator: P0_Node0_Channel0_Dimm1
+	Type: Unknown
+	Speed: Unknown
+	Manufacturer: NO DIMM
+	Serial Number: NO DIMM
+	Part Number: NO DIMM
+
+Handle 0x0031, DMI type 17, 34 bytes
+Memory Device
+	Array Handle: 0x00FF
+	Total Width: 72 bits
+	Data Width: 64 bits
+	Size: 8192 MB
+	Locator: DIMM
+	Bank Locator: BANK 0
+	Speed: 2400 MT/s
+
+Handle 0x0032, DMI type 17, 34 bytes
+Memory Device
+	Total Width: 64 bits
+	Data Width: 64 bits
+	Size: 8192 MB
+	Locator: DIMM
+	Bank Locator: BANK 1
+
+Handle 0x0033, DMI type 17, 34 bytes
+Memory Device
+	Size: 4096 MB
+	Manufacturer: To be filled by O.E.M.
+`
+
+func TestParseSections(t *testing.T) {
+	sections := ParseSections(textOut, false)
+	if len(sections) != 9 {
+		t.Fatalf("got %d sections, want 9", len(sections))
+	}
+	tests := []struct {
+		ii     int
+		handle string
+		typ    int
+		title  string
+		items  map[string]string // "" means absent
+	}{
+		{0, "0x0000", 0, "BIOS Information", map[string]string{"Vendor": "American Megatrends Inc.", "Release Date": "02/19/2014", "Characteristics": "", "PCI is supported": ""}},
+		{1, "0x0001", 1, "System Information", map[string]string{"Product Name": "X9DRi-LN4+/X9DR3-LN4+", "UUID": "00000000-0000-0000-0000-0CC47A000000"}},
+		{2, "0x0004", 4, "Processor Information", map[string]string{"Status": "Populated; Enabled", "Version": "Intel(R) Xeon(R) CPU E5-2620 v2 @ 2.10GHz"}},
+		{4, "0x002E", 17, "Memory Device", map[string]string{"Array Handle": "0x002C", "Speed": "1600 MHz"}},
+		{8, "0x0033", 17, "Memory Device", map[string]string{"Manufacturer": "OEM"}},
+	}
+	for _, tt := range tests {
+		ds := sections[tt.ii]
+		if (ds.Handle_ != tt.handle) || (ds.Type_ != tt.typ) || (ds.Title_ != tt.title) {
+			t.Errorf("section%d: got %s type %d %q", tt.ii, ds.Handle_, ds.Type_, ds.Title_)
+		}
+		for kk, want := range tt.items {
+			got, ok := ds.Items_[kk]
+			if (got != want) || (ok != (len(want) > 0)) {
+				t.Errorf("section%d: %s = %q (%v), want %q", tt.ii, kk, got, ok, want)
+			}
+		}
+	}
+	if got := len(ParseSections("# dmidecode 3.3\nNo SMBIOS nor DMI entry point found, sorry.\n", false)); got != 0 {
+		t.Errorf("no tables: %d sections", got)
+	}
+}
+
+func TestFromSections(t *testing.T) {
+	sections := ParseSections(textOut, false)
+	if dc := SystemFromSections(sections); (dc == nil) || (dc.Manufacturer_ != "Supermicro") || (dc.Modelfamily_ != "X9") {
+		t.Errorf("system = %+v", dc)
+	}
+	if bios := BiosFromSections(sections); (bios == nil) || (bios.Version_ != "3.0a") {
+		t.Errorf("bios = %+v", bios)
+	}
+	if board := BaseboardFromSections(sections); board != nil {
+		t.Errorf("baseboard = %+v, want nil without type 2", board)
+	}
+	cpus := ProcessorsFromSections(sections)
+	if cpu := cpus["CPU1"]; (len(cpus) != 1) || (cpu == nil) || (cpu.Manufacturer_ != "Intel") || (cpu.Corecount_ != "6") || (cpu.Maxspeed_ != "4000 MHz") {
+		t.Errorf("processors = %v", cpus)
+	}
+}
+
+func TestMemorydevicesFromSections(t *testing.T) {
+	smap := MemorydevicesFromSections(ParseSections(textOut, false))
+	tests := []struct {
+		key                                        string
+		size, speed, ecc, manufacturer, partnumber string
+	}{
+		{"P1-DIMMA1", "16384 MB", "1600 MT/s", "Multi-bit ECC", "Hynix Semiconductor", "HMT42GR7AFR4A-PB"},
+		{"P1-DIMMA2", "No Module Installed", "Unknown", "Multi-bit ECC", "NO DIMM", "NO DIMM"},
+		{"DIMM", "8192 MB", "2400 MT/s", "ECC", "", ""}, // array handle of no array: ECC from the widths
+		{"BANK 1/DIMM", "8192 MB", "", "", "", ""},      // the locator again, keyed by bank
+		{"0x0033", "4096 MB", "", "", "OEM", ""},        // no locator, keyed by handle
+	}
+	if len(smap) != len(tests) {
+		t.Errorf("got %d devices, want %d: %v", len(smap), len(tests), SortedKeys_String2PtrMemorydevicedata(&smap))
+	}
+	for _, tt := range tests {
+		dimm := smap[tt.key]
+		if dimm == nil {
+			t.Errorf("%s: missing", tt.key)
+			continue
+		}
+		if (dimm.Size_ != tt.size) || (dimm.Speed_ != tt.speed) || (dimm.Ecc_ != tt.ecc) || (dimm.Manufacturer_ != tt.manufacturer) || (dimm.Partnumber_ != tt.partnumber) {
+			t.Errorf("%s: got %s", tt.key, dimm.Sprint())
+		}
+		if strings.Count(dimm.Csv(), ",") != strings.Count(MemorydeviceHeader(), ",") {
+			t.Errorf("%s: Csv %q does not match the header", tt.key, dimm.Csv())
+		}
+	}
+}
+
+func TestMemorySpeed(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1333 MHz", "1333 MT/s"},
+		{"4800 MT/s", "4800 MT/s"},
+		{"Unknown", "Unknown"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := memorySpeed(tt.in); got != tt.want {
+			t.Errorf("memorySpeed(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package dmidecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The kernel exports the raw SMBIOS tables (root only) and a few decoded strings (mostly world readable)
+var (
+	sysDmiTables = "/sys/firmware/dmi/tables"
+	sysDmiId     = "/sys/class/dmi/id"
+)
+
+var (
+	chassisTypes = []string{"", "Other", "Unknown", "Desktop", "Low Profile Desktop", "Pizza Box", "Mini Tower", "Tower", "Portable", "Laptop", "Notebook", "Hand Held", "Docking Station", "All In One", "Sub Notebook", "Space-saving", "Lunch Box", "Main Server Chassis", "Expansion Chassis", "Sub Chassis", "Bus Expansion Chassis", "Peripheral Chassis", "RAID Chassis", "Rack Mount Chassis", "Sealed-case PC", "Multi-system", "CompactPCI", "AdvancedTCA", "Blade", "Blade Enclosure", "Tablet", "Convertible", "Detachable", "IoT Gateway", "Embedded PC", "Mini PC", "Stick PC"}
+	eccTypes     = []string{"", "Other", "Unknown", "None", "Parity", "Single-bit ECC", "Multi-bit ECC", "CRC"}
+	memoryTypes  = []string{"", "Other", "Unknown", "DRAM", "EDRAM", "VRAM", "SRAM", "RAM", "ROM", "Flash", "EEPROM", "FEPROM", "EPROM", "CDRAM", "3DRAM", "SDRAM", "SGRAM", "RDRAM", "DDR", "DDR2", "DDR2 FB-DIMM", "Reserved", "Reserved", "Reserved", "DDR3", "FBD2", "DDR4", "LPDDR", "LPDDR2", "LPDDR3", "LPDDR4", "Logical non-volatile device", "HBM", "HBM2", "DDR5", "LPDDR5"}
+	cpuStatuses  = []string{"Unknown", "Enabled", "Disabled By User", "Disabled By BIOS", "Idle", "Other", "Other", "Other"}
+)
+
+// enumName returns the dmidecode name of an SMBIOS enumerated value
+func enumName(_names []string, _val int) string {
+	if (_val > 0) && (_val < len(_names)) {
+		return _names[_val]
+	}
+	return fmt.Sprintf("<OUT OF SPEC>(%d)", _val)
+}
+
+// smbiosStruct is one raw structure of the SMBIOS table
+type smbiosStruct struct {
+	formatted []byte   // header included, so offsets match the SMBIOS spec
+	strs      []string // string set, 1-based in the spec
+}
+
+// byteAt is a bounds checked read of a formatted-area byte
+func (self *smbiosStruct) byteAt(_off int) (int, bool) {
+	if _off >= len(self.formatted) {
+		return 0, false
+	}
+	return int(self.formatted[_off]), true
+}
+
+// wordAt is a bounds checked read of a formatted-area little endian word
+func (self *smbiosStruct) wordAt(_off int) (int, bool) {
+	if _off+2 > len(self.formatted) {
+		return 0, false
+	}
+	return int(binary.LittleEndian.Uint16(self.formatted[_off:])), true
+}
+
+// dwordAt is a bounds checked read of a formatted-area little endian dword
+func (self *smbiosStruct) dwordAt(_off int) (uint32, bool) {
+	if _off+4 > len(self.formatted) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(self.formatted[_off:]), true
+}
+
+// strAt resolves the string index held at a formatted-area offset
+func (self *smbiosStruct) strAt(_off int) string {
+	idx, ok := self.byteAt(_off)
+	if !ok || (idx == 0) || (idx > len(self.strs)) {
+		return ""
+	}
+	return cleanItem(self.strs[idx-1])
+}
+
+// parseEntryPoint returns the SMBIOS version and table length from a 2.x (_SM_) or 3.x (_SM3_) entry point
+func parseEntryPoint(_entry []byte) (major, minor, tableLen int, err error) {
+	switch {
+	case (len(_entry) >= 24) && (string(_entry[:5]) == "_SM3_"):
+		return int(_entry[7]), int(_entry[8]), int(binary.LittleEndian.Uint32(_entry[12:16])), nil
+	case (len(_entry) >= 31) && (string(_entry[:4]) == "_SM_"):
+		return int(_entry[6]), int(_entry[7]), int(binary.LittleEndian.Uint16(_entry[22:24])), nil
+	}
+	return 0, 0, 0, errors.New("dmidecode: no _SM_ or _SM3_ anchor in smbios entry point")
+}
+
+// splitTable cuts the SMBIOS table into its structures, stopping at type 127 (end of table)
+func splitTable(_table []byte) []*smbiosStruct {
+	structs := []*smbiosStruct{}
+	for off := 0; off+4 <= len(_table); {
+		length := int(_table[off+1])
+		if (length < 4) || (off+length > len(_table)) {
+			break
+		}
+		ss := &smbiosStruct{formatted: _table[off : off+length]}
+		end := off + length
+		for (end+1 < len(_table)) && !((_table[end] == 0) && (_table[end+1] == 0)) {
+			end++
+		}
+		if end > off+length {
+			ss.strs = strings.Split(string(_table[off+length:end]), "\x00")
+		}
+		structs = append(structs, ss)
+		if _table[off] == 127 {
+			break
+		}
+		off = end + 2
+	}
+	return structs
+}
+
+// formatUuid prints a type 1 uuid the way dmidecode does, byte swapping the first three fields from SMBIOS 2.6 on
+func formatUuid(_uuid []byte, _major, _minor int) string {
+	if len(_uuid) != 16 {
+		return ""
+	}
+	bb := append([]byte{}, _uuid...)
+	if (_major > 2) || ((_major == 2) && (_minor >= 6)) {
+		bb[0], bb[1], bb[2], bb[3] = bb[3], bb[2], bb[1], bb[0]
+		bb[4], bb[5] = bb[5], bb[4]
+		bb[6], bb[7] = bb[7], bb[6]
+	}
+	return fmt.Sprintf("%X-%X-%X-%X-%X", bb[0:4], bb[4:6], bb[6:8], bb[8:10], bb[10:16])
+}
+
+// decodeStruct turns the structure types used by the records into a Dmisection keyed like dmidecode text output
+func decodeStruct(_ss *smbiosStruct, _major, _minor int) *Dmisection {
+	ds := new(Dmisection)
+	ds.Type_ = int(_ss.formatted[0])
+	ds.Handle_ = fmt.Sprintf("0x%04X", binary.LittleEndian.Uint16(_ss.formatted[2:4]))
+	ds.Items_ = map[string]string{}
+	set := func(_key, _val string) {
+		if len(_val) > 0 {
+			ds.Items_[_key] = _val
+		}
+	}
+	switch ds.Type_ {
+	case 0:
+		ds.Title_ = "BIOS Information"
+		set("Vendor", _ss.strAt(0x04))
+		set("Version", _ss.strAt(0x05))
+		set("Release Date", _ss.strAt(0x08))
+	case 1:
+		ds.Title_ = "System Information"
+		set("Manufacturer", _ss.strAt(0x04))
+		set("Product Name", _ss.strAt(0x05))
+		set("Version", _ss.strAt(0x06))
+		set("Serial Number", _ss.strAt(0x07))
+		if len(_ss.formatted) >= 0x18 {
+			set("UUID", formatUuid(_ss.formatted[0x08:0x18], _major, _minor))
+		}
+	case 2:
+		ds.Title_ = "Base Board Information"
+		set("Manufacturer", _ss.strAt(0x04))
+		set("Product Name", _ss.strAt(0x05))
+		set("Version", _ss.strAt(0x06))
+		set("Serial Number", _ss.strAt(0x07))
+		set("Asset Tag", _ss.strAt(0x08))
+	case 3:
+		ds.Title_ = "Chassis Information"
+		set("Manufacturer", _ss.strAt(0x04))
+		if vv, ok := _ss.byteAt(0x05); ok {
+			set("Type", enumName(chassisTypes, vv&0x7F))
+		}
+		set("Version", _ss.strAt(0x06))
+		set("Serial Number", _ss.strAt(0x07))
+		set("Asset Tag", _ss.strAt(0x08))
+	case 4:
+		ds.Title_ = "Processor Information"
+		set("Socket Designation", _ss.strAt(0x04))
+		set("Manufacturer", _ss.strAt(0x07))
+		set("Version", _ss.strAt(0x10))
+		if vv, ok := _ss.wordAt(0x14); ok && (vv > 0) {
+			set("Max Speed", fmt.Sprintf("%d MHz", vv))
+		}
+		if vv, ok := _ss.byteAt(0x18); ok {
+			if vv&0x40 == 0 {
+				set("Status", "Unpopulated")
+			} else {
+				set("Status", "Populated; "+cpuStatuses[vv&0x07])
+			}
+		}
+		if vv, ok := _ss.byteAt(0x23); ok && (vv > 0) {
+			if vv2, ok2 := _ss.wordAt(0x2A); (vv == 0xFF) && ok2 {
+				vv = vv2
+			}
+			set("Core Count", strconv.Itoa(vv))
+		}
+		if vv, ok := _ss.byteAt(0x25); ok && (vv > 0) {
+			if vv2, ok2 := _ss.wordAt(0x2E); (vv == 0xFF) && ok2 {
+				vv = vv2
+			}
+			set("Thread Count", strconv.Itoa(vv))
+		}
+	case 16:
+		ds.Title_ = "Physical Memory Array"
+		if vv, ok := _ss.byteAt(0x06); ok {
+			set("Error Correction Type", enumName(eccTypes, vv))
+		}
+	case 17:
+		ds.Title_ = "Memory Device"
+		if vv, ok := _ss.wordAt(0x04); ok {
+			set("Array Handle", fmt.Sprintf("0x%04X", vv))
+		}
+		if vv, ok := _ss.wordAt(0x08); ok && (vv != 0xFFFF) {
+			set("Total Width", fmt.Sprintf("%d bits", vv))
+		}
+		if vv, ok := _ss.wordAt(0x0A); ok && (vv != 0xFFFF) {
+			set("Data Width", fmt.Sprintf("%d bits", vv))
+		}
+		if vv, ok := _ss.wordAt(0x0C); ok {
+			switch {
+			case vv == 0:
+				set("Size", "No Module Installed")
+			case vv == 0xFFFF:
+				set("Size", "Unknown")
+			case vv == 0x7FFF:
+				if ext, ok := _ss.dwordAt(0x1C); ok {
+					set("Size", fmt.Sprintf("%d MB", ext&0x7FFFFFFF))
+				}
+			case vv&0x8000 != 0:
+				set("Size", fmt.Sprintf("%d kB", vv&0x7FFF))
+			default:
+				set("Size", fmt.Sprintf("%d MB", vv))
+			}
+		}
+		set("Locator", _ss.strAt(0x10))
+		set("Bank Locator", _ss.strAt(0x11))
+		if vv, ok := _ss.byteAt(0x12); ok {
+			set("Type", enumName(memoryTypes, vv))
+		}
+		if vv, ok := _ss.wordAt(0x15); ok && (vv > 0) {
+			set("Speed", fmt.Sprintf("%d MT/s", vv))
+		}
+		set("Manufacturer", _ss.strAt(0x17))
+		set("Serial Number", _ss.strAt(0x18))
+		set("Part Number", _ss.strAt(0x1A))
+	default:
+		return nil
+	}
+	return ds
+}
+
+// ParseSmbios decodes a raw SMBIOS entry point and table as exported by the kernel, into sections
+func ParseSmbios(_entry, _table []byte, _verbose bool) ([]*Dmisection, error) {
+	major, minor, tableLen, err := parseEntryPoint(_entry)
+	if err != nil {
+		return nil, err
+	}
+	if (tableLen > 0) && (tableLen < len(_table)) {
+		_table = _table[:tableLen]
+	}
+	if _verbose {
+		fmt.Printf("ParseSmbios: SMBIOS %d.%d tablelen=%d\n", major, minor, len(_table))
+	}
+	sections := []*Dmisection{}
+	for _, ss := range splitTable(_table) {
+		if ds := decodeStruct(ss, major, minor); ds != nil {
+			sections = append(sections, ds)
+		}
+	}
+	return sections, nil
+}
+
+// SmbiosFromFiles decodes an entry point file and a DMI table file, e.g. copies of /sys/firmware/dmi/tables taken from another box
+func SmbiosFromFiles(_entryPath, _tablePath string, _verbose bool) ([]*Dmisection, error) {
+	entry, err := ioutil.ReadFile(_entryPath)
+	if err != nil {
+		return nil, err
+	}
+	table, err := ioutil.ReadFile(_tablePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSmbios(entry, table, _verbose)
+}
+
+// readDmiId reads one /sys/class/dmi/id file, "" if absent or unreadable
+func readDmiId(_name string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(sysDmiId, _name))
+	if err != nil {
+		return ""
+	}
+	return cleanItem(string(buf))
+}
+
+// dmiIdSections builds type 0 to 3 sections from /sys/class/dmi/id; serial numbers and uuid are only readable by root
+func dmiIdSections() []*Dmisection {
+	files := []struct {
+		dmitype int
+		title   string
+		keys    map[string]string
+	}{
+		{0, "BIOS Information", map[string]string{"Vendor": "bios_vendor", "Version": "bios_version", "Release Date": "bios_date"}},
+		{1, "System Information", map[string]string{"Manufacturer": "sys_vendor", "Product Name": "product_name", "Version": "product_version", "Serial Number": "product_serial", "UUID": "product_uuid"}},
+		{2, "Base Board Information", map[string]string{"Manufacturer": "board_vendor", "Product Name": "board_name", "Version": "board_version", "Serial Number": "board_serial", "Asset Tag": "board_asset_tag"}},
+		{3, "Chassis Information", map[string]string{"Manufacturer": "chassis_vendor", "Version": "chassis_version", "Serial Number": "chassis_serial", "Asset Tag": "chassis_asset_tag"}},
+	}
+	sections := []*Dmisection{}
+	for _, ff := range files {
+		ds := &Dmisection{Type_: ff.dmitype, Title_: ff.title, Items_: map[string]string{}}
+		for key, file := range ff.keys {
+			if val := readDmiId(file); len(val) > 0 {
+				ds.Items_[key] = val
+			}
+		}
+		if uuid, ok := ds.Items_["UUID"]; ok {
+			ds.Items_["UUID"] = strings.ToUpper(uuid)
+		}
+		if ff.dmitype == 3 {
+			if vv, err := strconv.Atoi(readDmiId("chassis_type")); err == nil {
+				ds.Items_["Type"] = enumName(chassisTypes, vv)
+			}
+		}
+		sections = append(sections, ds)
+	}
+	return sections
+}
+
+// Smbios reads the SMBIOS tables straight from sysfs, without the dmidecode binary.
+// When the tables are not readable (not root, old kernel) it falls back to /sys/class/dmi/id, which covers bios, system, baseboard and chassis only.
+func Smbios(_verbose bool) []*Dmisection {
+	sections, err := SmbiosFromFiles(filepath.Join(sysDmiTables, "smbios_entry_point"), filepath.Join(sysDmiTables, "DMI"), _verbose)
+	if err == nil {
+		return sections
+	}
+	if _verbose {
+		fmt.Printf("Smbios: %s, falling back to %s\n", err, sysDmiId)
+	}
+	return dmiIdSections()
+}
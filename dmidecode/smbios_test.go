@@ -0,0 +1,115 @@
+package dmidecode
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// The testdata tables follow the layout QEMU's firmware exports under /sys/firmware/dmi/tables: smbios2_* has a 2.8 _SM_ entry point,
+// smbios3_* a 3.3 _SM3_ one. Strings are deliberately out of field order, some fields use string index 0 or an index past the set,
+// type 2 has no strings at all (the formatted area is followed directly by the double NUL), and the 2.x table is padded past its length
+func TestSmbiosFromFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		want  map[string]map[string]string // by handle, then item; "" means the item must be absent
+	}{
+		{"smbios2", 8, map[string]map[string]string{
+			"0x0000": {"Vendor": "SeaBIOS", "Version": "rel-1.16.3-0-ga6ed6b701f0a-prebuilt.qemu.org", "Release Date": "04/01/2014"},
+			"0x0100": {"Manufacturer": "QEMU", "Product Name": "Standard PC (Q35 + ICH9; 2009)", "Version": "", "Serial Number": "VM-0042", "UUID": "00112233-4455-6677-8899-AABBCCDDEEFF"},
+			"0x0200": {"Manufacturer": "", "Product Name": ""},
+			"0x0300": {"Manufacturer": "QEMU", "Type": "Rack Mount Chassis", "Version": "pc-q35-8.2", "Serial Number": "CH-7", "Asset Tag": ""},
+			"0x0400": {"Socket Designation": "CPU 0", "Manufacturer": "QEMU", "Version": "pc-q35-8.2", "Max Speed": "2000 MHz", "Status": "Populated; Enabled", "Core Count": "4", "Thread Count": "8"},
+			"0x1000": {"Error Correction Type": "None"},
+			"0x1100": {"Array Handle": "0x1000", "Total Width": "", "Size": "16384 MB", "Locator": "DIMM 0", "Bank Locator": "", "Type": "RAM", "Speed": "", "Manufacturer": "QEMU"},
+			"0x1101": {"Size": "512 kB", "Locator": "DIMM 1"},
+		}},
+		{"smbios3", 9, map[string]map[string]string{
+			"0x0100": {"Manufacturer": "QEMU", "UUID": "00112233-4455-6677-8899-AABBCCDDEEFF"},
+			"0x0200": {"Manufacturer": ""},
+			"0x0300": {"Type": "Rack Mount Chassis"},
+			"0x0400": {"Manufacturer": "Advanced Micro Devices; Inc.", "Version": "AMD EPYC 9354 32-Core Processor", "Max Speed": "3700 MHz", "Status": "Populated; Enabled", "Core Count": "64", "Thread Count": "128"},
+			"0x0401": {"Socket Designation": "CPU 1", "Manufacturer": "", "Version": "Not Specified", "Status": "Unpopulated", "Core Count": ""},
+			"0x1000": {"Error Correction Type": "Multi-bit ECC"},
+			"0x1100": {"Total Width": "80 bits", "Data Width": "64 bits", "Size": "65536 MB", "Locator": "DIMM A1", "Bank Locator": "P0 CHANNEL A", "Type": "DDR5", "Speed": "4800 MT/s", "Manufacturer": "Samsung", "Serial Number": "80CE01234567", "Part Number": "M321R8GA0BB0-CQKZJ; rev 2"},
+			"0x1101": {"Size": "No Module Installed", "Type": "Unknown", "Manufacturer": "", "Part Number": ""},
+		}},
+	}
+	for _, tt := range tests {
+		sections, err := SmbiosFromFiles(filepath.Join("testdata", tt.name+"_entry_point"), filepath.Join("testdata", tt.name+"_DMI"), false)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if len(sections) != tt.count {
+			t.Errorf("%s: %d sections, want %d", tt.name, len(sections), tt.count)
+		}
+		byHandle := map[string]*Dmisection{}
+		types := map[int]bool{}
+		for _, ds := range sections {
+			byHandle[ds.Handle_] = ds
+			types[ds.Type_] = true
+		}
+		for _, dmitype := range []int{0, 1, 2, 3, 4, 16, 17} {
+			if !types[dmitype] {
+				t.Errorf("%s: no type %d section", tt.name, dmitype)
+			}
+		}
+		if types[32] || types[127] {
+			t.Errorf("%s: undecoded types returned", tt.name)
+		}
+		for handle, items := range tt.want {
+			ds := byHandle[handle]
+			if ds == nil {
+				t.Errorf("%s: no section %s", tt.name, handle)
+				continue
+			}
+			for key, want := range items {
+				if got, ok := ds.Items_[key]; (got != want) || (ok != (len(want) > 0)) {
+					t.Errorf("%s: %s %s = %q, want %q", tt.name, handle, key, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseEntryPoint(t *testing.T) {
+	tests := []struct {
+		entry        []byte
+		major, minor int
+		tableLen     int
+		ok           bool
+	}{
+		{append([]byte("_SM3_\x00\x18\x03\x03\x00\x01\x00\x81\x02\x00\x00"), make([]byte, 8)...), 3, 3, 0x281, true},
+		{append([]byte("_SM_\x00\x1f\x02\x08"), append(make([]byte, 14), 0xd4, 0x01, 0, 0, 0, 0, 0, 0, 0)...), 2, 8, 0x1d4, true},
+		{[]byte("_SM3_\x00\x18\x03"), 0, 0, 0, false},
+		{append([]byte("_DMI_"), make([]byte, 26)...), 0, 0, 0, false},
+	}
+	for ii, tt := range tests {
+		major, minor, tableLen, err := parseEntryPoint(tt.entry)
+		if (err == nil) != tt.ok {
+			t.Errorf("case%d: err = %v", ii, err)
+			continue
+		}
+		if (major != tt.major) || (minor != tt.minor) || (tableLen != tt.tableLen) {
+			t.Errorf("case%d: got %d.%d len %d, want %d.%d len %d", ii, major, minor, tableLen, tt.major, tt.minor, tt.tableLen)
+		}
+	}
+}
+
+func TestSplitTableStrings(t *testing.T) {
+	// a type 1 with two strings, a type 2 without strings, then the end of table marker
+	table := []byte{1, 8, 0, 1, 2, 1, 0, 0, 'A', 0, 'B', 'C', 0, 0, 2, 4, 0, 2, 0, 0, 127, 4, 0, 3, 0, 0}
+	structs := splitTable(table)
+	if len(structs) != 3 {
+		t.Fatalf("%d structures, want 3", len(structs))
+	}
+	if (len(structs[0].strs) != 2) || (structs[0].strAt(4) != "BC") || (structs[0].strAt(5) != "A") || (structs[0].strAt(6) != "") {
+		t.Errorf("type 1 strings = %q", structs[0].strs)
+	}
+	if (len(structs[1].strs) != 0) || (structs[1].formatted[0] != 2) {
+		t.Errorf("type 2 = %v %q", structs[1].formatted, structs[1].strs)
+	}
+	if structs[2].formatted[0] != 127 {
+		t.Errorf("last type = %d, want 127", structs[2].formatted[0])
+	}
+}
@@ -0,0 +1,131 @@
+package dmidecode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Vendorrule maps raw vendor strings to a canonical vendor, or, when Family_ is set, products of a canonical vendor to a model family.
+// Match_ is case-insensitive; a vendor rule matches whole words at the start (HP matches HP Inc. but not HPC Systems),
+// a family rule any prefix, since model numbers follow directly, e.g. X10DRi
+type Vendorrule struct {
+	Match_  string // e.g. Hewlett-Packard, or ProLiant for a family rule
+	Vendor_ string // e.g. HP
+	Family_ string // e.g. ProLiant, empty for a vendor rule
+}
+
+// builtinVendorRules covers the vendors seen in our server rooms; the first matching rule wins
+var builtinVendorRules = []*Vendorrule{
+	{Match_: "Hewlett Packard Enterprise", Vendor_: "HP"},
+	{Match_: "Hewlett-Packard", Vendor_: "HP"},
+	{Match_: "HPE", Vendor_: "HP"},
+	{Match_: "HP", Vendor_: "HP"},
+	{Match_: "Super Micro", Vendor_: "Supermicro"},
+	{Match_: "Supermicro", Vendor_: "Supermicro"},
+	{Match_: "Gigabyte", Vendor_: "Gigabyte"},
+	{Match_: "GIGA-BYTE", Vendor_: "Gigabyte"},
+	{Match_: "Dell", Vendor_: "Dell"},
+	{Match_: "LENOVO", Vendor_: "Lenovo"},
+	{Match_: "IBM", Vendor_: "IBM"},
+	{Match_: "ASUSTeK", Vendor_: "ASUS"},
+	{Match_: "Intel", Vendor_: "Intel"},
+	{Match_: "VMware", Vendor_: "VMware"},
+	{Match_: "QEMU", Vendor_: "QEMU"},
+	{Match_: "ProLiant", Vendor_: "HP", Family_: "ProLiant"},
+	{Match_: "PowerEdge", Vendor_: "Dell", Family_: "PowerEdge"},
+	{Match_: "System x", Vendor_: "IBM", Family_: "System x"},
+	{Match_: "ThinkSystem", Vendor_: "Lenovo", Family_: "ThinkSystem"},
+	{Match_: "X8", Vendor_: "Supermicro", Family_: "X8"},
+	{Match_: "X9", Vendor_: "Supermicro", Family_: "X9"},
+	{Match_: "X10", Vendor_: "Supermicro", Family_: "X10"},
+	{Match_: "X11", Vendor_: "Supermicro", Family_: "X11"},
+}
+
+// userVendorRules are loaded by LoadVendorRules and take precedence over the builtin ones; userVendorRulesMu guards them,
+// since rules may be reloaded while other goroutines normalize
+var (
+	userVendorRules   = []*Vendorrule{}
+	userVendorRulesMu sync.RWMutex
+)
+
+// LoadVendorRules reads site specific rules, one per line, # starts a comment:
+//
+//	vendor,Hewlett-Packard,HP
+//	family,HP,ProLiant,ProLiant
+func LoadVendorRules(_path string, _verbose bool) error {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return err
+	}
+	rules := []*Vendorrule{}
+	for ii, lineraw := range strings.Split(string(buf), "\n") {
+		line := strings.TrimSpace(lineraw)
+		if (len(line) == 0) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.Split(line, ",")
+		for jj := range items {
+			items[jj] = strings.TrimSpace(items[jj])
+		}
+		switch {
+		case (items[0] == "vendor") && (len(items) == 3):
+			rules = append(rules, &Vendorrule{Match_: items[1], Vendor_: items[2]})
+		case (items[0] == "family") && (len(items) == 4):
+			rules = append(rules, &Vendorrule{Match_: items[2], Vendor_: items[1], Family_: items[3]})
+		default:
+			return fmt.Errorf("dmidecode: %s line%d: bad vendor rule: %s", _path, ii+1, line)
+		}
+		if _verbose {
+			fmt.Printf("LoadVendorRules: line%d: %s\n", ii+1, line)
+		}
+	}
+	userVendorRulesMu.Lock()
+	userVendorRules = rules
+	userVendorRulesMu.Unlock()
+	return nil
+}
+
+// vendorRules lists the user rules then the builtin ones
+func vendorRules() []*Vendorrule {
+	userVendorRulesMu.RLock()
+	defer userVendorRulesMu.RUnlock()
+	return append(append([]*Vendorrule{}, userVendorRules...), builtinVendorRules...)
+}
+
+// hasWordPrefix tells whether _str starts with the words of _prefix, i.e. the prefix is not followed by a letter or digit
+func hasWordPrefix(_str, _prefix string) bool {
+	if !strings.HasPrefix(_str, _prefix) {
+		return false
+	}
+	rest := strings.TrimPrefix(_str, _prefix)
+	for _, rr := range rest {
+		return !unicode.IsLetter(rr) && !unicode.IsDigit(rr)
+	}
+	return true
+}
+
+// NormalizeVendor maps a raw vendor string (from dmidecode, smbios, lsscsi ...) to its canonical name, e.g. Hewlett-Packard -> HP
+// Strings matching no rule are returned as is
+func NormalizeVendor(_raw string) string {
+	raw := strings.ToLower(strings.TrimSpace(_raw))
+	for _, rule := range vendorRules() {
+		if (len(rule.Family_) == 0) && hasWordPrefix(raw, strings.ToLower(rule.Match_)) {
+			return rule.Vendor_
+		}
+	}
+	return strings.TrimSpace(_raw)
+}
+
+// ModelFamily maps a product name of a canonical vendor to its model family, e.g. HP + ProLiant DL380 G7 -> ProLiant, or "" if unknown
+func ModelFamily(_vendor, _product string) string {
+	product := strings.ToLower(strings.TrimSpace(_product))
+	for _, rule := range vendorRules() {
+		if (len(rule.Family_) > 0) && (rule.Vendor_ == _vendor) && strings.HasPrefix(product, strings.ToLower(rule.Match_)) {
+			return rule.Family_
+		}
+	}
+	return ""
+}
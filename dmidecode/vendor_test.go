@@ -0,0 +1,153 @@
+package dmidecode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNormalizeVendor(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"HP", "HP"},
+		{"hp", "HP"},
+		{"  HP  ", "HP"},
+		{"HP Inc.", "HP"},
+		{"HPE", "HP"},
+		{"Hewlett-Packard", "HP"},
+		{"Hewlett Packard Enterprise", "HP"},
+		{"HPC Systems Inc.", "HPC Systems Inc."}, // not HP
+		{"HPCompute", "HPCompute"},
+		{"Dell Inc.", "Dell"},
+		{"DELL", "Dell"},
+		{"Dellwood", "Dellwood"},
+		{"Supermicro", "Supermicro"},
+		{"Super Micro Computer, Inc.", "Supermicro"},
+		{"GIGA-BYTE TECHNOLOGY CO., LTD.", "Gigabyte"},
+		{"LENOVO", "Lenovo"},
+		{"IBM", "IBM"},
+		{"ASUSTeK COMPUTER INC.", "ASUS"},
+		{"Intel(R) Corporation", "Intel"},
+		{"Intelligent Systems", "Intelligent Systems"},
+		{"VMware, Inc.", "VMware"},
+		{"QEMU", "QEMU"},
+		{"Not Specified", "Not Specified"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeVendor(tt.raw); got != tt.want {
+			t.Errorf("NormalizeVendor(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestModelFamily(t *testing.T) {
+	tests := []struct {
+		vendor, product, want string
+	}{
+		{"HP", "ProLiant DL380 G7", "ProLiant"},
+		{"HP", "proliant dl360p gen8", "ProLiant"},
+		{"Dell", "PowerEdge R740", "PowerEdge"},
+		{"IBM", "System x3650 M5", "System x"},
+		{"Lenovo", "ThinkSystem SR650", "ThinkSystem"},
+		{"Supermicro", "X10DRi", "X10"}, // family rules match any prefix
+		{"Supermicro", "X11SPM-F", "X11"},
+		{"Supermicro", "X9DRW", "X9"},
+		{"Dell", "ProLiant DL380", ""}, // the rule is for another vendor
+		{"HP", "Compaq 8200 Elite", ""},
+		{"QEMU", "Standard PC (Q35 + ICH9; 2009)", ""},
+	}
+	for _, tt := range tests {
+		if got := ModelFamily(tt.vendor, tt.product); got != tt.want {
+			t.Errorf("ModelFamily(%s, %s) = %q, want %q", tt.vendor, tt.product, got, tt.want)
+		}
+	}
+}
+
+func TestLoadVendorRules(t *testing.T) {
+	saved := userVendorRules
+	defer func() { userVendorRules = saved }()
+	dir, err := ioutil.TempDir("", "dmidecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(_name, _text string) string {
+		path := filepath.Join(dir, _name)
+		if err := ioutil.WriteFile(path, []byte(_text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good", `# site rules
+vendor, Quanta Computer, Quanta
+vendor,Dell,DellEMC
+
+family,Quanta,D51,D51 series
+family,HP,ProLiant,PL
+`)
+	if err := LoadVendorRules(good, false); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		raw, want string
+	}{
+		{"Quanta Computer Inc", "Quanta"},
+		{"Dell Inc.", "DellEMC"}, // user rules come first
+		{"Hewlett-Packard", "HP"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeVendor(tt.raw); got != tt.want {
+			t.Errorf("NormalizeVendor(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+	if got := ModelFamily("Quanta", "D51B-2U"); got != "D51 series" {
+		t.Errorf("ModelFamily(Quanta) = %q", got)
+	}
+	if got := ModelFamily("HP", "ProLiant DL380"); got != "PL" {
+		t.Errorf("ModelFamily(HP) = %q, user rules should win", got)
+	}
+	for _, bad := range []string{"vendor,Dell\n", "family,HP,ProLiant\n", "vendor,a,b,c\n", "model,HP,x\n", "# ok\nvendor,a,b\nnonsense\n"} {
+		err := LoadVendorRules(write("bad", bad), false)
+		if (err == nil) || !strings.Contains(err.Error(), "bad vendor rule") {
+			t.Errorf("%q: err = %v", bad, err)
+		}
+	}
+	if got := NormalizeVendor("Dell Inc."); got != "DellEMC" {
+		t.Errorf("a bad file replaced the loaded rules: %q", got)
+	}
+	if err := LoadVendorRules(filepath.Join(dir, "missing"), false); err == nil {
+		t.Errorf("LoadVendorRules of a missing file: no error")
+	}
+}
+
+func TestVendorRulesConcurrent(t *testing.T) {
+	saved := userVendorRules
+	defer func() { userVendorRules = saved }()
+	dir, err := ioutil.TempDir("", "dmidecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules")
+	if err := ioutil.WriteFile(path, []byte("vendor,Quanta,Quanta\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for ii := 0; ii < 8; ii++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			LoadVendorRules(path, false)
+		}()
+		go func() {
+			defer wg.Done()
+			NormalizeVendor("Hewlett-Packard")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,116 @@
+// Package etcfile rewrites configuration files, e.g. /etc/fstab or /etc/hosts, safely on linux
+//
+// A file is replaced atomically via a temp file in the same dir, fsync and rename, keeping the mode, owner, group and SELinux label of the old file.
+// A symlinked file is replaced at its target, so the symlink stays
+package etcfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// keptXattrs are copied from the old file onto the new one; without the SELinux label, e.g. net_conf_t on /etc/hosts,
+// the new file would get the default label of its dir and confined daemons could no longer read it
+var keptXattrs = []string{"security.selinux"}
+
+// attrs are what a replacement keeps of the old file
+type attrs struct {
+	mode   os.FileMode
+	uid    int // -1 leaves the owner the file was created with
+	gid    int
+	xattrs map[string][]byte
+}
+
+// readAttrs reads the attrs of an existing file
+func readAttrs(_path string, _fi os.FileInfo) *attrs {
+	at := &attrs{mode: _fi.Mode().Perm(), uid: -1, gid: -1, xattrs: map[string][]byte{}}
+	if st, ok := _fi.Sys().(*syscall.Stat_t); ok {
+		at.uid, at.gid = int(st.Uid), int(st.Gid)
+	}
+	for _, name := range keptXattrs {
+		size, err := syscall.Getxattr(_path, name, nil)
+		if (err != nil) || (size <= 0) {
+			continue // ENODATA when unlabelled, ENOTSUP where the filesystem has no xattrs
+		}
+		value := make([]byte, size)
+		if size, err = syscall.Getxattr(_path, name, value); err == nil {
+			at.xattrs[name] = value[:size]
+		}
+	}
+	return at
+}
+
+// writeSync writes and fsyncs a file with the attrs given
+func writeSync(_path string, _data []byte, _at *attrs) error {
+	ff, err := os.OpenFile(_path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, _at.mode)
+	if err != nil {
+		return err
+	}
+	if _, err := ff.Write(_data); err != nil {
+		ff.Close()
+		return err
+	}
+	if err := ff.Chmod(_at.mode); err != nil {
+		ff.Close()
+		return err
+	}
+	if err := ff.Chown(_at.uid, _at.gid); err != nil {
+		ff.Close()
+		return err
+	}
+	for name, value := range _at.xattrs {
+		if err := syscall.Setxattr(_path, name, value, 0); err != nil {
+			ff.Close()
+			return &os.PathError{Op: "setxattr " + name, Path: _path, Err: err}
+		}
+	}
+	if err := ff.Sync(); err != nil {
+		ff.Close()
+		return err
+	}
+	return ff.Close()
+}
+
+// Replace writes _data to _path atomically, following symlinks to the real file. If the file exists it keeps its mode, owner, group
+// and SELinux label, and with _backup its old contents are first copied to .bak next to it; a new file gets mode 0644 and the owner of the process
+func Replace(_path string, _data []byte, _backup bool) error {
+	path := _path
+	if target, err := filepath.EvalSymlinks(_path); err == nil {
+		path = target
+	}
+	at := &attrs{mode: 0644, uid: -1, gid: -1}
+	if fi, err := os.Stat(path); err == nil {
+		at = readAttrs(path, fi)
+		if _backup {
+			old, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := writeSync(path+".bak", old, at); err != nil {
+				return err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".qslinux.")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if err := writeSync(tmp.Name(), _data, at); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if ff, err := os.Open(dir); err == nil {
+		ff.Sync()
+		ff.Close()
+	}
+	return nil
+}
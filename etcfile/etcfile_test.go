@@ -0,0 +1,155 @@
+package etcfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts")
+	tests := []struct {
+		name    string
+		old     string // "" for no file
+		mode    os.FileMode
+		backup  bool
+		data    string
+		wantBak string // "" for no backup
+	}{
+		{"new file", "", 0644, true, "a\n", ""},
+		{"keeps mode", "a\n", 0600, false, "b\n", ""},
+		{"backup", "b\n", 0640, true, "c\n", "b\n"},
+	}
+	for _, tt := range tests {
+		os.Remove(path)
+		os.Remove(path + ".bak")
+		if len(tt.old) > 0 {
+			if err := ioutil.WriteFile(path, []byte(tt.old), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			os.Chmod(path, tt.mode)
+		}
+		if err := Replace(path, []byte(tt.data), tt.backup); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		buf, _ := ioutil.ReadFile(path)
+		if string(buf) != tt.data {
+			t.Errorf("%s: got %q, want %q", tt.name, buf, tt.data)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != tt.mode {
+			t.Errorf("%s: mode %v, want %v", tt.name, fi.Mode().Perm(), tt.mode)
+		}
+		if st := fi.Sys().(*syscall.Stat_t); (int(st.Uid) != os.Getuid()) || (int(st.Gid) != os.Getgid()) {
+			t.Errorf("%s: owner %d:%d, want %d:%d", tt.name, st.Uid, st.Gid, os.Getuid(), os.Getgid())
+		}
+		bak, err := ioutil.ReadFile(path + ".bak")
+		if (len(tt.wantBak) == 0) != os.IsNotExist(err) || (string(bak) != tt.wantBak) {
+			t.Errorf("%s: backup %q (%v), want %q", tt.name, bak, err, tt.wantBak)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(dir, ".hosts.qslinux.*")); len(matches) > 0 {
+			t.Errorf("%s: temp files left: %v", tt.name, matches)
+		}
+	}
+}
+
+func TestReplaceKeepsOwner(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("needs root to chown")
+	}
+	dir, err := ioutil.TempDir("", "etcfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fstab")
+	if err := ioutil.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(path, 12345, 23456); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(path, []byte("b\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{path, path + ".bak"} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st := fi.Sys().(*syscall.Stat_t); (st.Uid != 12345) || (st.Gid != 23456) {
+			t.Errorf("%s: owner %d:%d, want 12345:23456", name, st.Uid, st.Gid)
+		}
+	}
+}
+
+func TestReplaceFollowsSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "hosts.real")
+	link := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(target, []byte("a\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("hosts.real", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(link, []byte("b\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); (err != nil) || (fi.Mode()&os.ModeSymlink == 0) {
+		t.Errorf("%s is no longer a symlink", link)
+	}
+	if buf, _ := ioutil.ReadFile(target); string(buf) != "b\n" {
+		t.Errorf("target holds %q", buf)
+	}
+	if buf, _ := ioutil.ReadFile(target + ".bak"); string(buf) != "a\n" {
+		t.Errorf("backup holds %q", buf)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0640 {
+		t.Errorf("target mode %v", fi.Mode().Perm())
+	}
+}
+
+func TestReplaceKeepsXattrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// security.selinux needs SELinux, a user xattr goes through the same code
+	if err := syscall.Setxattr(path, "user.qslinux", []byte("system_u:object_r:net_conf_t:s0\x00"), 0); err != nil {
+		t.Skipf("no user xattrs here: %s", err)
+	}
+	saved := keptXattrs
+	keptXattrs = []string{"security.selinux", "user.qslinux"}
+	defer func() { keptXattrs = saved }()
+	if err := Replace(path, []byte("b\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{path, path + ".bak"} {
+		value := make([]byte, 64)
+		size, err := syscall.Getxattr(name, "user.qslinux", value)
+		if (err != nil) || (string(value[:size]) != "system_u:object_r:net_conf_t:s0\x00") {
+			t.Errorf("%s: xattr %q, %v", name, value[:size], err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package etcfstab
+
+import (
+	"fmt"
+	"github.com/LDCS/qslinux/blkid"
+	"github.com/LDCS/qslinux/df"
+	"github.com/LDCS/qslinux/mounts"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Auditdata is one finding of the fstab versus reality audit
+type Auditdata struct {
+	Check_    string // notmounted, undeclared, unresolved, duplicate or passno
+	Severity_ string // error (boot is likely to fail), warn or info
+	Lineno_   string // fstab line, empty for undeclared mounts
+	Spec_     string
+	File_     string
+	Detail_   string
+}
+
+const (
+	namesAudit     = "Check,Severity,Lineno,Spec,File,Detail"
+	hdrprefixAudit = ",xfa."
+)
+
+var (
+	headerStringAudit  string
+	commaStringAudit   string
+	pctStringAudit     string
+	namePctStringAudit string
+	procSwaps          = "/proc/swaps"
+	procMountinfo      = "/proc/self/mountinfo"
+	noFsckFstypes      = map[string]bool{"xfs": true, "btrfs": true, "nfs": true, "nfs4": true, "cifs": true, "smbfs": true, "tmpfs": true, "swap": true, "proc": true, "sysfs": true, "devpts": true, "none": true, "bind": true, "glusterfs": true, "ceph": true, "fuse": true}
+	fsckFstypes        = map[string]bool{"ext2": true, "ext3": true, "ext4": true, "vfat": true, "msdos": true, "jfs": true, "reiserfs": true}
+	// runtimeDirs hold mounts made at runtime, not from fstab
+	runtimeDirs = []string{"/run", "/dev", "/sys", "/proc"}
+)
+
+// init  is generic
+func init() {
+	headerStringAudit = (hdrprefixAudit + strings.Join(strings.Split(namesAudit, ","), hdrprefixAudit))[1:]
+	commaStringAudit = strings.Repeat(",", strings.Count(headerStringAudit, ","))
+	pctStringAudit = strings.Repeat(",%s", 1+strings.Count(headerStringAudit, ","))[1:]
+	namePctStringAudit = strings.Replace(namesAudit, ",", "=%s ", -1) + "=%s\n"
+}
+
+// AuditHeader is generic
+func AuditHeader() string { return headerStringAudit }
+
+// Csv is generic
+func (self *Auditdata) Csv() string {
+	if self == nil {
+		return commaStringAudit
+	}
+	return fmt.Sprintf(pctStringAudit, self.Check_, self.Severity_, self.Lineno_, self.Spec_, self.File_, strings.Replace(self.Detail_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Auditdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringAudit, self.Check_, self.Severity_, self.Lineno_, self.Spec_, self.File_, self.Detail_)
+}
+
+// Print is generic
+func (self *Auditdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// newAudit is a finding about an fstab entry
+func newAudit(_check, _severity string, _xfs *Fstabdata, _detail string) *Auditdata {
+	return &Auditdata{Check_: _check, Severity_: _severity, Lineno_: fmt.Sprint(_xfs.Lineno_), Spec_: _xfs.Spec_, File_: _xfs.File_, Detail_: _detail}
+}
+
+// isSwap tells swap entries apart, they are checked against /proc/swaps rather than mounts
+func (self *Fstabdata) isSwap() bool { return (self.Vfstype_ == "swap") || (self.File_ == "swap") }
+
+// resolveSpec finds the device behind a spec, "" if none; LABEL matching allows for blkid's spaces-to-underscores
+func resolveSpec(_spec string, _blkid map[string]*blkid.Blkiddata) (string, bool) {
+	kk, vv := _spec, ""
+	if ii := strings.Index(_spec, "="); ii > 0 {
+		kk, vv = _spec[:ii], strings.Trim(_spec[ii+1:], `"`)
+	}
+	for devname, bi := range _blkid {
+		switch kk {
+		case "UUID":
+			if strings.EqualFold(bi.Uuid_, vv) {
+				return devname, true
+			}
+		case "LABEL":
+			if bi.Label_ == strings.Replace(vv, " ", "_", -1) {
+				return devname, true
+			}
+		case "PARTUUID":
+			if strings.EqualFold(bi.Partuuid_, vv) {
+				return devname, true
+			}
+		case "PARTLABEL":
+			if bi.Partlabel_ == strings.Replace(vv, " ", "_", -1) {
+				return devname, true
+			}
+		}
+	}
+	switch kk {
+	case "UUID", "LABEL", "PARTUUID", "PARTLABEL":
+		return "", true
+	}
+	return "", false
+}
+
+// Auditinput holds what an fstab is checked against, so the checks can be run on another box's data
+type Auditinput struct {
+	Mounts_ []*mounts.Mountdata         // what is mounted now, from mountinfo, pseudo filesystems included
+	Df_     map[string][]*df.Dfdata     // the real filesystems, classified, for the undeclared check
+	Blkid_  map[string]*blkid.Blkiddata // the block devices specs are resolved against
+	Swaps_  []string                    // active swap devices and files, from /proc/swaps
+	Units_  []*Fstabdata                // systemd .mount and .automount units, see Units; their mountpoints count as declared
+}
+
+// Audit cross-checks an fstab file against the live mounts, the block devices and the active swap devices of _in
+func Audit(_xf *Fstabfile, _in *Auditinput) []*Auditdata {
+	audits := []*Auditdata{}
+	mounted := map[string]*mounts.Mountdata{}
+	for _, md := range _in.Mounts_ {
+		mounted[md.Mountpoint_] = md
+	}
+	declared := map[string]*Fstabdata{}
+	for _, xfs := range _xf.Entries() {
+		_, noauto := xfs.Option("noauto")
+		_, nofail := xfs.Option("nofail")
+		severity := "error"
+		if noauto || nofail {
+			severity = "warn"
+		}
+
+		if prev := declared[xfs.Key()]; prev != nil {
+			audits = append(audits, newAudit("duplicate", "error", xfs, fmt.Sprintf("%s already declared on line%d", xfs.Key(), prev.Lineno_)))
+		}
+		declared[xfs.Key()] = xfs
+
+		devname, isTagged := resolveSpec(xfs.Spec_, _in.Blkid_)
+		switch {
+		case isTagged && (len(devname) == 0):
+			audits = append(audits, newAudit("unresolved", severity, xfs, "no block device has "+xfs.Spec_))
+		case strings.HasPrefix(xfs.Spec_, "/dev/"):
+			if _, err := os.Stat(xfs.Spec_); (err != nil) && (_in.Blkid_[xfs.Spec_] == nil) {
+				audits = append(audits, newAudit("unresolved", severity, xfs, xfs.Spec_+" does not exist"))
+			}
+			devname = xfs.Spec_
+		}
+
+		switch {
+		case xfs.isSwap():
+			active := false
+			for _, swap := range _in.Swaps_ {
+				active = active || (realPath(swap) == realPath(devname)) || (realPath(swap) == realPath(xfs.Spec_))
+			}
+			if !active && !noauto {
+				audits = append(audits, newAudit("notmounted", "warn", xfs, "swap is not active"))
+			}
+		case (mounted[xfs.File_] == nil) && !noauto:
+			audits = append(audits, newAudit("notmounted", "warn", xfs, "not mounted now, may fail at boot"))
+		}
+
+		passno := strings.TrimSpace(xfs.Passno_)
+		switch {
+		case noFsckFstypes[xfs.Vfstype_] && (passno != "0") && (len(passno) > 0):
+			audits = append(audits, newAudit("passno", "warn", xfs, fmt.Sprintf("passno %s but %s is not checked by fsck at boot, use 0", passno, xfs.Vfstype_)))
+		case fsckFstypes[xfs.Vfstype_] && (xfs.File_ == "/") && (passno != "1"):
+			audits = append(audits, newAudit("passno", "warn", xfs, fmt.Sprintf("passno %s for the root filesystem, use 1", passno)))
+		case fsckFstypes[xfs.Vfstype_] && (xfs.File_ != "/") && (passno == "1"):
+			audits = append(audits, newAudit("passno", "info", xfs, "passno 1 is meant for the root filesystem, use 2"))
+		case fsckFstypes[xfs.Vfstype_] && (passno == "0"):
+			audits = append(audits, newAudit("passno", "info", xfs, fmt.Sprintf("passno 0, %s is never checked at boot", xfs.Vfstype_)))
+		}
+	}
+	units := map[string]bool{}
+	for _, xfs := range _in.Units_ {
+		units[xfs.File_] = true
+	}
+	for _, kk := range df.SortedKeys_String2PtrDfdata(&_in.Df_) {
+		for _, dfd := range _in.Df_[kk] {
+			if (dfd.Class_ == df.ClassPseudo) || (dfd.Class_ == df.ClassOverlay) || (declared[dfd.Mountpoint_] != nil) || units[dfd.Mountpoint_] {
+				continue
+			}
+			if underAny(dfd.Mountpoint_, runtimeDirs) {
+				continue
+			}
+			audits = append(audits, &Auditdata{Check_: "undeclared", Severity_: "info", Spec_: dfd.Name_, File_: dfd.Mountpoint_, Detail_: "mounted but not in fstab or a mount unit, will not come back after reboot"})
+		}
+	}
+	return audits
+}
+
+// underAny tells whether a path is one of _dirs or below one of them; /devdata is not below /dev
+func underAny(_path string, _dirs []string) bool {
+	for _, dir := range _dirs {
+		if (_path == dir) || strings.HasPrefix(_path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath follows symlinks such as /dev/mapper/vg-swap -> /dev/dm-1
+func realPath(_path string) string {
+	if target, err := filepath.EvalSymlinks(_path); err == nil {
+		return target
+	}
+	return _path
+}
+
+// readSwaps lists the active swap devices and files from /proc/swaps
+func readSwaps() []string {
+	swaps := []string{}
+	buf, err := ioutil.ReadFile(procSwaps)
+	if err != nil {
+		return swaps
+	}
+	for _, line := range strings.Split(string(buf), "\n")[1:] {
+		if items := strings.Fields(line); len(items) > 0 {
+			swaps = append(swaps, mounts.Unescape(items[0]))
+		}
+	}
+	return swaps
+}
+
+// FstabAudit audits /etc/fstab against this box
+func FstabAudit(_verbose bool) []*Auditdata {
+	xf, err := ReadFstab("/etc/fstab", _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("FstabAudit: %s\n", err)
+		}
+		return nil
+	}
+	in := &Auditinput{Df_: df.Df(false, _verbose), Blkid_: blkid.Blkid(_verbose), Swaps_: readSwaps(), Units_: Units(_verbose)}
+	if in.Mounts_, err = mounts.ReadMountinfo(procMountinfo, _verbose); err != nil {
+		if _verbose {
+			fmt.Printf("FstabAudit: %s\n", err)
+		}
+		return nil
+	}
+	audits := Audit(xf, in)
+	if _verbose {
+		for _, audit := range audits {
+			audit.Print()
+		}
+	}
+	return audits
+}
@@ -0,0 +1,103 @@
+package etcfstab
+
+import (
+	"github.com/LDCS/qslinux/blkid"
+	"github.com/LDCS/qslinux/df"
+	"github.com/LDCS/qslinux/mounts"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// auditMountinfo is the mountinfo of a small box: the pseudo filesystems df never lists, the root, /boot and an nfs home
+const auditMountinfo = `21 26 0:20 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+22 26 0:4 / /proc rw,nosuid,nodev,noexec,relatime shared:14 - proc proc rw
+24 21 0:22 / /dev/pts rw,nosuid,noexec,relatime shared:3 - devpts devpts rw,gid=5,mode=620,ptmxmode=000
+26 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+27 26 8:2 / /boot rw,relatime shared:28 - ext4 /dev/sda2 rw
+28 26 0:50 / /home rw,relatime shared:30 - nfs4 fs1:/home rw
+`
+
+var auditBlkid = map[string]*blkid.Blkiddata{
+	"/dev/sda1": {Devname_: "/dev/sda1", Uuid_: "0a1b2c3d-1111-2222-3333-444455556666", Type_: "ext4", Label_: "root"},
+	"/dev/sda2": {Devname_: "/dev/sda2", Uuid_: "0a1b2c3d-7777-8888-9999-aaaabbbbcccc", Type_: "ext4", Label_: "my_boot"},
+	"/dev/sda3": {Devname_: "/dev/sda3", Type_: "swap", Partuuid_: "5e6f7a8b-03"},
+}
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name  string
+		fstab string
+		swaps []string
+		want  []string // check:severity:line, sorted
+	}{
+		{"clean", `UUID=0a1b2c3d-1111-2222-3333-444455556666 / ext4 defaults 0 1
+LABEL="my boot" /boot ext4 defaults 0 2
+proc /proc proc defaults 0 0
+sysfs /sys sysfs defaults 0 0
+devpts /dev/pts devpts gid=5,mode=620 0 0
+fs1:/home /home nfs4 defaults 0 0
+PARTUUID=5E6F7A8B-03 none swap sw 0 0
+`, []string{"/dev/sda3"}, nil},
+		{"unresolved", `UUID=deadbeef-0000 /data ext4 defaults 0 2
+LABEL=gone /backup xfs nofail 0 0
+/dev/qslinux-no-such-disk /scratch ext4 noauto 0 2
+`, nil, []string{"notmounted:warn:1", "notmounted:warn:2", "unresolved:error:1", "unresolved:warn:2", "unresolved:warn:3"}},
+		{"duplicate", `/dev/sda1 / ext4 defaults 0 1
+/dev/sda2 /boot ext4 defaults 0 2
+UUID=0a1b2c3d-7777-8888-9999-aaaabbbbcccc /boot ext4 defaults 0 2
+`, nil, []string{"duplicate:error:3"}},
+		{"passno", `/dev/sda1 / ext4 defaults 0 2
+/dev/sda2 /boot ext4 defaults 0 1
+fs1:/home /home nfs4 defaults 0 2
+proc /proc proc defaults 0 0
+sysfs /sys sysfs defaults
+`, nil, []string{"passno:info:2", "passno:warn:1", "passno:warn:3"}},
+		{"passno 0 on ext4", "/dev/sda2 /boot ext4 defaults 0 0\n", nil, []string{"passno:info:1"}},
+		{"notmounted", `/dev/sda1 / ext4 defaults 0 1
+tmpfs /tmp tmpfs defaults 0 0
+/dev/sda2 /mnt/usb ext4 noauto 0 2
+/dev/sda2 /mnt/opt ext4 nofail 0 2
+`, nil, []string{"notmounted:warn:2", "notmounted:warn:4"}},
+		{"swap", `/dev/sda3 none swap sw 0 0
+/swapfile none swap sw 0 0
+/swap2 swap swap noauto 0 0
+`, []string{"/dev/sda3"}, []string{"notmounted:warn:2"}},
+	}
+	for _, tt := range tests {
+		in := &Auditinput{Mounts_: mounts.ParseMountinfo(auditMountinfo, false), Blkid_: auditBlkid, Swaps_: tt.swaps}
+		got := []string{}
+		for _, audit := range Audit(ParseFstab(tt.fstab, false), in) {
+			got = append(got, audit.Check_+":"+audit.Severity_+":"+audit.Lineno_)
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuditUndeclared(t *testing.T) {
+	xf := ParseFstab("/dev/sda1 / ext4 defaults 0 1\n", false)
+	in := &Auditinput{Df_: map[string][]*df.Dfdata{}, Units_: []*Fstabdata{{Spec_: "fs1:/srv", File_: "/srv/data", Vfstype_: "nfs", Source_: SourceUnit}}}
+	for _, mountpoint := range []string{"/", "/run/user/1000", "/dev/shm", "/sys/fs/cgroup", "/proc/fs/nfsd", "/run", "/devdata", "/sysadmin", "/proc_archive", "/runs", "/srv/data"} {
+		in.Df_[mountpoint] = []*df.Dfdata{{Name_: "/dev/sdb1", Mountpoint_: mountpoint, Class_: df.ClassPrimary}}
+		in.Mounts_ = append(in.Mounts_, &mounts.Mountdata{Mountpoint_: mountpoint})
+	}
+	in.Df_["/"][0].Name_ = "/dev/sda1"
+	got := map[string]bool{}
+	for _, audit := range Audit(xf, in) {
+		if audit.Check_ == "undeclared" {
+			got[audit.File_] = true
+		}
+	}
+	want := []string{"/devdata", "/sysadmin", "/proc_archive", "/runs"}
+	if len(got) != len(want) {
+		t.Errorf("undeclared = %v, want %v", got, want)
+	}
+	for _, mountpoint := range want {
+		if !got[mountpoint] {
+			t.Errorf("%s not reported as undeclared", mountpoint)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package etcfstab
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LDCS/qslinux/etcfile"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var wsRegexp = regexp.MustCompile(`[ \t]+`)
+
+// fields renders the six fields of an entry, escaping spec and file
+func (self *Fstabdata) fields() []string {
+	mntops := JoinOptions(self.Options_)
+	if len(mntops) == 0 {
+		mntops = strings.Replace(self.Mntops_, "|", ",", -1)
+	}
+	if len(mntops) == 0 {
+		mntops = "defaults"
+	}
+	freq, passno := self.Freq_, self.Passno_
+	if len(freq) == 0 {
+		freq = "0"
+	}
+	if len(passno) == 0 {
+		passno = "0"
+	}
+	return []string{Escape(self.Spec_), Escape(self.File_), self.Vfstype_, mntops, freq, passno}
+}
+
+// Line renders an entry as a tab separated fstab line
+func (self *Fstabdata) Line() string {
+	return strings.Join(self.fields(), "\t")
+}
+
+// relayout puts new fields into an existing line, keeping its indentation and the spacing between columns
+func relayout(_raw string, _fields []string) string {
+	seps := wsRegexp.FindAllString(_raw, -1)
+	lead := ""
+	if (len(seps) > 0) && strings.HasPrefix(_raw, seps[0]) {
+		lead, seps = seps[0], seps[1:]
+	}
+	out := lead
+	for ii, field := range _fields {
+		if ii > 0 {
+			if ii-1 < len(seps) {
+				out += seps[ii-1]
+			} else {
+				out += "\t"
+			}
+		}
+		out += field
+	}
+	return out
+}
+
+// String renders the whole file, untouched lines exactly as read
+func (self *Fstabfile) String() string {
+	if len(self.Lines_) == 0 {
+		return ""
+	}
+	raws := []string{}
+	for _, xl := range self.Lines_ {
+		raws = append(raws, xl.Raw_)
+	}
+	return strings.Join(raws, "\n") + "\n"
+}
+
+// Add appends an entry at the end of the file
+func (self *Fstabfile) Add(_xfs *Fstabdata) {
+	if len(_xfs.Options_) == 0 {
+		_xfs.Options_ = ParseOptions(strings.Replace(_xfs.Mntops_, "|", ",", -1))
+	}
+	_xfs.Mntops_ = strings.Replace(JoinOptions(_xfs.Options_), ",", "|", -1)
+	fields := _xfs.fields()
+	_xfs.Freq_, _xfs.Passno_ = fields[4], fields[5] // as written, 0 when omitted
+	_xfs.Lineno_ = len(self.Lines_) + 1
+	_xfs.Source_ = SourceFstab
+	self.Lines_ = append(self.Lines_, &Fstabline{Raw_: _xfs.Line(), Entry_: _xfs})
+}
+
+// Remove drops the entries whose Key (mountpoint, or spec for swap) matches, and returns how many went
+func (self *Fstabfile) Remove(_key string) int {
+	kept := []*Fstabline{}
+	for _, xl := range self.Lines_ {
+		if (xl.Entry_ != nil) && (xl.Entry_.Key() == _key) {
+			continue
+		}
+		kept = append(kept, xl)
+	}
+	removed := len(self.Lines_) - len(kept)
+	self.Lines_ = kept
+	self.renumber()
+	return removed
+}
+
+// Modify applies _fn to the entries whose Key matches and re-renders their lines; Options_ wins over Mntops_ if _fn changed both
+func (self *Fstabfile) Modify(_key string, _fn func(*Fstabdata)) error {
+	found := false
+	for _, xl := range self.Lines_ {
+		if (xl.Entry_ == nil) || (xl.Entry_.Key() != _key) {
+			continue
+		}
+		found = true
+		oldMntops := xl.Entry_.Mntops_
+		_fn(xl.Entry_)
+		if (xl.Entry_.Mntops_ != oldMntops) && (JoinOptions(xl.Entry_.Options_) == strings.Replace(oldMntops, "|", ",", -1)) {
+			xl.Entry_.Options_ = ParseOptions(strings.Replace(xl.Entry_.Mntops_, "|", ",", -1))
+		}
+		xl.Entry_.Mntops_ = strings.Replace(JoinOptions(xl.Entry_.Options_), ",", "|", -1)
+		xl.Raw_ = relayout(xl.Raw_, xl.Entry_.fields())
+	}
+	if !found {
+		return fmt.Errorf("etcfstab: no entry for %s", _key)
+	}
+	return nil
+}
+
+// SetOption adds or replaces an option, e.g. SetOption("noatime", "") or SetOption("uid", "500")
+func (self *Fstabdata) SetOption(_key, _value string) {
+	for _, opt := range self.Options_ {
+		if opt.Key_ == _key {
+			opt.Value_ = _value
+			return
+		}
+	}
+	self.Options_ = append(self.Options_, &Fstabopt{Key_: _key, Value_: _value})
+}
+
+// DelOption removes an option
+func (self *Fstabdata) DelOption(_key string) {
+	kept := []*Fstabopt{}
+	for _, opt := range self.Options_ {
+		if opt.Key_ != _key {
+			kept = append(kept, opt)
+		}
+	}
+	self.Options_ = kept
+}
+
+// renumber refreshes Lineno_ after lines were removed
+func (self *Fstabfile) renumber() {
+	for ii, xl := range self.Lines_ {
+		if xl.Entry_ != nil {
+			xl.Entry_.Lineno_ = ii + 1
+		}
+	}
+}
+
+// Validate checks what mount -a would trip over: unparsable lines, missing fields, relative mountpoints, bad numbers and duplicate mountpoints
+func (self *Fstabfile) Validate() error {
+	problems := []string{}
+	seen := map[string]int{}
+	for ii, xl := range self.Lines_ {
+		lineno := ii + 1
+		if len(xl.Error_) > 0 {
+			problems = append(problems, fmt.Sprintf("line%d: %s", lineno, xl.Error_))
+		}
+		xfs := xl.Entry_
+		if xfs == nil {
+			continue
+		}
+		if len(xfs.Spec_) == 0 {
+			problems = append(problems, fmt.Sprintf("line%d: empty spec", lineno))
+		}
+		if len(xfs.Vfstype_) == 0 {
+			problems = append(problems, fmt.Sprintf("line%d: empty vfstype", lineno))
+		}
+		if !strings.HasPrefix(xfs.File_, "/") && (xfs.Key() == xfs.File_) {
+			problems = append(problems, fmt.Sprintf("line%d: mountpoint %q is not absolute", lineno, xfs.File_))
+		}
+		if _, err := strconv.Atoi(xfs.Freq_); (len(xfs.Freq_) > 0) && (err != nil) {
+			problems = append(problems, fmt.Sprintf("line%d: freq %q is not a number", lineno, xfs.Freq_))
+		}
+		if _, err := strconv.Atoi(xfs.Passno_); (len(xfs.Passno_) > 0) && (err != nil) {
+			problems = append(problems, fmt.Sprintf("line%d: passno %q is not a number", lineno, xfs.Passno_))
+		}
+		if prev, ok := seen[xfs.Key()]; ok {
+			problems = append(problems, fmt.Sprintf("line%d: %s already declared on line%d", lineno, xfs.Key(), prev))
+		} else {
+			seen[xfs.Key()] = lineno
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("etcfstab: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Save validates the file and writes it back to Path_ with etcfile.Replace, after copying the old one to Path_.bak
+// It always returns the unified diff against what is on disk; with _dryRun nothing is written
+func (self *Fstabfile) Save(_dryRun, _verbose bool) (string, error) {
+	if len(self.Path_) == 0 {
+		return "", errors.New("etcfstab: Save needs a Path_")
+	}
+	old, err := ioutil.ReadFile(self.Path_)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	diff := UnifiedDiff(string(old), self.String(), self.Path_, self.Path_+".new")
+	if _verbose {
+		fmt.Print(diff)
+	}
+	if err := self.Validate(); err != nil {
+		return diff, err
+	}
+	if _dryRun || (len(diff) == 0) {
+		return diff, nil
+	}
+	if err := etcfile.Replace(self.Path_, []byte(self.String()), true); err != nil {
+		return diff, err
+	}
+	return diff, nil
+}
+
+// UnifiedDiff returns a diff -u style diff of two texts with 3 lines of context, "" if they are equal
+func UnifiedDiff(_old, _new, _oldName, _newName string) string {
+	if _old == _new {
+		return ""
+	}
+	aa := strings.SplitAfter(_old, "\n")
+	bb := strings.SplitAfter(_new, "\n")
+	if (len(aa) > 0) && (aa[len(aa)-1] == "") {
+		aa = aa[:len(aa)-1]
+	}
+	if (len(bb) > 0) && (bb[len(bb)-1] == "") {
+		bb = bb[:len(bb)-1]
+	}
+	// lcs[ii][jj] is the longest common subsequence of aa[ii:] and bb[jj:]
+	lcs := make([][]int, len(aa)+1)
+	for ii := range lcs {
+		lcs[ii] = make([]int, len(bb)+1)
+	}
+	for ii := len(aa) - 1; ii >= 0; ii-- {
+		for jj := len(bb) - 1; jj >= 0; jj-- {
+			if aa[ii] == bb[jj] {
+				lcs[ii][jj] = lcs[ii+1][jj+1] + 1
+			} else if lcs[ii+1][jj] >= lcs[ii][jj+1] {
+				lcs[ii][jj] = lcs[ii+1][jj]
+			} else {
+				lcs[ii][jj] = lcs[ii][jj+1]
+			}
+		}
+	}
+	type diffop struct {
+		kind byte // ' ', '-' or '+'
+		line string
+		ai   int // line index in aa of this or the next old line
+		bi   int
+	}
+	ops := []diffop{}
+	ii, jj := 0, 0
+	for (ii < len(aa)) || (jj < len(bb)) {
+		switch {
+		case (ii < len(aa)) && (jj < len(bb)) && (aa[ii] == bb[jj]):
+			ops = append(ops, diffop{' ', aa[ii], ii, jj})
+			ii++
+			jj++
+		case (ii < len(aa)) && ((jj == len(bb)) || (lcs[ii+1][jj] >= lcs[ii][jj+1])):
+			ops = append(ops, diffop{'-', aa[ii], ii, jj})
+			ii++
+		default:
+			ops = append(ops, diffop{'+', bb[jj], ii, jj})
+			jj++
+		}
+	}
+	const context = 3
+	out := fmt.Sprintf("--- %s\n+++ %s\n", _oldName, _newName)
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		// grow the hunk while changes are within 2*context lines of each other
+		beg := start - context
+		if beg < 0 {
+			beg = 0
+		}
+		end := start
+		for kk := start; kk < len(ops); kk++ {
+			if ops[kk].kind != ' ' {
+				end = kk
+			} else if kk-end > 2*context {
+				break
+			}
+		}
+		stop := end + context + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		nold, nnew := 0, 0
+		body := ""
+		for _, op := range ops[beg:stop] {
+			if op.kind != '+' {
+				nold++
+			}
+			if op.kind != '-' {
+				nnew++
+			}
+			line := op.line
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n\\ No newline at end of file\n"
+			}
+			body += string(op.kind) + line
+		}
+		oldStart, newStart := ops[beg].ai+1, ops[beg].bi+1
+		if nold == 0 {
+			oldStart--
+		}
+		if nnew == 0 {
+			newStart--
+		}
+		out += fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, nold, newStart, nnew) + body
+		start = stop
+	}
+	return out
+}
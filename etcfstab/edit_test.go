@@ -0,0 +1,212 @@
+package etcfstab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lines renders line<from> to line<to>, one per line
+func lines(_from, _to int) string {
+	out := ""
+	for ii := _from; ii <= _to; ii++ {
+		out += fmt.Sprintf("line%d\n", ii)
+	}
+	return out
+}
+
+// The expected diffs are what GNU diff -u --label old --label new prints
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", lines(1, 5), lines(1, 5), ""},
+		{"both empty", "", "", ""},
+		{"single change", lines(1, 10), lines(1, 4) + "LINE5\n" + lines(6, 10),
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n line2\n line3\n line4\n-line5\n+LINE5\n line6\n line7\n line8\n"},
+		{"append at end", lines(1, 5), lines(1, 6),
+			"--- old\n+++ new\n@@ -3,3 +3,4 @@\n line3\n line4\n line5\n+line6\n"},
+		{"insert at start", lines(1, 5), "line0\n" + lines(1, 5),
+			"--- old\n+++ new\n@@ -1,3 +1,4 @@\n+line0\n line1\n line2\n line3\n"},
+		{"delete at start", lines(1, 5), lines(2, 5),
+			"--- old\n+++ new\n@@ -1,4 +1,3 @@\n-line1\n line2\n line3\n line4\n"},
+		{"gap of 6 merges", lines(1, 15), lines(1, 2) + "X\n" + lines(4, 9) + "Y\n" + lines(11, 15),
+			"--- old\n+++ new\n@@ -1,13 +1,13 @@\n line1\n line2\n-line3\n+X\n line4\n line5\n line6\n line7\n line8\n line9\n-line10\n+Y\n line11\n line12\n line13\n"},
+		{"gap of 7 splits", lines(1, 16), lines(1, 2) + "X\n" + lines(4, 10) + "Y\n" + lines(12, 16),
+			"--- old\n+++ new\n@@ -1,6 +1,6 @@\n line1\n line2\n-line3\n+X\n line4\n line5\n line6\n@@ -8,7 +8,7 @@\n line8\n line9\n line10\n-line11\n+Y\n line12\n line13\n line14\n"},
+		{"from empty", "", lines(1, 2),
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+line1\n+line2\n"},
+		{"to empty", lines(1, 2), "",
+			"--- old\n+++ new\n@@ -1,2 +0,0 @@\n-line1\n-line2\n"},
+		{"no newline at end", "a\nb", "a\nc",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"},
+		{"newline added", "a\nb", "a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+	}
+	for _, tt := range tests {
+		if got := UnifiedDiff(tt.old, tt.new, "old", "new"); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// editFstab is laid out by hand, with aligned columns, a comment and a blank line
+const editFstab = `# /etc/fstab: static file system information.
+#
+# <file system>                           <mount point>  <type>  <options>          <dump>  <pass>
+UUID=0a1b2c3d-1111-2222-3333-444455556666  /              ext4    errors=remount-ro  0       1
+/dev/sda2                                 /boot          ext4    defaults           0       2
+
+  /dev/sdb1   /srv/my\040data   xfs   noatime   0   0
+/dev/sda3 none swap sw 0 0
+`
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(xf *Fstabfile) error
+		want map[int]string // changed lines by number from 1; every other line of editFstab must stay as it was
+		size int            // lines after the edit
+	}{
+		{"modify keeps columns", func(xf *Fstabfile) error {
+			return xf.Modify("/boot", func(xfs *Fstabdata) { xfs.SetOption("noatime", ""); xfs.Passno_ = "0" })
+		}, map[int]string{5: "/dev/sda2                                 /boot          ext4    defaults,noatime           0       0"}, 8},
+		{"modify keeps indent and escapes", func(xf *Fstabfile) error {
+			return xf.Modify("/srv/my data", func(xfs *Fstabdata) { xfs.DelOption("noatime"); xfs.SetOption("uid", "500") })
+		}, map[int]string{7: "  /dev/sdb1   /srv/my\\040data   xfs   uid=500   0   0"}, 8},
+		{"modify mntops", func(xf *Fstabfile) error {
+			return xf.Modify("/dev/sda3", func(xfs *Fstabdata) { xfs.Mntops_ = "sw|pri=10" })
+		}, map[int]string{8: "/dev/sda3 none swap sw,pri=10 0 0"}, 8},
+		{"modify missing", func(xf *Fstabfile) error {
+			if err := xf.Modify("/nosuch", func(xfs *Fstabdata) {}); err == nil {
+				return fmt.Errorf("no error")
+			}
+			return nil
+		}, map[int]string{}, 8},
+		{"add", func(xf *Fstabfile) error {
+			xf.Add(&Fstabdata{Spec_: "fs1:/home", File_: "/home/my disk", Vfstype_: "nfs4", Mntops_: "rw|_netdev"})
+			return nil
+		}, map[int]string{9: "fs1:/home\t/home/my\\040disk\tnfs4\trw,_netdev\t0\t0"}, 9},
+		{"remove", func(xf *Fstabfile) error {
+			if nn := xf.Remove("/boot"); nn != 1 {
+				return fmt.Errorf("removed %d", nn)
+			}
+			return nil
+		}, map[int]string{5: "", 6: "  /dev/sdb1   /srv/my\\040data   xfs   noatime   0   0", 7: "/dev/sda3 none swap sw 0 0"}, 7},
+	}
+	orig := strings.Split(strings.TrimSuffix(editFstab, "\n"), "\n")
+	for _, tt := range tests {
+		xf := ParseFstab(editFstab, false)
+		if err := tt.edit(xf); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		got := strings.Split(strings.TrimSuffix(xf.String(), "\n"), "\n")
+		if len(got) != tt.size {
+			t.Errorf("%s: got %d lines, want %d", tt.name, len(got), tt.size)
+			continue
+		}
+		for ii, line := range got {
+			want, changed := tt.want[ii+1]
+			if !changed {
+				want = orig[ii]
+			}
+			if line != want {
+				t.Errorf("%s: line%d is %q, want %q", tt.name, ii+1, line, want)
+			}
+		}
+		for ii, xfs := range xf.Entries() { // line numbers follow the edit, and the edited file parses back to the same entries
+			reparsed := ParseFstab(xf.String(), false).Entries()[ii]
+			if (reparsed.Lineno_ != xfs.Lineno_) || (reparsed.Sprint() != xfs.Sprint()) {
+				t.Errorf("%s: entry %d reparses as %s, want %s", tt.name, ii, reparsed.Sprint(), xfs.Sprint())
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		fstab string
+		want  string // "" for valid
+	}{
+		{"valid", editFstab, ""},
+		{"empty", "", ""},
+		{"too few fields", "/dev/sda1 /\n", "etcfstab: line1: 2 fields, need at least 4"},
+		{"too many fields", "/dev/sda1 / ext4 defaults 0 1 2\n", "etcfstab: line1: 7 fields, at most 6 allowed"},
+		{"relative mountpoint", "/dev/sda1 mnt ext4 defaults 0 2\n", `etcfstab: line1: mountpoint "mnt" is not absolute`},
+		{"swap needs no mountpoint", "/dev/sda3 none swap sw 0 0\n/dev/sda4 swap swap sw\n", ""},
+		{"bad numbers", "/dev/sda1 / ext4 defaults x 1\n/dev/sda2 /boot ext4 defaults 0 two\n", `etcfstab: line1: freq "x" is not a number; line2: passno "two" is not a number`},
+		{"duplicate", "# c\n/dev/sda1 /data ext4 defaults\n/dev/sdb1 /data xfs defaults\n", "etcfstab: line3: /data already declared on line2"},
+	}
+	for _, tt := range tests {
+		err := ParseFstab(tt.fstab, false).Validate()
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	xf := ParseFstab(editFstab, false)
+	xf.Add(&Fstabdata{File_: "/x", Vfstype_: "", Mntops_: "defaults"})
+	if err := xf.Validate(); (err == nil) || !strings.Contains(err.Error(), "line9: empty spec; line9: empty vfstype") {
+		t.Errorf("added entry: got %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcfstab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fstab")
+	tests := []struct {
+		name    string
+		dryRun  bool
+		edit    func(xf *Fstabfile)
+		wantErr bool
+		written bool // the file changed and the old one went to fstab.bak
+	}{
+		{"unchanged", false, func(xf *Fstabfile) {}, false, false},
+		{"dry run", true, func(xf *Fstabfile) { xf.Remove("/boot") }, false, false},
+		{"invalid", false, func(xf *Fstabfile) { xf.Add(&Fstabdata{Spec_: "/dev/sdc1", File_: "/boot", Vfstype_: "ext4"}) }, true, false},
+		{"save", false, func(xf *Fstabfile) { xf.Remove("/boot") }, false, true},
+	}
+	for _, tt := range tests {
+		os.Remove(path + ".bak")
+		if err := ioutil.WriteFile(path, []byte(editFstab), 0644); err != nil {
+			t.Fatal(err)
+		}
+		xf, err := ReadFstab(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.edit(xf)
+		diff, err := xf.Save(tt.dryRun, false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v", tt.name, err)
+		}
+		if diff != UnifiedDiff(editFstab, xf.String(), path, path+".new") {
+			t.Errorf("%s: diff %q", tt.name, diff)
+		}
+		buf, _ := ioutil.ReadFile(path)
+		bak, bakErr := ioutil.ReadFile(path + ".bak")
+		switch {
+		case tt.written && ((string(buf) != xf.String()) || (string(bak) != editFstab)):
+			t.Errorf("%s: file %q, backup %q", tt.name, buf, bak)
+		case !tt.written && ((string(buf) != editFstab) || !os.IsNotExist(bakErr)):
+			t.Errorf("%s: file touched: %q, backup %v", tt.name, buf, bakErr)
+		}
+	}
+	if _, err := (&Fstabfile{}).Save(false, false); err == nil {
+		t.Errorf("no Path_: no error")
+	}
+}
@@ -5,23 +5,25 @@ package etcfstab
 
 import (
 	"fmt"
-	"github.com/LDCS/genutil"
 	"sort"
 	"strings"
 )
 
 // Fstabdata holds fstab data
 type Fstabdata struct {
-	Spec_    string
-	File_    string
+	Spec_    string // e.g. UUID=..., octal escapes decoded
+	File_    string // e.g. /mnt/my disk, octal escapes decoded
 	Vfstype_ string
-	Mntops_  string
+	Mntops_  string // e.g. defaults|noatime, commas turned to pipes for csv
 	Freq_    string
 	Passno_  string
+	Source_  string      // fstab, unit or generator (systemd units, see Units)
+	Options_ []*Fstabopt // Mntops split into ordered options
+	Lineno_  int         // line in the fstab file, from 1
 }
 
 const (
-	names     = "Spec,File,Vfstype,Mntops,Freq,Passno"
+	names     = "Spec,File,Vfstype,Mntops,Freq,Passno,Source"
 	hdrprefix = ",xfs."
 	semi      = ";"
 )
@@ -72,7 +74,7 @@ func (self *Fstabdata) Csv() string {
 	if self == nil {
 		return commaString
 	}
-	return fmt.Sprintf(pctString, self.Spec_, self.File_, self.Vfstype_, self.Mntops_, self.Freq_, self.Passno_)
+	return fmt.Sprintf(pctString, strings.Replace(self.Spec_, ",", semi, -1), strings.Replace(self.File_, ",", semi, -1), self.Vfstype_, self.Mntops_, self.Freq_, self.Passno_, self.Source_)
 }
 
 // Sprint is generic
@@ -80,7 +82,7 @@ func (self *Fstabdata) Sprint() string {
 	if self == nil {
 		return ""
 	}
-	return fmt.Sprintf(namePctString, self.Spec_, self.File_, self.Vfstype_, self.Mntops_, self.Freq_, self.Passno_)
+	return fmt.Sprintf(namePctString, self.Spec_, self.File_, self.Vfstype_, self.Mntops_, self.Freq_, self.Passno_, self.Source_)
 }
 
 // Print is generic
@@ -94,33 +96,20 @@ func (self *Fstabdata) Print() {
 // New is generic
 func New() *Fstabdata { return new(Fstabdata) }
 
-// Fstab extracts fstab data
+// Fstab extracts fstab data, keyed by mountpoint (by spec for swap)
 func Fstab(_verbose bool) (smap map[string]*Fstabdata) {
 	smap = make(map[string]*Fstabdata)
-	out := genutil.BashExecOrDie(_verbose, "/bin/cat /etc/fstab| sed -e 's/,/|/g'", ".")
-	if _verbose {
-		fmt.Println(out)
-	}
-	lines := genutil.CleanAndSplitOnSpaces(out, ",")
-	for ii, line := range lines {
-		items := strings.Split(line, ",")
-		num := len(items)
-		if num < 6 {
-			continue
-		}
-		if items[0] == "#" {
-			continue
+	xf, err := ReadFstab("/etc/fstab", _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Fstab: %s\n", err)
 		}
-		lastxfs := new(Fstabdata)
-		lastxfs.Spec_ = items[0]
-		lastxfs.File_ = items[1]
-		lastxfs.Vfstype_ = items[2]
-		lastxfs.Mntops_ = items[3]
-		lastxfs.Freq_ = items[4]
-		lastxfs.Passno_ = items[5]
-		smap[lastxfs.Spec_] = lastxfs
+		return smap
+	}
+	for _, xfs := range xf.Entries() {
+		smap[xfs.Key()] = xfs
 		if _verbose {
-			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
+			fmt.Printf("line%d: key(%s) %s", xfs.Lineno_, xfs.Key(), xfs.Sprint())
 		}
 	}
 	return smap
@@ -0,0 +1,179 @@
+package etcfstab
+
+import (
+	"fmt"
+	"github.com/LDCS/qslinux/mounts"
+	"io/ioutil"
+	"strings"
+)
+
+// Fstabopt is one mount option, e.g. noatime or uid=500
+type Fstabopt struct {
+	Key_   string
+	Value_ string // empty for flags such as noatime
+}
+
+// Fstabline is one line of an fstab file, kept verbatim so the file can be written back unchanged
+type Fstabline struct {
+	Raw_   string     // the line as read, without its newline
+	Entry_ *Fstabdata // nil for comments, blank lines and lines that failed to parse
+	Error_ string     // why a non-comment line did not parse
+}
+
+// Fstabfile holds a whole fstab file in order
+type Fstabfile struct {
+	Path_  string
+	Lines_ []*Fstabline
+}
+
+// Escape is the reverse of mounts.Unescape, for the characters that would otherwise split a field
+func Escape(_str string) string {
+	out := []byte{}
+	for ii := 0; ii < len(_str); ii++ {
+		switch cc := _str[ii]; cc {
+		case ' ', '\t', '\n', '\\':
+			out = append(out, []byte(fmt.Sprintf(`\%03o`, cc))...)
+		default:
+			out = append(out, cc)
+		}
+	}
+	return string(out)
+}
+
+// ParseOptions splits a mntops field into its ordered options
+func ParseOptions(_mntops string) []*Fstabopt {
+	opts := []*Fstabopt{}
+	for _, item := range strings.Split(_mntops, ",") {
+		if len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		opt := &Fstabopt{Key_: kv[0]}
+		if len(kv) > 1 {
+			opt.Value_ = kv[1]
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+// JoinOptions is the reverse of ParseOptions
+func JoinOptions(_opts []*Fstabopt) string {
+	items := []string{}
+	for _, opt := range _opts {
+		if len(opt.Value_) > 0 {
+			items = append(items, opt.Key_+"="+opt.Value_)
+		} else {
+			items = append(items, opt.Key_)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Option returns the value of an option and whether it is present
+func (self *Fstabdata) Option(_key string) (string, bool) {
+	for _, opt := range self.Options_ {
+		if opt.Key_ == _key {
+			return opt.Value_, true
+		}
+	}
+	return "", false
+}
+
+// parseEntry parses a non-comment fstab line; freq and passno default to 0 when omitted, as mount does
+func parseEntry(_line string) (*Fstabdata, string) {
+	items := strings.Fields(_line)
+	if len(items) < 4 {
+		return nil, fmt.Sprintf("%d fields, need at least 4", len(items))
+	}
+	if len(items) > 6 {
+		return nil, fmt.Sprintf("%d fields, at most 6 allowed", len(items))
+	}
+	for len(items) < 6 {
+		items = append(items, "0")
+	}
+	xfs := new(Fstabdata)
+	xfs.Spec_ = mounts.Unescape(items[0])
+	xfs.File_ = mounts.Unescape(items[1])
+	xfs.Vfstype_ = items[2]
+	xfs.Options_ = ParseOptions(items[3])
+	xfs.Mntops_ = strings.Replace(items[3], ",", "|", -1)
+	xfs.Freq_ = items[4]
+	xfs.Passno_ = items[5]
+	xfs.Source_ = SourceFstab
+	return xfs, ""
+}
+
+// ParseFstab parses fstab text, keeping every line in order
+func ParseFstab(_text string, _verbose bool) *Fstabfile {
+	xf := new(Fstabfile)
+	lines := strings.Split(strings.TrimSuffix(_text, "\n"), "\n")
+	if (len(lines) == 1) && (len(lines[0]) == 0) {
+		lines = nil
+	}
+	for ii, line := range lines {
+		xl := &Fstabline{Raw_: line}
+		trimmed := strings.TrimSpace(line)
+		if (len(trimmed) > 0) && !strings.HasPrefix(trimmed, "#") {
+			xl.Entry_, xl.Error_ = parseEntry(trimmed)
+			if xl.Entry_ != nil {
+				xl.Entry_.Lineno_ = ii + 1
+			}
+			if _verbose && (len(xl.Error_) > 0) {
+				fmt.Printf("line%d: %s: %s\n", ii+1, xl.Error_, line)
+			}
+		}
+		xf.Lines_ = append(xf.Lines_, xl)
+	}
+	return xf
+}
+
+// ReadFstab reads and parses an fstab file
+func ReadFstab(_path string, _verbose bool) (*Fstabfile, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	xf := ParseFstab(string(buf), _verbose)
+	xf.Path_ = _path
+	return xf, nil
+}
+
+// Entries lists the entries in file order
+func (self *Fstabfile) Entries() []*Fstabdata {
+	entries := []*Fstabdata{}
+	for _, xl := range self.Lines_ {
+		if xl.Entry_ != nil {
+			entries = append(entries, xl.Entry_)
+		}
+	}
+	return entries
+}
+
+// LookupAll lists the entries mounted on a mountpoint, normally one
+func (self *Fstabfile) LookupAll(_mountpoint string) []*Fstabdata {
+	entries := []*Fstabdata{}
+	for _, xfs := range self.Entries() {
+		if xfs.File_ == _mountpoint {
+			entries = append(entries, xfs)
+		}
+	}
+	return entries
+}
+
+// Lookup returns the entry for a mountpoint, the last one if there are several since that is the one left visible by mount -a
+func (self *Fstabfile) Lookup(_mountpoint string) *Fstabdata {
+	entries := self.LookupAll(_mountpoint)
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[len(entries)-1]
+}
+
+// Key is the mountpoint of an entry, or its spec for swap entries, which all share the mountpoint none
+func (self *Fstabdata) Key() string {
+	if (self.File_ == "none") || (self.File_ == "swap") || (self.Vfstype_ == "swap") {
+		return self.Spec_
+	}
+	return self.File_
+}
@@ -0,0 +1,131 @@
+package etcfstab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFstab(t *testing.T) {
+	text := `# /etc/fstab
+UUID=0a1b2c3d / ext4 defaults,noatime 1 1
+
+/dev/sdb1 /mnt/my\040disk xfs defaults 0 2
+LABEL=my\040data /data ext4 defaults
+  # indented comment
+/dev/sdc1 /opt ext4 defaults 0
+/dev/sdd1 /srv
+/dev/sde1 /srv ext4 defaults 0 2 extra
+/swapfile none swap sw 0 0
+	tmpfs	/tmp	tmpfs	size=2g,mode=1777	0	0
+`
+	xf := ParseFstab(text, false)
+	if got := len(xf.Lines_); got != 11 {
+		t.Fatalf("got %d lines, want 11", got)
+	}
+	for ii, raw := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if xf.Lines_[ii].Raw_ != raw {
+			t.Errorf("line%d: Raw_ %q, want %q", ii+1, xf.Lines_[ii].Raw_, raw)
+		}
+	}
+	tests := []struct {
+		lineno                                    int
+		spec, file, vfstype, mntops, freq, passno string
+		err                                       string // part of Error_ for a line that does not parse
+	}{
+		{1, "", "", "", "", "", "", ""}, // comment
+		{2, "UUID=0a1b2c3d", "/", "ext4", "defaults|noatime", "1", "1", ""},
+		{3, "", "", "", "", "", "", ""}, // blank
+		{4, "/dev/sdb1", "/mnt/my disk", "xfs", "defaults", "0", "2", ""},
+		{5, "LABEL=my data", "/data", "ext4", "defaults", "0", "0", ""},
+		{6, "", "", "", "", "", "", ""},
+		{7, "/dev/sdc1", "/opt", "ext4", "defaults", "0", "0", ""},
+		{8, "", "", "", "", "", "", "2 fields, need at least 4"},
+		{9, "", "", "", "", "", "", "7 fields, at most 6 allowed"},
+		{10, "/swapfile", "none", "swap", "sw", "0", "0", ""},
+		{11, "tmpfs", "/tmp", "tmpfs", "size=2g|mode=1777", "0", "0", ""},
+	}
+	for _, tt := range tests {
+		xl := xf.Lines_[tt.lineno-1]
+		if len(tt.err) > 0 {
+			if (xl.Entry_ != nil) || !strings.Contains(xl.Error_, tt.err) {
+				t.Errorf("line%d: entry %+v, error %q, want %s", tt.lineno, xl.Entry_, xl.Error_, tt.err)
+			}
+			continue
+		}
+		if len(tt.spec) == 0 {
+			if (xl.Entry_ != nil) || (len(xl.Error_) > 0) {
+				t.Errorf("line%d: a comment or blank line parsed as %+v %q", tt.lineno, xl.Entry_, xl.Error_)
+			}
+			continue
+		}
+		xfs := xl.Entry_
+		if xfs == nil {
+			t.Errorf("line%d: %s", tt.lineno, xl.Error_)
+			continue
+		}
+		if (xfs.Spec_ != tt.spec) || (xfs.File_ != tt.file) || (xfs.Vfstype_ != tt.vfstype) || (xfs.Mntops_ != tt.mntops) || (xfs.Freq_ != tt.freq) || (xfs.Passno_ != tt.passno) {
+			t.Errorf("line%d: got %s", tt.lineno, xfs.Sprint())
+		}
+		if (xfs.Lineno_ != tt.lineno) || (xfs.Source_ != SourceFstab) {
+			t.Errorf("line%d: Lineno_ %d Source_ %s", tt.lineno, xfs.Lineno_, xfs.Source_)
+		}
+	}
+	if got := len(xf.Entries()); got != 6 {
+		t.Errorf("got %d entries, want 6", got)
+	}
+	if got := xf.Lines_[10].Entry_; got.Options_[0].Key_ != "size" || got.Options_[0].Value_ != "2g" {
+		t.Errorf("options %+v", got.Options_[0])
+	}
+	if got := xf.Lines_[9].Entry_.Key(); got != "/swapfile" {
+		t.Errorf("swap Key = %s", got)
+	}
+	if got := len(ParseFstab("", false).Lines_); got != 0 {
+		t.Errorf("empty text: %d lines", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	xf := ParseFstab(`/dev/sda1 /data ext4 defaults 0 2
+/dev/sdb1 /srv xfs defaults 0 2
+/dev/sdc1 /data xfs noauto 0 0
+`, false)
+	if got := len(xf.LookupAll("/data")); got != 2 {
+		t.Errorf("LookupAll = %d entries, want 2", got)
+	}
+	if got := xf.Lookup("/data"); (got == nil) || (got.Spec_ != "/dev/sdc1") || (got.Lineno_ != 3) {
+		t.Errorf("Lookup = %+v, want the last line", got)
+	}
+	if got := xf.Lookup("/nope"); got != nil {
+		t.Errorf("Lookup(/nope) = %+v", got)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/mnt/plain", "/mnt/plain"},
+		{"/mnt/my disk", `/mnt/my\040disk`},
+		{"a\tb\nc\\d", `a\011b\012c\134d`},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		xf := ParseFstab(Escape(tt.in)+" /x ext4 defaults 0 0\n", false)
+		if got := xf.Entries()[0].Spec_; got != tt.in {
+			t.Errorf("round trip of %q gave %q", tt.in, got)
+		}
+	}
+}
+
+func TestCsv(t *testing.T) {
+	xfs := ParseFstab(`server:/export,v2 /mnt/a,b nfs rw,hard 0 0`, false).Entries()[0]
+	csv := xfs.Csv()
+	if strings.Count(csv, ",") != strings.Count(Header(), ",") {
+		t.Fatalf("Csv %q does not match the header %q", csv, Header())
+	}
+	if !strings.HasPrefix(csv, "server:/export;v2,/mnt/a;b,nfs,rw|hard,") {
+		t.Errorf("Csv = %q", csv)
+	}
+}
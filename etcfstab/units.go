@@ -0,0 +1,201 @@
+package etcfstab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Values of Fstabdata.Source_
+const (
+	SourceFstab     = "fstab"     // a line of /etc/fstab
+	SourceUnit      = "unit"      // a .mount or .automount unit written by an admin or a package
+	SourceGenerator = "generator" // a unit generated at boot, mostly by systemd-fstab-generator
+)
+
+// unitDirs are searched in systemd's order of precedence, a unit name found earlier hides the same name later
+var unitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/run/systemd/generator.early",
+	"/run/systemd/generator",
+	"/run/systemd/generator.late",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// parseUnit reads the key=value pairs of a unit file section by section, e.g. items["Mount"]["Where"]
+// Continuation lines are joined; for repeated keys the last one wins
+func parseUnit(_text string) map[string]map[string]string {
+	items := map[string]map[string]string{}
+	section := ""
+	pending := ""
+	for _, lineraw := range strings.Split(_text, "\n") {
+		line := strings.TrimSpace(lineraw)
+		if len(pending) > 0 {
+			line = pending + " " + line
+			pending = ""
+		}
+		if strings.HasSuffix(line, `\`) {
+			pending = strings.TrimSuffix(line, `\`)
+			continue
+		}
+		switch {
+		case (len(line) == 0) || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = line[1 : len(line)-1]
+			if items[section] == nil {
+				items[section] = map[string]string{}
+			}
+		case len(section) > 0:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) == 2 {
+				items[section][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		}
+	}
+	return items
+}
+
+// mergeUnit applies the settings of a drop-in onto a parsed unit, a setting in the drop-in replacing the unit's
+func mergeUnit(_items, _dropin map[string]map[string]string) {
+	for section, kvs := range _dropin {
+		if _items[section] == nil {
+			_items[section] = map[string]string{}
+		}
+		for kk, vv := range kvs {
+			_items[section][kk] = vv
+		}
+	}
+}
+
+// dropins lists the <unit>.d/*.conf drop-ins of a unit in the order systemd applies them: by file name across all dirs,
+// a drop-in of one name in an earlier dir hiding the same name in later ones
+func dropins(_dirs []string, _name string) []string {
+	byName := map[string]string{}
+	for _, dir := range _dirs {
+		paths, _ := filepath.Glob(filepath.Join(dir, _name+".d", "*.conf"))
+		for _, path := range paths {
+			if len(byName[filepath.Base(path)]) == 0 {
+				byName[filepath.Base(path)] = path
+			}
+		}
+	}
+	names := []string{}
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paths := []string{}
+	for _, name := range names {
+		paths = append(paths, byName[name])
+	}
+	return paths
+}
+
+// unitToFstabdata turns a parsed .mount or .automount unit into an fstab style record
+func unitToFstabdata(_name, _path string, _items map[string]map[string]string) *Fstabdata {
+	xfs := new(Fstabdata)
+	xfs.Freq_, xfs.Passno_ = "0", "0"
+	xfs.Source_ = SourceUnit
+	if strings.HasPrefix(_path, "/run/systemd/generator") {
+		xfs.Source_ = SourceGenerator
+	}
+	switch {
+	case strings.HasSuffix(_name, ".mount"):
+		mount := _items["Mount"]
+		if mount == nil {
+			return nil
+		}
+		xfs.Spec_, xfs.File_, xfs.Vfstype_ = mount["What"], mount["Where"], mount["Type"]
+		xfs.Options_ = ParseOptions(mount["Options"])
+	case strings.HasSuffix(_name, ".automount"):
+		automount := _items["Automount"]
+		if automount == nil {
+			return nil
+		}
+		xfs.Spec_, xfs.File_, xfs.Vfstype_ = strings.TrimSuffix(_name, ".automount")+".mount", automount["Where"], "autofs"
+		if timeout := automount["TimeoutIdleSec"]; len(timeout) > 0 {
+			xfs.Options_ = []*Fstabopt{{Key_: "x-systemd.idle-timeout", Value_: timeout}}
+		}
+	default:
+		return nil
+	}
+	xfs.Mntops_ = strings.Replace(JoinOptions(xfs.Options_), ",", "|", -1)
+	if len(xfs.Mntops_) == 0 {
+		xfs.Mntops_ = "defaults"
+	}
+	return xfs
+}
+
+// UnitsFromDirs reads the .mount and .automount units of a list of dirs, in order of precedence, with their drop-ins applied
+// (e.g. What= or Options= overridden in /etc/systemd/system/data.mount.d/override.conf)
+func UnitsFromDirs(_dirs []string, _verbose bool) []*Fstabdata {
+	seen := map[string]bool{}
+	units := []*Fstabdata{}
+	for _, dir := range _dirs {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.mount"))
+		autopaths, _ := filepath.Glob(filepath.Join(dir, "*.automount"))
+		paths = append(paths, autopaths...)
+		sort.Strings(paths)
+		for _, path := range paths {
+			name := filepath.Base(path)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			buf, err := ioutil.ReadFile(path) // follows the symlinks systemctl enable and generators create
+			if err != nil {
+				if _verbose {
+					fmt.Printf("UnitsFromDirs: %s\n", err)
+				}
+				continue
+			}
+			items := parseUnit(string(buf))
+			for _, dropin := range dropins(_dirs, name) {
+				if len(items) == 0 {
+					break // masked, i.e. linked to /dev/null: systemd ignores its drop-ins
+				}
+				dbuf, err := ioutil.ReadFile(dropin)
+				if err != nil {
+					if _verbose {
+						fmt.Printf("UnitsFromDirs: %s\n", err)
+					}
+					continue
+				}
+				mergeUnit(items, parseUnit(string(dbuf)))
+			}
+			xfs := unitToFstabdata(name, path, items)
+			if xfs == nil {
+				if _verbose {
+					fmt.Printf("UnitsFromDirs: %s has no [Mount] or [Automount] section\n", path)
+				}
+				continue
+			}
+			units = append(units, xfs)
+			if _verbose {
+				fmt.Printf("unit %s: %s", path, xfs.Sprint())
+			}
+		}
+	}
+	return units
+}
+
+// Units lists the systemd mount and automount units of this box
+func Units(_verbose bool) []*Fstabdata {
+	return UnitsFromDirs(unitDirs, _verbose)
+}
+
+// Inventory lists the /etc/fstab entries followed by the systemd units, so one csv shows every declared mount and where it comes from
+func Inventory(_verbose bool) []*Fstabdata {
+	entries := []*Fstabdata{}
+	if xf, err := ReadFstab("/etc/fstab", _verbose); err == nil {
+		entries = append(entries, xf.Entries()...)
+	} else if _verbose {
+		fmt.Printf("Inventory: %s\n", err)
+	}
+	return append(entries, Units(_verbose)...)
+}
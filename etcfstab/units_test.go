@@ -0,0 +1,180 @@
+package etcfstab
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseUnit(t *testing.T) {
+	text := `# data.mount
+Where=/ignored
+[Unit]
+Description=Data\
+  disk
+; a comment
+[Mount]
+What=/dev/sdb1
+Where = /data
+Type=xfs
+Options=noatime,\
+	nodev
+# Options=ro
+Options=noatime,nodev,\
+nosuid
+
+[Install]
+WantedBy=local-fs.target
+`
+	items := parseUnit(text)
+	tests := []struct {
+		section, key, want string
+	}{
+		{"Unit", "Description", "Data disk"},
+		{"Mount", "What", "/dev/sdb1"},
+		{"Mount", "Where", "/data"},
+		{"Mount", "Type", "xfs"},
+		{"Mount", "Options", "noatime,nodev, nosuid"}, // the last one wins, not the commented one
+		{"Install", "WantedBy", "local-fs.target"},
+	}
+	for _, tt := range tests {
+		if got := items[tt.section][tt.key]; got != tt.want {
+			t.Errorf("[%s] %s = %q, want %q", tt.section, tt.key, got, tt.want)
+		}
+	}
+	if len(items) != 3 {
+		t.Errorf("got sections %v, want Unit, Mount and Install", items)
+	}
+	if got := len(parseUnit("")); got != 0 {
+		t.Errorf("empty unit: %d sections", got)
+	}
+}
+
+func TestUnitToFstabdata(t *testing.T) {
+	tests := []struct {
+		name, path, text                    string
+		spec, file, vfstype, mntops, source string // spec "" for nil
+	}{
+		{"data.mount", "/etc/systemd/system/data.mount", "[Mount]\nWhat=/dev/sdb1\nWhere=/data\nType=xfs\nOptions=noatime,uid=500\n",
+			"/dev/sdb1", "/data", "xfs", "noatime|uid=500", SourceUnit},
+		{"data.mount", "/run/systemd/generator/data.mount", "[Mount]\nWhat=/dev/sdb1\nWhere=/data\n",
+			"/dev/sdb1", "/data", "", "defaults", SourceGenerator},
+		{"data.mount", "/run/systemd/generator.late/data.mount", "[Mount]\nWhat=/dev/sdb1\nWhere=/data\n",
+			"/dev/sdb1", "/data", "", "defaults", SourceGenerator},
+		{"data.mount", "/run/systemd/system/data.mount", "[Mount]\nWhat=/dev/sdb1\nWhere=/data\n",
+			"/dev/sdb1", "/data", "", "defaults", SourceUnit},
+		{"net-home.automount", "/etc/systemd/system/net-home.automount", "[Automount]\nWhere=/net/home\nTimeoutIdleSec=600\n",
+			"net-home.mount", "/net/home", "autofs", "x-systemd.idle-timeout=600", SourceUnit},
+		{"net-home.automount", "/usr/lib/systemd/system/net-home.automount", "[Automount]\nWhere=/net/home\n",
+			"net-home.mount", "/net/home", "autofs", "defaults", SourceUnit},
+		{"data.mount", "/etc/systemd/system/data.mount", "[Unit]\nDescription=no mount section\n", "", "", "", "", ""},
+		{"net-home.automount", "/etc/systemd/system/net-home.automount", "[Mount]\nWhere=/net/home\n", "", "", "", "", ""},
+		{"data.service", "/etc/systemd/system/data.service", "[Mount]\nWhere=/data\n", "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		xfs := unitToFstabdata(tt.name, tt.path, parseUnit(tt.text))
+		if len(tt.spec) == 0 {
+			if xfs != nil {
+				t.Errorf("%s: got %s, want nil", tt.path, xfs.Sprint())
+			}
+			continue
+		}
+		if xfs == nil {
+			t.Errorf("%s: got nil", tt.path)
+			continue
+		}
+		if (xfs.Spec_ != tt.spec) || (xfs.File_ != tt.file) || (xfs.Vfstype_ != tt.vfstype) || (xfs.Mntops_ != tt.mntops) || (xfs.Source_ != tt.source) {
+			t.Errorf("%s: got %s", tt.path, xfs.Sprint())
+		}
+		if (xfs.Freq_ != "0") || (xfs.Passno_ != "0") {
+			t.Errorf("%s: freq %s passno %s", tt.path, xfs.Freq_, xfs.Passno_)
+		}
+	}
+}
+
+func TestUnitsFromDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "etcfstab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	etc, lib := filepath.Join(root, "etc"), filepath.Join(root, "lib")
+	files := map[string]string{
+		"lib/data.mount":                     "[Mount]\nWhat=/dev/sdb1\nWhere=/data\nType=xfs\nOptions=defaults\n",
+		"lib/data.mount.d/10-opts.conf":      "[Mount]\nOptions=noatime\n",
+		"lib/data.mount.d/20-what.conf":      "[Mount]\nWhat=/dev/sdz9\n",
+		"etc/data.mount.d/20-what.conf":      "[Mount]\nWhat=LABEL=data\n", // hides the lib one of the same name
+		"etc/data.mount.d/30-opts.conf":      "[Mount]\nOptions=noatime,nodev\n",
+		"etc/data.mount.d/notaconf":          "[Mount]\nWhat=/dev/wrong\n",
+		"lib/srv.mount":                      "[Mount]\nWhat=/dev/sdc1\nWhere=/srv\n",
+		"etc/srv.mount":                      "[Mount]\nWhat=/dev/sdd1\nWhere=/srv\n", // hides the lib unit
+		"lib/net-home.automount":             "[Automount]\nWhere=/net/home\n",
+		"lib/net-home.automount.d/idle.conf": "[Automount]\nTimeoutIdleSec=300\n",
+		"lib/broken.mount":                   "[Unit]\nDescription=no mount section\n",
+		"lib/masked.mount":                   "[Mount]\nWhat=/dev/sde1\nWhere=/masked\n",
+		"etc/masked.mount.d/override.conf":   "[Mount]\nOptions=ro\n",
+		"lib/sshd.service":                   "[Service]\nExecStart=/usr/sbin/sshd\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/dev/null", filepath.Join(etc, "masked.mount")); err != nil {
+		t.Fatal(err)
+	}
+	units := UnitsFromDirs([]string{etc, lib}, false)
+	byFile := map[string]*Fstabdata{}
+	for _, xfs := range units {
+		byFile[xfs.File_] = xfs
+	}
+	if len(units) != 3 {
+		t.Errorf("got %d units, want 3: %v", len(units), byFile)
+	}
+	tests := []struct {
+		file, spec, mntops string
+	}{
+		{"/data", "LABEL=data", "noatime|nodev"},
+		{"/srv", "/dev/sdd1", "defaults"},
+		{"/net/home", "net-home.mount", "x-systemd.idle-timeout=300"},
+	}
+	for _, tt := range tests {
+		xfs := byFile[tt.file]
+		if (xfs == nil) || (xfs.Spec_ != tt.spec) || (xfs.Mntops_ != tt.mntops) {
+			t.Errorf("%s: got %+v, want %s %s", tt.file, xfs, tt.spec, tt.mntops)
+		}
+	}
+	if byFile["/masked"] != nil {
+		t.Errorf("a masked unit came back through its drop-in: %+v", byFile["/masked"])
+	}
+}
+
+func TestDropins(t *testing.T) {
+	root, err := ioutil.TempDir("", "etcfstab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{"a/x.mount.d/b.conf", "b/x.mount.d/a.conf", "b/x.mount.d/b.conf", "b/x.mount.d/c.conf", "b/y.mount.d/a.conf"} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := dropins([]string{filepath.Join(root, "a"), filepath.Join(root, "b")}, "x.mount")
+	want := []string{"b/x.mount.d/a.conf", "a/x.mount.d/b.conf", "b/x.mount.d/c.conf"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for ii := range want {
+		if got[ii] != filepath.Join(root, want[ii]) {
+			t.Errorf("dropin%d: got %s, want %s", ii, got[ii], want[ii])
+		}
+	}
+}
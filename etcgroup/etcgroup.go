@@ -0,0 +1,224 @@
+// Package etcgroup extracts useful info from /etc/group and /etc/gshadow on linux
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package etcgroup
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Groupdata holds etc group data, with the gshadow line of the group if any
+type Groupdata struct {
+	Groupname_ string
+	Gid_       string
+	Pwinfo_    string // x, empty, or the length of a group password
+	Members_   string // supplementary members, pipe separated
+	Admins_    string // gshadow administrators, pipe separated
+	Gsmembers_ string // gshadow members, pipe separated; normally equal to Members_
+	Ingshadow_ string // yes if gshadow has a line for the group
+	Lineno_    int    // line in /etc/group, from 1
+}
+
+const (
+	names     = "Groupname,Gid,Pwinfo,Members,Admins,Gsmembers,Ingshadow"
+	hdrprefix = ",xgr."
+	semi      = ";"
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+	etcGroup      = "/etc/group"
+	etcGshadow    = "/etc/gshadow"
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// SortedKeys_String2PtrGroupdata is generic
+func SortedKeys_String2PtrGroupdata(_mp *map[string]*Groupdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys_String2PtrGroupdata is generic
+func Keys_String2PtrGroupdata(_mp *map[string]*Groupdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	return keys
+}
+
+// Header is generic
+func Header() string { return headerString }
+
+// Csv is generic
+func (self *Groupdata) Csv() string {
+	if self == nil {
+		return commaString
+	}
+	return fmt.Sprintf(pctString, self.Groupname_, self.Gid_, self.Pwinfo_, self.Members_, self.Admins_, self.Gsmembers_, self.Ingshadow_)
+}
+
+// Sprint is generic
+func (self *Groupdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctString, self.Groupname_, self.Gid_, self.Pwinfo_, self.Members_, self.Admins_, self.Gsmembers_, self.Ingshadow_)
+}
+
+// Print is generic
+func (self *Groupdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// New is generic
+func New() *Groupdata { return new(Groupdata) }
+
+// memberList turns a comma separated member list into a pipe separated one
+func memberList(_str string) string {
+	members := []string{}
+	for _, member := range strings.Split(_str, ",") {
+		if member = strings.TrimSpace(member); len(member) > 0 {
+			members = append(members, member)
+		}
+	}
+	return strings.Join(members, "|")
+}
+
+// Members lists the supplementary members of a group
+func (self *Groupdata) Members() []string {
+	if len(self.Members_) == 0 {
+		return []string{}
+	}
+	return strings.Split(self.Members_, "|")
+}
+
+// HasMember tells whether a user is a supplementary member of a group
+func (self *Groupdata) HasMember(_user string) bool {
+	return genutil.SliceContainsStr(self.Members(), _user)
+}
+
+// ParseGroup parses /etc/group text into groups in file order; lines without 4 fields are skipped
+func ParseGroup(_text string, _verbose bool) []*Groupdata {
+	groups := []*Groupdata{}
+	for ii, line := range strings.Split(_text, "\n") {
+		if (len(strings.TrimSpace(line)) == 0) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.Split(line, ":")
+		if len(items) != 4 {
+			if _verbose {
+				fmt.Printf("line%d: %d fields, need 4: %s\n", ii+1, len(items), line)
+			}
+			continue
+		}
+		xgr := new(Groupdata)
+		xgr.Groupname_ = items[0]
+		xgr.Pwinfo_ = genutil.StrTernary(len(items[1]) < 2, items[1], fmt.Sprintf("len=%d", len(items[1])))
+		xgr.Gid_ = items[2]
+		xgr.Members_ = memberList(items[3])
+		xgr.Ingshadow_ = "no"
+		xgr.Lineno_ = ii + 1
+		groups = append(groups, xgr)
+		if _verbose {
+			fmt.Printf("line%d: %s", ii+1, xgr.Sprint())
+		}
+	}
+	return groups
+}
+
+// MergeGshadow fills Admins_, Gsmembers_ and Ingshadow_ from /etc/gshadow text; the group passwords there are never kept
+func MergeGshadow(_groups []*Groupdata, _text string) {
+	byName := map[string]*Groupdata{}
+	for _, xgr := range _groups {
+		if byName[xgr.Groupname_] == nil {
+			byName[xgr.Groupname_] = xgr
+		}
+	}
+	for _, line := range strings.Split(_text, "\n") {
+		items := strings.Split(line, ":")
+		if len(items) != 4 {
+			continue
+		}
+		if xgr := byName[items[0]]; xgr != nil {
+			xgr.Admins_ = memberList(items[2])
+			xgr.Gsmembers_ = memberList(items[3])
+			xgr.Ingshadow_ = "yes"
+		}
+	}
+}
+
+// ReadGroups reads /etc/group and, if readable (it needs root), /etc/gshadow, in file order
+func ReadGroups(_verbose bool) ([]*Groupdata, error) {
+	buf, err := ioutil.ReadFile(etcGroup)
+	if err != nil {
+		return nil, err
+	}
+	groups := ParseGroup(string(buf), _verbose)
+	if buf, err := ioutil.ReadFile(etcGshadow); err == nil {
+		MergeGshadow(groups, string(buf))
+	} else if _verbose {
+		fmt.Printf("ReadGroups: %s\n", err)
+	}
+	return groups, nil
+}
+
+// Group extracts group info, keyed by group name; for a repeated name the first line wins, as for getgrnam
+func Group(_verbose bool) (smap map[string]*Groupdata) {
+	smap = make(map[string]*Groupdata)
+	groups, err := ReadGroups(_verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Group: %s\n", err)
+		}
+		return smap
+	}
+	for _, xgr := range groups {
+		if smap[xgr.Groupname_] == nil {
+			smap[xgr.Groupname_] = xgr
+		}
+	}
+	return smap
+}
+
+// UserGroups lists the group names of a user, primary group (by gid) first, then the supplementary groups in file order
+func UserGroups(_groups []*Groupdata, _user, _gid string) []string {
+	names := []string{}
+	for _, xgr := range _groups {
+		if xgr.Gid_ == _gid {
+			names = append(names, xgr.Groupname_)
+			break
+		}
+	}
+	for _, xgr := range _groups {
+		if xgr.HasMember(_user) && !genutil.SliceContainsStr(names, xgr.Groupname_) {
+			names = append(names, xgr.Groupname_)
+		}
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+package etcgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const groupText = `root:x:0:
+# a comment:x:99:
+wheel:x:10:alice, bob
+users:x:100:
+
+broken:x:101
+toomany:x:102:a:b
+sales::200:carol,alice
+secret:$6$salt$hash:201:
+wheel:x:11:mallory
+ops:!:300:bob,alice,bob
+`
+
+const gshadowText = `root:::
+wheel:!::alice,bob
+users:!::
+sales:$6$salt$hash:carol:carol,alice
+ghost:!:admin:someone
+short:!
+`
+
+func TestParseGroup(t *testing.T) {
+	groups := ParseGroup(groupText, false)
+	want := []Groupdata{
+		{Groupname_: "root", Gid_: "0", Pwinfo_: "x", Ingshadow_: "no", Lineno_: 1},
+		{Groupname_: "wheel", Gid_: "10", Pwinfo_: "x", Members_: "alice|bob", Ingshadow_: "no", Lineno_: 3},
+		{Groupname_: "users", Gid_: "100", Pwinfo_: "x", Ingshadow_: "no", Lineno_: 4},
+		{Groupname_: "sales", Gid_: "200", Pwinfo_: "", Members_: "carol|alice", Ingshadow_: "no", Lineno_: 8},
+		{Groupname_: "secret", Gid_: "201", Pwinfo_: "len=12", Ingshadow_: "no", Lineno_: 9},
+		{Groupname_: "wheel", Gid_: "11", Pwinfo_: "x", Members_: "mallory", Ingshadow_: "no", Lineno_: 10},
+		{Groupname_: "ops", Gid_: "300", Pwinfo_: "!", Members_: "bob|alice|bob", Ingshadow_: "no", Lineno_: 11},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(want))
+	}
+	for ii, ww := range want {
+		if *groups[ii] != ww {
+			t.Errorf("group%d: got %+v, want %+v", ii, *groups[ii], ww)
+		}
+	}
+	for _, xgr := range groups {
+		if strings.Contains(xgr.Csv(), "$6$") || strings.Contains(xgr.Sprint(), "$6$") {
+			t.Errorf("%s: password hash leaked: %s", xgr.Groupname_, xgr.Csv())
+		}
+	}
+	if !groups[1].HasMember("bob") || groups[1].HasMember("bo") || (len(groups[0].Members()) != 0) {
+		t.Errorf("members: wheel %v, root %v", groups[1].Members(), groups[0].Members())
+	}
+}
+
+func TestMergeGshadow(t *testing.T) {
+	groups := ParseGroup(groupText, false)
+	MergeGshadow(groups, gshadowText)
+	tests := []struct {
+		ii        int
+		admins    string
+		gsmembers string
+		ingshadow string
+	}{
+		{0, "", "", "yes"},                 // root
+		{1, "", "alice|bob", "yes"},        // wheel, the first one
+		{2, "", "", "yes"},                 // users
+		{3, "carol", "carol|alice", "yes"}, // sales
+		{4, "", "", "no"},                  // secret, missing from gshadow
+		{5, "", "", "no"},                  // wheel again, gshadow goes with the first
+		{6, "", "", "no"},                  // ops, missing from gshadow
+	}
+	for _, tt := range tests {
+		xgr := groups[tt.ii]
+		if (xgr.Admins_ != tt.admins) || (xgr.Gsmembers_ != tt.gsmembers) || (xgr.Ingshadow_ != tt.ingshadow) {
+			t.Errorf("%s: got admins=%q gsmembers=%q ingshadow=%q, want %q %q %q", xgr.Groupname_, xgr.Admins_, xgr.Gsmembers_, xgr.Ingshadow_, tt.admins, tt.gsmembers, tt.ingshadow)
+		}
+		if strings.Contains(xgr.Csv(), "$6$") {
+			t.Errorf("%s: gshadow password leaked: %s", xgr.Groupname_, xgr.Csv())
+		}
+	}
+}
+
+func TestUserGroups(t *testing.T) {
+	groups := ParseGroup(groupText, false)
+	tests := []struct {
+		user, gid string
+		want      string
+	}{
+		{"alice", "100", "users wheel sales ops"},
+		{"bob", "300", "ops wheel"}, // primary first, listed once though ops names bob twice
+		{"carol", "200", "sales"},   // primary and a member: no duplicate
+		{"mallory", "10", "wheel"},  // gid 10 is the first wheel, and the second wheel is the same name
+		{"nobody", "65534", ""},     // no group with the gid
+		{"dave", "0", "root"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(UserGroups(groups, tt.user, tt.gid), " "); got != tt.want {
+			t.Errorf("%s/%s: got %q, want %q", tt.user, tt.gid, got, tt.want)
+		}
+	}
+}
+
+func TestReadGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	savedGroup, savedGshadow := etcGroup, etcGshadow
+	defer func() { etcGroup, etcGshadow = savedGroup, savedGshadow }()
+	etcGroup, etcGshadow = filepath.Join(dir, "group"), filepath.Join(dir, "gshadow")
+	if _, err := ReadGroups(false); err == nil {
+		t.Errorf("ReadGroups without a group file: no error")
+	}
+	if err := ioutil.WriteFile(etcGroup, []byte(groupText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	smap := Group(false) // gshadow unreadable: groups still read
+	if (len(smap) != 6) || (smap["wheel"].Gid_ != "10") || (smap["sales"].Ingshadow_ != "no") {
+		t.Errorf("Group without gshadow = %v", smap)
+	}
+	if err := ioutil.WriteFile(etcGshadow, []byte(gshadowText), 0600); err != nil {
+		t.Fatal(err)
+	}
+	smap = Group(false)
+	if smap["sales"].Admins_ != "carol" {
+		t.Errorf("Group with gshadow: sales %+v", smap["sales"])
+	}
+}
@@ -0,0 +1,128 @@
+// Package etchostconf extracts useful info from /etc/host.conf on linux
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package etchostconf
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"io/ioutil"
+	"strings"
+)
+
+// Hostconfdata holds host.conf data
+type Hostconfdata struct {
+	Order_   string // e.g. hosts|bind, ignored by glibc, which follows nsswitch.conf
+	Multi_   string // on: all addresses of a host in /etc/hosts are returned, not just the first line's
+	Reorder_ string
+	Trim_    string // domains trimmed from names, pipe separated
+	Spoof_   string // nospoof, spoofalert or spoof setting, ignored by current glibc
+}
+
+const (
+	names     = "Order,Multi,Reorder,Trim,Spoof"
+	hdrprefix = ",xhf."
+	semi      = ";"
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// Header is generic
+func Header() string { return headerString }
+
+// Csv is generic
+func (self *Hostconfdata) Csv() string {
+	if self == nil {
+		return commaString
+	}
+	return fmt.Sprintf(pctString, self.Order_, self.Multi_, self.Reorder_, self.Trim_, self.Spoof_)
+}
+
+// Sprint is generic
+func (self *Hostconfdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctString, self.Order_, self.Multi_, self.Reorder_, self.Trim_, self.Spoof_)
+}
+
+// Print is generic
+func (self *Hostconfdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// New is generic
+func New() *Hostconfdata { return new(Hostconfdata) }
+
+// splitList splits a host.conf list, whose items may be separated by commas, colons, semicolons or spaces
+func splitList(_str string) []string {
+	return strings.FieldsFunc(_str, func(rr rune) bool { return strings.ContainsRune(",;: \t", rr) })
+}
+
+// ParseHostconf parses host.conf text; for a repeated keyword the last line wins
+func ParseHostconf(_text string, _verbose bool) *Hostconfdata {
+	xhf := new(Hostconfdata)
+	trims := []string{}
+	for ii, line := range strings.Split(_text, "\n") {
+		if jj := strings.Index(line, "#"); jj >= 0 {
+			line = line[:jj]
+		}
+		items := strings.Fields(line)
+		if len(items) == 0 {
+			continue
+		}
+		value := strings.ToLower(strings.Join(items[1:], " "))
+		switch strings.ToLower(items[0]) {
+		case "order":
+			xhf.Order_ = strings.Join(splitList(value), "|")
+		case "multi":
+			xhf.Multi_ = value
+		case "reorder":
+			xhf.Reorder_ = value
+		case "trim":
+			trims = append(trims, splitList(value)...)
+		case "nospoof", "spoofalert":
+			xhf.Spoof_ = strings.ToLower(items[0]) + genutil.StrTernary(len(value) > 0, "="+value, "")
+		case "spoof":
+			xhf.Spoof_ = value
+		default:
+			if _verbose {
+				fmt.Printf("line%d: unknown keyword: %s\n", ii+1, line)
+			}
+		}
+	}
+	xhf.Trim_ = strings.Join(trims, "|")
+	return xhf
+}
+
+// Hostconf extracts /etc/host.conf; an absent file gives an empty record
+func Hostconf(_verbose bool) *Hostconfdata {
+	buf, err := ioutil.ReadFile("/etc/host.conf")
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Hostconf: %s\n", err)
+		}
+		return new(Hostconfdata)
+	}
+	xhf := ParseHostconf(string(buf), _verbose)
+	if _verbose {
+		xhf.Print()
+	}
+	return xhf
+}
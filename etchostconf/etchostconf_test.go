@@ -0,0 +1,54 @@
+package etchostconf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHostconf(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Hostconfdata
+	}{
+		{"empty", "", Hostconfdata{}},
+		{"multi on", "multi on\n", Hostconfdata{Multi_: "on"}},
+		{"order commas", "order hosts,bind\n", Hostconfdata{Order_: "hosts|bind"}},
+		{"order spaces", "order   hosts  bind nis\n", Hostconfdata{Order_: "hosts|bind|nis"}},
+		{"order mixed", "ORDER Hosts;bind: nis\n", Hostconfdata{Order_: "hosts|bind|nis"}},
+		{"order last wins", "order bind\norder hosts,bind\n", Hostconfdata{Order_: "hosts|bind"}},
+		{"multi off", "multi off\n", Hostconfdata{Multi_: "off"}},
+		{"multi case", "Multi ON\n", Hostconfdata{Multi_: "on"}},
+		{"multi last wins", "multi on\nmulti off\n", Hostconfdata{Multi_: "off"}},
+		{"multi comment", "multi on # all addresses\n", Hostconfdata{Multi_: "on"}},
+		{"trim one", "trim example.com\n", Hostconfdata{Trim_: "example.com"}},
+		{"trim list", "trim example.com,corp.example.com:lab.example.com\n", Hostconfdata{Trim_: "example.com|corp.example.com|lab.example.com"}},
+		{"trim lines add up", "trim example.com\ntrim corp.example.com\n", Hostconfdata{Trim_: "example.com|corp.example.com"}},
+		{"trim lowercased", "trim Example.COM\n", Hostconfdata{Trim_: "example.com"}},
+		{"reorder", "reorder on\n", Hostconfdata{Reorder_: "on"}},
+		{"nospoof", "nospoof on\n", Hostconfdata{Spoof_: "nospoof=on"}},
+		{"spoofalert", "spoofalert\n", Hostconfdata{Spoof_: "spoofalert"}},
+		{"spoof", "spoof warn\n", Hostconfdata{Spoof_: "warn"}},
+		{"comments and unknown", "# host.conf\n\n   # indented\nbogus thing\n#multi on\n", Hostconfdata{}},
+		{"all", "order hosts,bind\nmulti on\ntrim example.com\n", Hostconfdata{Order_: "hosts|bind", Multi_: "on", Trim_: "example.com"}},
+	}
+	for _, tt := range tests {
+		if got := ParseHostconf(tt.text, false); *got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestCsv(t *testing.T) {
+	xhf := ParseHostconf("order hosts,bind\nmulti on\ntrim a.com,b.com\n", false)
+	if got := xhf.Csv(); got != "hosts|bind,on,,a.com|b.com," {
+		t.Errorf("Csv = %q", got)
+	}
+	if strings.Count(xhf.Csv(), ",") != strings.Count(Header(), ",") {
+		t.Errorf("Csv %q does not match the header %q", xhf.Csv(), Header())
+	}
+	var none *Hostconfdata
+	if got := none.Csv(); got != ",,,," {
+		t.Errorf("nil Csv = %q", got)
+	}
+}
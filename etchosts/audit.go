@@ -0,0 +1,137 @@
+package etchosts
+
+import (
+	"fmt"
+	"github.com/LDCS/qslinux/etchostconf"
+	"strings"
+)
+
+// Auditdata is one finding about a hosts file
+type Auditdata struct {
+	Check_    string // invalid, duplicateip, duplicatename or conflict
+	Severity_ string // error, warn or info
+	Lineno_   string
+	Ip_       string
+	Name_     string
+	Detail_   string
+}
+
+const (
+	namesAudit     = "Check,Severity,Lineno,Ip,Name,Detail"
+	hdrprefixAudit = ",xha."
+)
+
+var (
+	headerStringAudit  string
+	commaStringAudit   string
+	pctStringAudit     string
+	namePctStringAudit string
+)
+
+// init  is generic
+func init() {
+	headerStringAudit = (hdrprefixAudit + strings.Join(strings.Split(namesAudit, ","), hdrprefixAudit))[1:]
+	commaStringAudit = strings.Repeat(",", strings.Count(headerStringAudit, ","))
+	pctStringAudit = strings.Repeat(",%s", 1+strings.Count(headerStringAudit, ","))[1:]
+	namePctStringAudit = strings.Replace(namesAudit, ",", "=%s ", -1) + "=%s\n"
+}
+
+// AuditHeader is generic
+func AuditHeader() string { return headerStringAudit }
+
+// Csv is generic
+func (self *Auditdata) Csv() string {
+	if self == nil {
+		return commaStringAudit
+	}
+	return fmt.Sprintf(pctStringAudit, self.Check_, self.Severity_, self.Lineno_, self.Ip_, self.Name_, strings.Replace(self.Detail_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Auditdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringAudit, self.Check_, self.Severity_, self.Lineno_, self.Ip_, self.Name_, self.Detail_)
+}
+
+// Print is generic
+func (self *Auditdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// conflictDetail says which addresses lookups of a name given several see: glibc returns the first line's only,
+// unless host.conf has multi on; _xhf is nil where host.conf is not known
+func conflictDetail(_xhf *etchostconf.Hostconfdata, _name, _prevIp string, _prevLineno int) (string, string) {
+	switch {
+	case _xhf == nil:
+		return "warn", fmt.Sprintf("%s is %s on line%d, lookups return only that one unless host.conf has multi on", _name, _prevIp, _prevLineno)
+	case _xhf.Multi_ == "on":
+		return "info", fmt.Sprintf("%s is also %s on line%d, with multi on lookups return both", _name, _prevIp, _prevLineno)
+	}
+	return "warn", fmt.Sprintf("%s is %s on line%d, lookups return only that one since host.conf does not have multi on", _name, _prevIp, _prevLineno)
+}
+
+// Audit reports the lines that did not parse, addresses on several lines, names repeated for one address,
+// and names given different addresses of the same family, of which lookups only see the first unless host.conf (_xhf, may be nil) has multi on
+func Audit(_xh *Hostsfile, _xhf *etchostconf.Hostconfdata) []*Auditdata {
+	audits := []*Auditdata{}
+	for ii, xl := range _xh.Lines_ {
+		if len(xl.Error_) > 0 {
+			audits = append(audits, &Auditdata{Check_: "invalid", Severity_: "error", Lineno_: fmt.Sprint(ii + 1), Detail_: xl.Error_})
+		}
+	}
+	firstIp := map[string]*Hostsdata{}
+	firstName := map[string]*Hostsdata{} // by family/name
+	for _, xho := range _xh.Entries() {
+		key := canonicalIp(xho.Ip_)
+		if prev := firstIp[key]; prev != nil {
+			audits = append(audits, &Auditdata{Check_: "duplicateip", Severity_: "warn", Lineno_: fmt.Sprint(xho.Lineno_), Ip_: xho.Ip_, Name_: xho.Name_,
+				Detail_: fmt.Sprintf("%s already on line%d, reverse lookups return %s", xho.Ip_, prev.Lineno_, prev.Name_)})
+		} else {
+			firstIp[key] = xho
+		}
+		seen := map[string]bool{}
+		for _, name := range xho.Names_ {
+			lname := strings.ToLower(name)
+			if seen[lname] {
+				audits = append(audits, &Auditdata{Check_: "duplicatename", Severity_: "info", Lineno_: fmt.Sprint(xho.Lineno_), Ip_: xho.Ip_, Name_: name, Detail_: name + " listed twice on the line"})
+				continue
+			}
+			seen[lname] = true
+			prev := firstName[xho.Family_+"/"+lname]
+			switch {
+			case prev == nil:
+				firstName[xho.Family_+"/"+lname] = xho
+			case canonicalIp(prev.Ip_) == key:
+				audits = append(audits, &Auditdata{Check_: "duplicatename", Severity_: "info", Lineno_: fmt.Sprint(xho.Lineno_), Ip_: xho.Ip_, Name_: name,
+					Detail_: fmt.Sprintf("%s already given %s on line%d", name, prev.Ip_, prev.Lineno_)})
+			default:
+				severity, detail := conflictDetail(_xhf, name, prev.Ip_, prev.Lineno_)
+				audits = append(audits, &Auditdata{Check_: "conflict", Severity_: severity, Lineno_: fmt.Sprint(xho.Lineno_), Ip_: xho.Ip_, Name_: name, Detail_: detail})
+			}
+		}
+	}
+	return audits
+}
+
+// HostsAudit audits /etc/hosts, reading multi from /etc/host.conf
+func HostsAudit(_verbose bool) []*Auditdata {
+	xh, err := ReadHosts("/etc/hosts", _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("HostsAudit: %s\n", err)
+		}
+		return nil
+	}
+	audits := Audit(xh, etchostconf.Hostconf(_verbose))
+	if _verbose {
+		for _, audit := range audits {
+			audit.Print()
+		}
+	}
+	return audits
+}
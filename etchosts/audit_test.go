@@ -0,0 +1,74 @@
+package etchosts
+
+import (
+	"github.com/LDCS/qslinux/etchostconf"
+	"strings"
+	"testing"
+)
+
+const auditText = `127.0.0.1 localhost
+10.0.0.5 db1.example.com db1
+10.0.0.300 broken.example.com
+10.0.0.6 db1
+10.0.0.5 db1-alias
+10.0.0.7 web web WEB
+::ffff:10.0.0.7 web
+fe80::1 db1
+10.0.0.05 other
+10.0.0.8
+10.0.0.9 DB1.example.com
+`
+
+// summary renders findings as check:severity:lineno:name, for comparing
+func summary(_audits []*Auditdata) string {
+	out := []string{}
+	for _, audit := range _audits {
+		out = append(out, audit.Check_+":"+audit.Severity_+":"+audit.Lineno_+":"+audit.Name_)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestAudit(t *testing.T) {
+	xh := ParseHosts(auditText, false)
+	want := "invalid:error:3: invalid:error:9: invalid:error:10: " + // 10.0.0.05 is not an address to net.ParseIP
+		"conflict:warn:4:db1 " + // another ipv4 address for db1
+		"duplicateip:warn:5:db1-alias " +
+		"duplicatename:info:6:web duplicatename:info:6:WEB " + // case-insensitive
+		"duplicateip:warn:7:web duplicatename:info:7:web " + // v4-mapped is the same ipv4 address
+		"conflict:warn:11:DB1.example.com"
+	// fe80::1 db1 is another family, so no conflict
+	if got := summary(Audit(xh, nil)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestAuditMulti(t *testing.T) {
+	xh := ParseHosts("10.0.0.5 db1\n10.0.0.6 db1\n", false)
+	tests := []struct {
+		name     string
+		xhf      *etchostconf.Hostconfdata
+		severity string
+		detail   string
+	}{
+		{"unknown", nil, "warn", "lookups return only that one unless host.conf has multi on"},
+		{"multi off", etchostconf.ParseHostconf("multi off\n", false), "warn", "lookups return only that one since host.conf does not have multi on"},
+		{"no multi", etchostconf.ParseHostconf("order hosts,bind\n", false), "warn", "since host.conf does not have multi on"},
+		{"multi on", etchostconf.ParseHostconf("multi on\n", false), "info", "with multi on lookups return both"},
+	}
+	for _, tt := range tests {
+		audits := Audit(xh, tt.xhf)
+		if (len(audits) != 1) || (audits[0].Check_ != "conflict") {
+			t.Errorf("%s: got %s", tt.name, summary(audits))
+			continue
+		}
+		if (audits[0].Severity_ != tt.severity) || !strings.Contains(audits[0].Detail_, tt.detail) || !strings.Contains(audits[0].Detail_, "10.0.0.5 on line1") {
+			t.Errorf("%s: got %s %q", tt.name, audits[0].Severity_, audits[0].Detail_)
+		}
+		if strings.Count(audits[0].Csv(), ",") != strings.Count(AuditHeader(), ",") {
+			t.Errorf("%s: Csv %q does not match the header", tt.name, audits[0].Csv())
+		}
+	}
+	if got := Audit(ParseHosts("", false), nil); len(got) != 0 {
+		t.Errorf("empty file: %s", summary(got))
+	}
+}
@@ -0,0 +1,208 @@
+package etchosts
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LDCS/qslinux/etcfile"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The managed block is delimited by these lines; everything outside it belongs to the local admin and is never rewritten
+const (
+	BlockBegin = "# BEGIN qslinux"
+	BlockEnd   = "# END qslinux"
+)
+
+// labelRegexp is one label of an RFC 1123 hostname
+var labelRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidName tells whether a name is a valid hostname, e.g. web-1.example.com
+func ValidName(_name string) bool {
+	if (len(_name) == 0) || (len(_name) > 253) {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(_name, "."), ".") {
+		if !labelRegexp.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// names returns Names_, or Name_ and Aliases_ when a caller filled only those
+func (self *Hostsdata) names() []string {
+	if len(self.Names_) > 0 {
+		return self.Names_
+	}
+	names := []string{}
+	if len(self.Name_) > 0 {
+		names = append(names, self.Name_)
+	}
+	for _, alias := range strings.Split(self.Aliases_, ":") {
+		if len(alias) > 0 {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// Line renders an entry as a hosts line
+func (self *Hostsdata) Line() string {
+	line := self.Ip_ + "\t" + strings.Join(self.names(), " ")
+	if len(self.Comment_) > 0 {
+		line += "\t# " + self.Comment_
+	}
+	return line
+}
+
+// Validate checks an entry before it is written: a valid address and at least one valid name
+func (self *Hostsdata) Validate() error {
+	if len(family(self.Ip_)) == 0 {
+		return fmt.Errorf("%q is not an ipv4 or ipv6 address", self.Ip_)
+	}
+	names := self.names()
+	if len(names) == 0 {
+		return fmt.Errorf("%s has no name", self.Ip_)
+	}
+	for _, name := range names {
+		if !ValidName(name) {
+			return fmt.Errorf("%s: %q is not a valid hostname", self.Ip_, name)
+		}
+	}
+	if strings.Contains(self.Comment_, "\n") {
+		return fmt.Errorf("%s: comment spans several lines", self.Ip_)
+	}
+	return nil
+}
+
+// String renders the whole file, untouched lines exactly as read
+func (self *Hostsfile) String() string {
+	if len(self.Lines_) == 0 {
+		return ""
+	}
+	raws := []string{}
+	for _, xl := range self.Lines_ {
+		raws = append(raws, xl.Raw_)
+	}
+	return strings.Join(raws, "\n") + "\n"
+}
+
+// Block finds the managed block: the indexes in Lines_ of its begin and end markers, -1,-1 if there is none.
+// A lone or repeated marker is an error, since the block boundary is then unknown and nothing should be rewritten
+func (self *Hostsfile) Block() (int, int, error) {
+	beg, end := -1, -1
+	for ii, xl := range self.Lines_ {
+		switch strings.TrimSpace(xl.Raw_) {
+		case BlockBegin:
+			if beg >= 0 {
+				return -1, -1, fmt.Errorf("etchosts: %s repeated on line%d", BlockBegin, ii+1)
+			}
+			beg = ii
+		case BlockEnd:
+			if (beg < 0) || (end >= 0) {
+				return -1, -1, fmt.Errorf("etchosts: unexpected %s on line%d", BlockEnd, ii+1)
+			}
+			end = ii
+		}
+	}
+	if (beg >= 0) && (end < 0) {
+		return -1, -1, fmt.Errorf("etchosts: %s on line%d has no %s", BlockBegin, beg+1, BlockEnd)
+	}
+	return beg, end, nil
+}
+
+// BlockEntries lists the entries inside the managed block
+func (self *Hostsfile) BlockEntries() []*Hostsdata {
+	entries := []*Hostsdata{}
+	beg, end, err := self.Block()
+	if (err != nil) || (beg < 0) {
+		return entries
+	}
+	for _, xl := range self.Lines_[beg+1 : end] {
+		if xl.Entry_ != nil {
+			entries = append(entries, xl.Entry_)
+		}
+	}
+	return entries
+}
+
+// SetBlock replaces the contents of the managed block with the entries, in the order given, appending the block at the end of the file if there is none yet.
+// Nothing is changed if an entry is invalid or the markers are broken
+func (self *Hostsfile) SetBlock(_entries []*Hostsdata) error {
+	problems := []string{}
+	for _, xho := range _entries {
+		if err := xho.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("etchosts: " + strings.Join(problems, "; "))
+	}
+	beg, end, err := self.Block()
+	if err != nil {
+		return err
+	}
+	block := []*Hostsline{{Raw_: BlockBegin}}
+	for _, xho := range _entries {
+		xl := &Hostsline{Raw_: xho.Line()}
+		xl.Entry_, xl.Error_ = parseEntry(xl.Raw_)
+		block = append(block, xl)
+	}
+	block = append(block, &Hostsline{Raw_: BlockEnd})
+	head, tail := self.Lines_, []*Hostsline{}
+	if beg >= 0 {
+		head, tail = self.Lines_[:beg], self.Lines_[end+1:]
+	}
+	lines := append(append([]*Hostsline{}, head...), block...)
+	self.Lines_ = append(lines, tail...)
+	for ii, xl := range self.Lines_ {
+		if xl.Entry_ != nil {
+			xl.Entry_.Lineno_ = ii + 1
+		}
+	}
+	return nil
+}
+
+// Save writes the file back to Path_ atomically with etcfile.Replace, keeping the mode, owner, group and SELinux label of the old file.
+// It returns whether the content changed; with _dryRun, or when nothing changed, nothing is written
+func (self *Hostsfile) Save(_dryRun, _verbose bool) (bool, error) {
+	if len(self.Path_) == 0 {
+		return false, errors.New("etchosts: Save needs a Path_")
+	}
+	if _, _, err := self.Block(); err != nil {
+		return false, err
+	}
+	old, err := ioutil.ReadFile(self.Path_)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	changed := string(old) != self.String()
+	if _verbose {
+		fmt.Printf("Save: %s changed=%v dryrun=%v\n", self.Path_, changed, _dryRun)
+	}
+	if _dryRun || !changed {
+		return changed, nil
+	}
+	if err := etcfile.Replace(self.Path_, []byte(self.String()), false); err != nil {
+		return changed, err
+	}
+	return changed, nil
+}
+
+// Push replaces the managed block of a hosts file, e.g. /etc/hosts, with the entries, and saves it
+func Push(_path string, _entries []*Hostsdata, _dryRun, _verbose bool) (bool, error) {
+	xh, err := ReadHosts(_path, _verbose)
+	if os.IsNotExist(err) {
+		xh, err = &Hostsfile{Path_: _path}, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := xh.SetBlock(_entries); err != nil {
+		return false, err
+	}
+	return xh.Save(_dryRun, _verbose)
+}
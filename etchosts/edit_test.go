@@ -0,0 +1,162 @@
+package etchosts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"localhost", true},
+		{"web-1.example.com", true},
+		{"web-1.example.com.", true},
+		{"1host", true},
+		{strings.Repeat("a", 63) + ".com", true},
+		{strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a.", 126) + "ab", false}, // 254 characters
+		{"", false},
+		{"-web", false},
+		{"web-", false},
+		{"web_1", false},
+		{"web..example.com", false},
+		{"web 1", false},
+		{".", false},
+	}
+	for _, tt := range tests {
+		if got := ValidName(tt.name); got != tt.want {
+			t.Errorf("ValidName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		beg, end int
+		err      string
+	}{
+		{"none", "127.0.0.1 localhost\n", -1, -1, ""},
+		{"block", "127.0.0.1 localhost\n# BEGIN qslinux\n10.0.0.1 a\n# END qslinux\n", 1, 3, ""},
+		{"indented markers", "  # BEGIN qslinux\n# END qslinux  \n", 0, 1, ""},
+		{"no end", "# BEGIN qslinux\n10.0.0.1 a\n", -1, -1, "etchosts: # BEGIN qslinux on line1 has no # END qslinux"},
+		{"end first", "# END qslinux\n# BEGIN qslinux\n", -1, -1, "etchosts: unexpected # END qslinux on line1"},
+		{"begin repeated", "# BEGIN qslinux\n# BEGIN qslinux\n# END qslinux\n", -1, -1, "etchosts: # BEGIN qslinux repeated on line2"},
+		{"end repeated", "# BEGIN qslinux\n# END qslinux\n# END qslinux\n", -1, -1, "etchosts: unexpected # END qslinux on line3"},
+		{"second block", "# BEGIN qslinux\n# END qslinux\n# BEGIN qslinux\n# END qslinux\n", -1, -1, "etchosts: # BEGIN qslinux repeated on line3"},
+	}
+	for _, tt := range tests {
+		beg, end, err := ParseHosts(tt.text, false).Block()
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if (beg != tt.beg) || (end != tt.end) || (got != tt.err) {
+			t.Errorf("%s: got %d,%d %q, want %d,%d %q", tt.name, beg, end, got, tt.beg, tt.end, tt.err)
+		}
+	}
+}
+
+func TestSetBlock(t *testing.T) {
+	entries := []*Hostsdata{
+		{Ip_: "10.0.0.1", Name_: "db1.example.com", Aliases_: "db1"},
+		{Ip_: "fd00::2", Names_: []string{"web1.example.com", "web1"}, Comment_: "frontend"},
+	}
+	block := "# BEGIN qslinux\n10.0.0.1\tdb1.example.com db1\nfd00::2\tweb1.example.com web1\t# frontend\n# END qslinux\n"
+	tests := []struct {
+		name    string
+		text    string
+		entries []*Hostsdata
+		want    string // "" for an error, the file unchanged
+	}{
+		{"append", "127.0.0.1 localhost\n", entries, "127.0.0.1 localhost\n" + block},
+		{"empty file", "", entries, block},
+		{"replace, keep the rest", "127.0.0.1   localhost   # mine\n# BEGIN qslinux\n10.0.0.9 old\n# END qslinux\n::1 localhost6\n", entries,
+			"127.0.0.1   localhost   # mine\n" + block + "::1 localhost6\n"},
+		{"empty block", "127.0.0.1 localhost\n# BEGIN qslinux\n10.0.0.9 old\n# END qslinux\n", nil, "127.0.0.1 localhost\n# BEGIN qslinux\n# END qslinux\n"},
+		{"bad address", "127.0.0.1 localhost\n", []*Hostsdata{{Ip_: "10.0.0.300", Name_: "a"}}, ""},
+		{"bad name", "127.0.0.1 localhost\n", []*Hostsdata{{Ip_: "10.0.0.3", Name_: "a_b"}}, ""},
+		{"no name", "127.0.0.1 localhost\n", []*Hostsdata{{Ip_: "10.0.0.3"}}, ""},
+		{"comment newline", "127.0.0.1 localhost\n", []*Hostsdata{{Ip_: "10.0.0.3", Name_: "a", Comment_: "x\ny"}}, ""},
+		{"broken markers", "# BEGIN qslinux\n", entries, ""},
+	}
+	for _, tt := range tests {
+		xh := ParseHosts(tt.text, false)
+		err := xh.SetBlock(tt.entries)
+		switch {
+		case (len(tt.want) == 0) && (err == nil):
+			t.Errorf("%s: no error", tt.name)
+		case (len(tt.want) == 0) && (xh.String() != tt.text):
+			t.Errorf("%s: file changed on error: %q", tt.name, xh.String())
+		case (len(tt.want) > 0) && (err != nil):
+			t.Errorf("%s: %s", tt.name, err)
+		case (len(tt.want) > 0) && (xh.String() != tt.want):
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, xh.String(), tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		for ii, xl := range xh.Lines_ {
+			if (xl.Entry_ != nil) && (xl.Entry_.Lineno_ != ii+1) {
+				t.Errorf("%s: line%d has Lineno_ %d", tt.name, ii+1, xl.Entry_.Lineno_)
+			}
+		}
+		if len(tt.entries) > 0 {
+			if got := xh.BlockEntries(); (len(got) != len(tt.entries)) || (got[0].Ip_ != tt.entries[0].Ip_) {
+				t.Errorf("%s: BlockEntries %v", tt.name, got)
+			}
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etchosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts")
+	entries := []*Hostsdata{{Ip_: "10.0.0.1", Name_: "db1"}}
+	tests := []struct {
+		name        string
+		old         string // "-" for no file
+		dryRun      bool
+		entries     []*Hostsdata
+		wantChanged bool
+		wantErr     bool
+		want        string // the file afterwards, "-" for none
+	}{
+		{"new file", "-", false, entries, true, false, "# BEGIN qslinux\n10.0.0.1\tdb1\n# END qslinux\n"},
+		{"new file, dry run", "-", true, entries, true, false, "-"},
+		{"append", "127.0.0.1 localhost\n", false, entries, true, false, "127.0.0.1 localhost\n# BEGIN qslinux\n10.0.0.1\tdb1\n# END qslinux\n"},
+		{"dry run", "127.0.0.1 localhost\n", true, entries, true, false, "127.0.0.1 localhost\n"},
+		{"unchanged", "127.0.0.1 localhost\n# BEGIN qslinux\n10.0.0.1\tdb1\n# END qslinux\n", false, entries, false, false, "127.0.0.1 localhost\n# BEGIN qslinux\n10.0.0.1\tdb1\n# END qslinux\n"},
+		{"broken markers", "# END qslinux\n", false, entries, false, true, "# END qslinux\n"},
+		{"invalid entry", "127.0.0.1 localhost\n", false, []*Hostsdata{{Ip_: "10.0.0.1", Name_: "db 1"}}, false, true, "127.0.0.1 localhost\n"},
+	}
+	for _, tt := range tests {
+		os.Remove(path)
+		if tt.old != "-" {
+			if err := ioutil.WriteFile(path, []byte(tt.old), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		changed, err := Push(path, tt.entries, tt.dryRun, false)
+		if (changed != tt.wantChanged) || ((err != nil) != tt.wantErr) {
+			t.Errorf("%s: got changed=%v err=%v", tt.name, changed, err)
+		}
+		buf, err := ioutil.ReadFile(path)
+		switch {
+		case (tt.want == "-") && !os.IsNotExist(err):
+			t.Errorf("%s: file written: %q", tt.name, buf)
+		case (tt.want != "-") && (string(buf) != tt.want):
+			t.Errorf("%s: file holds %q, want %q", tt.name, buf, tt.want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ package etchosts
 
 import (
 	"fmt"
-	"github.com/LDCS/genutil"
 	"sort"
 	"strings"
 )
@@ -14,11 +13,15 @@ import (
 type Hostsdata struct {
 	Ip_      string
 	Name_    string
-	Aliases_ string
+	Aliases_ string   // colon separated
+	Family_  string   // ipv4 or ipv6
+	Comment_ string   // text after # on the same line
+	Lineno_  int      // line in the hosts file, from 1
+	Names_   []string // Name followed by the aliases
 }
 
 const (
-	names     = "Ip,Name,Aliases"
+	names     = "Ip,Name,Aliases,Family,Comment"
 	hdrprefix = ",xho."
 	semi      = ";"
 )
@@ -69,7 +72,7 @@ func (self *Hostsdata) Csv() string {
 	if self == nil {
 		return commaString
 	}
-	return fmt.Sprintf(pctString, self.Ip_, self.Name_, self.Aliases_)
+	return fmt.Sprintf(pctString, self.Ip_, self.Name_, self.Aliases_, self.Family_, strings.Replace(self.Comment_, ",", semi, -1))
 }
 
 // Sprint is generic
@@ -77,7 +80,7 @@ func (self *Hostsdata) Sprint() string {
 	if self == nil {
 		return ""
 	}
-	return fmt.Sprintf(namePctString, self.Ip_, self.Name_, self.Aliases_)
+	return fmt.Sprintf(namePctString, self.Ip_, self.Name_, self.Aliases_, self.Family_, self.Comment_)
 }
 
 // Print is generic
@@ -91,29 +94,29 @@ func (self *Hostsdata) Print() {
 // New is generic
 func New() *Hostsdata { return new(Hostsdata) }
 
-// Hosts extracts etc hosts info
+// Hosts extracts etc hosts info, keyed by ip.
+// As for the resolver, the first line of an ip gives its Name_; the names of later lines for the same ip are appended to Aliases_ (see Audit for duplicates)
 func Hosts(_verbose bool) (smap map[string]*Hostsdata) {
 	smap = make(map[string]*Hostsdata)
-	out := genutil.BashExecOrDie(_verbose, "/bin/cat /etc/hosts", ".")
-	if _verbose {
-		fmt.Println(out)
+	xh, err := ReadHosts("/etc/hosts", _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Hosts: %s\n", err)
+		}
+		return smap
 	}
-	lines := genutil.CleanAndSplitOnSpaces(out, ",")
-	for ii, line := range lines {
-		items := strings.Split(line, ",")
-		num := len(items)
-		if num < 2 {
+	for _, xho := range xh.Entries() {
+		key := canonicalIp(xho.Ip_)
+		if first := smap[key]; first != nil {
+			merged := *first
+			merged.Names_ = appendNew(append([]string{}, first.Names_...), xho.Names_...)
+			merged.Aliases_ = strings.Join(merged.Names_[1:], ":")
+			smap[key] = &merged
 			continue
 		}
-		lastxho := new(Hostsdata)
-		lastxho.Ip_ = items[0]
-		lastxho.Name_ = items[1]
-		if num > 2 {
-			lastxho.Aliases_ = strings.Join(items[2:], ":")
-		}
-		smap[lastxho.Ip_] = lastxho
+		smap[key] = xho
 		if _verbose {
-			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
+			fmt.Printf("line%d: key(%s) %s", xho.Lineno_, key, xho.Sprint())
 		}
 	}
 	return smap
@@ -0,0 +1,188 @@
+package etchosts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// Hostsline is one line of a hosts file, kept verbatim so the file can be written back unchanged
+type Hostsline struct {
+	Raw_   string     // the line as read, without its newline
+	Entry_ *Hostsdata // nil for comments, blank lines and lines that failed to parse
+	Error_ string     // why a non-comment line did not parse
+}
+
+// Hostsfile holds a whole hosts file in order
+type Hostsfile struct {
+	Path_  string
+	Lines_ []*Hostsline
+}
+
+// canonicalIp renders an address the same way however it was written, e.g. 0:0::1 -> ::1; invalid addresses are returned as is
+func canonicalIp(_ip string) string {
+	if ip := net.ParseIP(_ip); ip != nil {
+		return ip.String()
+	}
+	return _ip
+}
+
+// family tells ipv4 from ipv6, "" for an invalid address; v4-mapped ipv6 addresses such as ::ffff:10.0.0.1 count as ipv4, as for the resolver
+func family(_ip string) string {
+	ip := net.ParseIP(_ip)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// appendNew appends the names not already in a list, case-insensitively since hostnames are
+func appendNew(_list []string, _names ...string) []string {
+	for _, name := range _names {
+		found := false
+		for _, have := range _list {
+			found = found || strings.EqualFold(have, name)
+		}
+		if !found {
+			_list = append(_list, name)
+		}
+	}
+	return _list
+}
+
+// parseEntry parses a non-comment hosts line: an address, a canonical name, then aliases, with an optional # comment
+func parseEntry(_line string) (*Hostsdata, string) {
+	comment := ""
+	if ii := strings.Index(_line, "#"); ii >= 0 {
+		_line, comment = _line[:ii], strings.TrimSpace(_line[ii+1:])
+	}
+	items := strings.Fields(_line)
+	if len(items) < 2 {
+		return nil, fmt.Sprintf("%d fields, need an address and a name", len(items))
+	}
+	xho := new(Hostsdata)
+	xho.Ip_ = items[0]
+	xho.Family_ = family(items[0])
+	if len(xho.Family_) == 0 {
+		return nil, fmt.Sprintf("%s is not an ipv4 or ipv6 address", items[0])
+	}
+	xho.Name_ = items[1]
+	xho.Names_ = items[1:]
+	xho.Aliases_ = strings.Join(items[2:], ":")
+	xho.Comment_ = comment
+	return xho, ""
+}
+
+// ParseHosts parses hosts text, keeping every line in order
+func ParseHosts(_text string, _verbose bool) *Hostsfile {
+	xh := new(Hostsfile)
+	lines := strings.Split(strings.TrimSuffix(_text, "\n"), "\n")
+	if (len(lines) == 1) && (len(lines[0]) == 0) {
+		lines = nil
+	}
+	for ii, line := range lines {
+		xl := &Hostsline{Raw_: line}
+		trimmed := strings.TrimSpace(line)
+		if (len(trimmed) > 0) && !strings.HasPrefix(trimmed, "#") {
+			xl.Entry_, xl.Error_ = parseEntry(trimmed)
+			if xl.Entry_ != nil {
+				xl.Entry_.Lineno_ = ii + 1
+			}
+			if _verbose && (len(xl.Error_) > 0) {
+				fmt.Printf("line%d: %s: %s\n", ii+1, xl.Error_, line)
+			}
+		}
+		xh.Lines_ = append(xh.Lines_, xl)
+	}
+	return xh
+}
+
+// ReadHosts reads and parses a hosts file
+func ReadHosts(_path string, _verbose bool) (*Hostsfile, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	xh := ParseHosts(string(buf), _verbose)
+	xh.Path_ = _path
+	return xh, nil
+}
+
+// Entries lists the entries in file order
+func (self *Hostsfile) Entries() []*Hostsdata {
+	entries := []*Hostsdata{}
+	for _, xl := range self.Lines_ {
+		if xl.Entry_ != nil {
+			entries = append(entries, xl.Entry_)
+		}
+	}
+	return entries
+}
+
+// HasName tells whether an entry carries a name, as canonical name or alias
+func (self *Hostsdata) HasName(_name string) bool {
+	for _, name := range self.Names_ {
+		if strings.EqualFold(name, _name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupName is the forward lookup: the addresses of a name in file order, both families, without repeats
+func (self *Hostsfile) LookupName(_name string) []string {
+	ips := []string{}
+	seen := map[string]bool{}
+	for _, xho := range self.Entries() {
+		if xho.HasName(_name) && !seen[canonicalIp(xho.Ip_)] {
+			seen[canonicalIp(xho.Ip_)] = true
+			ips = append(ips, xho.Ip_)
+		}
+	}
+	return ips
+}
+
+// LookupAddr is the reverse lookup: the names of an address in file order, the first being what gethostbyaddr returns
+func (self *Hostsfile) LookupAddr(_ip string) []string {
+	names := []string{}
+	ip := net.ParseIP(_ip)
+	if ip == nil {
+		return names
+	}
+	for _, xho := range self.Entries() {
+		if ip.Equal(net.ParseIP(xho.Ip_)) {
+			names = appendNew(names, xho.Names_...)
+		}
+	}
+	return names
+}
+
+// Forward maps each lowercased name to its addresses, see LookupName
+func (self *Hostsfile) Forward() map[string][]string {
+	fwd := map[string][]string{}
+	for _, xho := range self.Entries() {
+		for _, name := range xho.Names_ {
+			key := strings.ToLower(name)
+			if _, ok := fwd[key]; !ok {
+				fwd[key] = self.LookupName(name)
+			}
+		}
+	}
+	return fwd
+}
+
+// Reverse maps each canonical address to its names, see LookupAddr
+func (self *Hostsfile) Reverse() map[string][]string {
+	rev := map[string][]string{}
+	for _, xho := range self.Entries() {
+		key := canonicalIp(xho.Ip_)
+		if _, ok := rev[key]; !ok {
+			rev[key] = self.LookupAddr(xho.Ip_)
+		}
+	}
+	return rev
+}
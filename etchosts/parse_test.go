@@ -0,0 +1,130 @@
+package etchosts
+
+import (
+	"reflect"
+	"testing"
+)
+
+const hostsText = `# /etc/hosts
+127.0.0.1	localhost
+127.0.1.1	box.example.com box   # set by the installer
+
+::1     localhost ip6-localhost ip6-loopback
+0:0:0:0:0:0:0:1 localhost6
+fe00::0 ip6-localnet
+10.0.0.5 db1.example.com db1
+10.0.0.5 DB1 database
+  # indented comment
+10.0.0.6
+10.0.0.300 broken.example.com
+::ffff:10.0.0.7 mapped
+10.0.0.8 box.example.com
+`
+
+func TestParseHosts(t *testing.T) {
+	xh := ParseHosts(hostsText, false)
+	if got := xh.String(); got != hostsText {
+		t.Errorf("String does not round trip:\n%s", got)
+	}
+	tests := []struct {
+		lineno  int
+		ip      string // "" for no entry
+		family  string
+		names   []string
+		aliases string
+		comment string
+		err     string
+	}{
+		{1, "", "", nil, "", "", ""},
+		{2, "127.0.0.1", "ipv4", []string{"localhost"}, "", "", ""},
+		{3, "127.0.1.1", "ipv4", []string{"box.example.com", "box"}, "box", "set by the installer", ""},
+		{4, "", "", nil, "", "", ""},
+		{5, "::1", "ipv6", []string{"localhost", "ip6-localhost", "ip6-loopback"}, "ip6-localhost:ip6-loopback", "", ""},
+		{6, "0:0:0:0:0:0:0:1", "ipv6", []string{"localhost6"}, "", "", ""},
+		{7, "fe00::0", "ipv6", []string{"ip6-localnet"}, "", "", ""},
+		{8, "10.0.0.5", "ipv4", []string{"db1.example.com", "db1"}, "db1", "", ""},
+		{9, "10.0.0.5", "ipv4", []string{"DB1", "database"}, "database", "", ""},
+		{10, "", "", nil, "", "", ""},
+		{11, "", "", nil, "", "", "1 fields, need an address and a name"},
+		{12, "", "", nil, "", "", "10.0.0.300 is not an ipv4 or ipv6 address"},
+		{13, "::ffff:10.0.0.7", "ipv4", []string{"mapped"}, "", "", ""},
+		{14, "10.0.0.8", "ipv4", []string{"box.example.com"}, "", "", ""},
+	}
+	if len(xh.Lines_) != len(tests) {
+		t.Fatalf("got %d lines, want %d", len(xh.Lines_), len(tests))
+	}
+	for _, tt := range tests {
+		xl := xh.Lines_[tt.lineno-1]
+		if xl.Error_ != tt.err {
+			t.Errorf("line%d: error %q, want %q", tt.lineno, xl.Error_, tt.err)
+		}
+		xho := xl.Entry_
+		if (xho == nil) != (len(tt.ip) == 0) {
+			t.Errorf("line%d: entry %+v, want ip %q", tt.lineno, xho, tt.ip)
+			continue
+		}
+		if xho == nil {
+			continue
+		}
+		if (xho.Ip_ != tt.ip) || (xho.Family_ != tt.family) || (xho.Name_ != tt.names[0]) || !reflect.DeepEqual(xho.Names_, tt.names) ||
+			(xho.Aliases_ != tt.aliases) || (xho.Comment_ != tt.comment) || (xho.Lineno_ != tt.lineno) {
+			t.Errorf("line%d: got %+v", tt.lineno, *xho)
+		}
+	}
+	if entries := xh.Entries(); len(entries) != 9 {
+		t.Errorf("got %d entries, want 9", len(entries))
+	}
+}
+
+func TestParseHostsEmpty(t *testing.T) {
+	for _, text := range []string{"", "\n"} {
+		if xh := ParseHosts(text, false); (len(xh.Lines_) != 0) || (xh.String() != "") {
+			t.Errorf("%q: got %d lines", text, len(xh.Lines_))
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	xh := ParseHosts(hostsText, false)
+	names := []struct {
+		name string
+		ips  []string
+	}{
+		{"localhost", []string{"127.0.0.1", "::1"}},
+		{"LOCALHOST6", []string{"0:0:0:0:0:0:0:1"}},
+		{"db1", []string{"10.0.0.5"}}, // db1 and DB1 on two lines of the same address
+		{"box.example.com", []string{"127.0.1.1", "10.0.0.8"}},
+		{"broken.example.com", []string{}},
+		{"nosuch", []string{}},
+	}
+	for _, tt := range names {
+		if got := xh.LookupName(tt.name); !reflect.DeepEqual(got, tt.ips) {
+			t.Errorf("LookupName(%s): got %v, want %v", tt.name, got, tt.ips)
+		}
+	}
+	addrs := []struct {
+		ip    string
+		names []string
+	}{
+		{"::1", []string{"localhost", "ip6-localhost", "ip6-loopback", "localhost6"}}, // ::1 written two ways
+		{"10.0.0.5", []string{"db1.example.com", "db1", "database"}},
+		{"10.0.0.7", []string{"mapped"}},
+		{"10.0.0.9", []string{}},
+		{"not-an-ip", []string{}},
+	}
+	for _, tt := range addrs {
+		if got := xh.LookupAddr(tt.ip); !reflect.DeepEqual(got, tt.names) {
+			t.Errorf("LookupAddr(%s): got %v, want %v", tt.ip, got, tt.names)
+		}
+	}
+	if got := xh.Forward()["db1"]; !reflect.DeepEqual(got, []string{"10.0.0.5"}) {
+		t.Errorf("Forward: db1 gives %v", got)
+	}
+	rev := xh.Reverse()
+	if got := rev["::1"]; len(got) != 4 {
+		t.Errorf("Reverse: ::1 gives %v", got)
+	}
+	if _, ok := rev["0:0:0:0:0:0:0:1"]; ok {
+		t.Errorf("Reverse: keys are not canonical")
+	}
+}
@@ -0,0 +1,250 @@
+// Package etcnsswitch extracts useful info from /etc/nsswitch.conf on linux
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package etcnsswitch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Nsservice is one source of a database with the actions that follow it, e.g. files [NOTFOUND=return]
+type Nsservice struct {
+	Name_    string            // e.g. files, dns, sss
+	Actions_ map[string]string // e.g. NOTFOUND -> return, keys uppercased; a leading ! is kept in the key, e.g. !UNAVAIL
+}
+
+// Nsswitchdata holds one database line of nsswitch.conf
+type Nsswitchdata struct {
+	Database_ string       // e.g. hosts, passwd
+	Sources_  string       // the sources in lookup order, e.g. files|[NOTFOUND=return]|dns, spaces turned to pipes for csv
+	Services_ []*Nsservice // Sources split into services
+	Lineno_   int          // line in the file, from 1
+}
+
+const (
+	names     = "Database,Sources"
+	hdrprefix = ",xns."
+	semi      = ";"
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+	// Defaults are what glibc uses for a database missing from the file (or when there is no file)
+	Defaults = map[string]string{
+		"hosts":  "dns [!UNAVAIL=return] files",
+		"passwd": "files",
+		"group":  "files",
+		"shadow": "files",
+	}
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// SortedKeys_String2PtrNsswitchdata is generic
+func SortedKeys_String2PtrNsswitchdata(_mp *map[string]*Nsswitchdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys_String2PtrNsswitchdata is generic
+func Keys_String2PtrNsswitchdata(_mp *map[string]*Nsswitchdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	return keys
+}
+
+// Header is generic
+func Header() string { return headerString }
+
+// Csv is generic
+func (self *Nsswitchdata) Csv() string {
+	if self == nil {
+		return commaString
+	}
+	return fmt.Sprintf(pctString, self.Database_, strings.Replace(self.Sources_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Nsswitchdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctString, self.Database_, self.Sources_)
+}
+
+// Print is generic
+func (self *Nsswitchdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// New is generic
+func New() *Nsswitchdata { return new(Nsswitchdata) }
+
+// ParseSources splits a sources spec, e.g. "files [NOTFOUND=return] dns", into services; actions before the first service are dropped
+func ParseSources(_spec string) []*Nsservice {
+	services := []*Nsservice{}
+	inActions := false
+	for _, item := range strings.Fields(strings.NewReplacer("[", " [ ", "]", " ] ").Replace(_spec)) {
+		switch {
+		case item == "[":
+			inActions = true
+		case item == "]":
+			inActions = false
+		case inActions:
+			kv := strings.SplitN(item, "=", 2)
+			if (len(kv) == 2) && (len(services) > 0) {
+				services[len(services)-1].Actions_[strings.ToUpper(kv[0])] = strings.ToLower(kv[1])
+			}
+		default:
+			services = append(services, &Nsservice{Name_: item, Actions_: map[string]string{}})
+		}
+	}
+	return services
+}
+
+// Has tells whether a database uses a source, e.g. Has("dns")
+func (self *Nsswitchdata) Has(_name string) bool {
+	for _, svc := range self.Services_ {
+		if svc.Name_ == _name {
+			return true
+		}
+	}
+	return false
+}
+
+// missStatus is what a source returns for an ordinary name it does not know, NOTFOUND unless listed.
+// The mdns minimal modules only answer for .local names and are UNAVAIL for others
+var missStatus = map[string]string{
+	"mdns_minimal":  "UNAVAIL",
+	"mdns4_minimal": "UNAVAIL",
+	"mdns6_minimal": "UNAVAIL",
+}
+
+// MissStatus is the status a source returns for an ordinary name it does not know
+func MissStatus(_name string) string {
+	if status, ok := missStatus[_name]; ok {
+		return status
+	}
+	return "NOTFOUND"
+}
+
+// Action is what glibc does after a source returned _status (SUCCESS, NOTFOUND, UNAVAIL or TRYAGAIN): return or continue.
+// [STATUS=action] applies to that status, [!STATUS=action] to any other one; by default only SUCCESS returns
+func (self *Nsservice) Action(_status string) string {
+	status := strings.ToUpper(_status)
+	if action, ok := self.Actions_[status]; ok {
+		return action
+	}
+	for key, action := range self.Actions_ {
+		if strings.HasPrefix(key, "!") && (key[1:] != status) {
+			return action
+		}
+	}
+	if status == "SUCCESS" {
+		return "return"
+	}
+	return "continue"
+}
+
+// ReachableIf lists the sources consulted for a name no source finds, when each source returns _status(name)
+func (self *Nsswitchdata) ReachableIf(_status func(name string) string) []string {
+	reached := []string{}
+	for _, svc := range self.Services_ {
+		reached = append(reached, svc.Name_)
+		if svc.Action(_status(svc.Name_)) == "return" {
+			break
+		}
+	}
+	return reached
+}
+
+// Reachable lists the sources consulted for an ordinary name that is not found anywhere, with every source up.
+// A source after e.g. files [NOTFOUND=return] is never consulted then, while one after mdns4_minimal [NOTFOUND=return] is,
+// since mdns4_minimal is UNAVAIL rather than NOTFOUND for names outside .local
+func (self *Nsswitchdata) Reachable() []string { return self.ReachableIf(MissStatus) }
+
+// Fallback lists the sources consulted when every source is unavailable, e.g. dns after resolve [!UNAVAIL=return]
+func (self *Nsswitchdata) Fallback() []string {
+	return self.ReachableIf(func(string) string { return "UNAVAIL" })
+}
+
+// ParseNsswitch parses nsswitch.conf text, keyed by database; for a repeated database the first line wins, as in glibc
+func ParseNsswitch(_text string, _verbose bool) (smap map[string]*Nsswitchdata) {
+	smap = make(map[string]*Nsswitchdata)
+	for ii, line := range strings.Split(_text, "\n") {
+		if jj := strings.Index(line, "#"); jj >= 0 {
+			line = line[:jj]
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) < 2 {
+			if _verbose && (len(strings.TrimSpace(line)) > 0) {
+				fmt.Printf("line%d: no colon: %s\n", ii+1, line)
+			}
+			continue
+		}
+		xns := new(Nsswitchdata)
+		xns.Database_ = strings.TrimSpace(kv[0])
+		xns.Services_ = ParseSources(kv[1])
+		xns.Sources_ = strings.Join(strings.Fields(kv[1]), "|")
+		xns.Lineno_ = ii + 1
+		if smap[xns.Database_] != nil {
+			if _verbose {
+				fmt.Printf("line%d: %s already on line%d\n", ii+1, xns.Database_, smap[xns.Database_].Lineno_)
+			}
+			continue
+		}
+		smap[xns.Database_] = xns
+		if _verbose {
+			fmt.Printf("line%d: %s", ii+1, xns.Sprint())
+		}
+	}
+	return smap
+}
+
+// Lookup returns the line of a database, or glibc's default for it (with Lineno_ 0) if it has one, else nil
+func Lookup(_smap map[string]*Nsswitchdata, _database string) *Nsswitchdata {
+	if xns := _smap[_database]; xns != nil {
+		return xns
+	}
+	if spec, ok := Defaults[_database]; ok {
+		return &Nsswitchdata{Database_: _database, Sources_: strings.Join(strings.Fields(spec), "|"), Services_: ParseSources(spec)}
+	}
+	return nil
+}
+
+// Nsswitch extracts /etc/nsswitch.conf, keyed by database; an absent file gives an empty map, i.e. glibc defaults
+func Nsswitch(_verbose bool) (smap map[string]*Nsswitchdata) {
+	buf, err := ioutil.ReadFile("/etc/nsswitch.conf")
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Nsswitch: %s\n", err)
+		}
+		return make(map[string]*Nsswitchdata)
+	}
+	return ParseNsswitch(string(buf), _verbose)
+}
@@ -0,0 +1,71 @@
+package etcnsswitch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReachable(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		reachable string
+		fallback  string
+	}{
+		{"ubuntu", "files mdns4_minimal [NOTFOUND=return] dns", "files mdns4_minimal dns", "files mdns4_minimal dns"},
+		{"fedora", "files myhostname resolve [!UNAVAIL=return] dns", "files myhostname resolve", "files myhostname resolve dns"},
+		{"default", Defaults["hosts"], "dns", "dns files"},
+		{"files only", "files [NOTFOUND=return] dns", "files", "files dns"},
+		{"not success", "files [!SUCCESS=return] dns", "files", "files"},
+		{"plain", "files dns", "files dns", "files dns"},
+		{"spacing", "files[NOTFOUND=return]  dns", "files", "files dns"},
+	}
+	for _, tt := range tests {
+		xns := &Nsswitchdata{Database_: "hosts", Services_: ParseSources(tt.spec)}
+		if got := strings.Join(xns.Reachable(), " "); got != tt.reachable {
+			t.Errorf("%s: Reachable() = %q, want %q", tt.name, got, tt.reachable)
+		}
+		if got := strings.Join(xns.Fallback(), " "); got != tt.fallback {
+			t.Errorf("%s: Fallback() = %q, want %q", tt.name, got, tt.fallback)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		spec   string
+		status string
+		want   string
+	}{
+		{"dns", "SUCCESS", "return"},
+		{"dns", "NOTFOUND", "continue"},
+		{"dns [NOTFOUND=return]", "NOTFOUND", "return"},
+		{"dns [NOTFOUND=return]", "UNAVAIL", "continue"},
+		{"dns [!UNAVAIL=return]", "NOTFOUND", "return"},
+		{"dns [!UNAVAIL=return]", "UNAVAIL", "continue"},
+		{"dns [SUCCESS=continue]", "SUCCESS", "continue"},
+		{"dns [tryagain=Return]", "TRYAGAIN", "return"},
+	}
+	for _, tt := range tests {
+		svc := ParseSources(tt.spec)[0]
+		if got := svc.Action(tt.status); got != tt.want {
+			t.Errorf("%s: Action(%s) = %s, want %s", tt.spec, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestParseNsswitch(t *testing.T) {
+	smap := ParseNsswitch("# comment\npasswd: files systemd\nhosts: files dns # trailing\nhosts: dns\nbogus line\n", false)
+	if len(smap) != 2 {
+		t.Fatalf("got %d databases, want 2", len(smap))
+	}
+	if xns := smap["hosts"]; (xns.Sources_ != "files|dns") || (xns.Lineno_ != 3) {
+		t.Errorf("hosts = %q on line%d, want files|dns on line3", xns.Sources_, xns.Lineno_)
+	}
+	if xns := Lookup(smap, "shadow"); (xns == nil) || (xns.Lineno_ != 0) || !xns.Has("files") {
+		t.Errorf("Lookup(shadow) = %v, want the files default", xns)
+	}
+	if xns := Lookup(smap, "automount"); xns != nil {
+		t.Errorf("Lookup(automount) = %v, want nil", xns)
+	}
+}
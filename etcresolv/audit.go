@@ -0,0 +1,159 @@
+package etcresolv
+
+import (
+	"fmt"
+	"github.com/LDCS/qslinux/etchostconf"
+	"github.com/LDCS/qslinux/etchosts"
+	"github.com/LDCS/qslinux/etcnsswitch"
+	"strings"
+)
+
+// Auditdata is one finding about the resolver configuration
+type Auditdata struct {
+	Check_    string // e.g. nohosts, nofiles, nodns, nonameserver, unreachable, fallback
+	Severity_ string // error (names will not resolve as intended), warn or info
+	File_     string // the file to fix
+	Lineno_   string
+	Detail_   string
+}
+
+const (
+	namesAudit     = "Check,Severity,File,Lineno,Detail"
+	hdrprefixAudit = ",xra."
+)
+
+var (
+	headerStringAudit  string
+	commaStringAudit   string
+	pctStringAudit     string
+	namePctStringAudit string
+)
+
+// init  is generic
+func init() {
+	headerStringAudit = (hdrprefixAudit + strings.Join(strings.Split(namesAudit, ","), hdrprefixAudit))[1:]
+	commaStringAudit = strings.Repeat(",", strings.Count(headerStringAudit, ","))
+	pctStringAudit = strings.Repeat(",%s", 1+strings.Count(headerStringAudit, ","))[1:]
+	namePctStringAudit = strings.Replace(namesAudit, ",", "=%s ", -1) + "=%s\n"
+}
+
+// AuditHeader is generic
+func AuditHeader() string { return headerStringAudit }
+
+// Csv is generic
+func (self *Auditdata) Csv() string {
+	if self == nil {
+		return commaStringAudit
+	}
+	return fmt.Sprintf(pctStringAudit, self.Check_, self.Severity_, self.File_, self.Lineno_, strings.Replace(self.Detail_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Auditdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringAudit, self.Check_, self.Severity_, self.File_, self.Lineno_, self.Detail_)
+}
+
+// Print is generic
+func (self *Auditdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// lineno renders a line number, "" for 0
+func lineno(_nn int) string {
+	if _nn == 0 {
+		return ""
+	}
+	return fmt.Sprint(_nn)
+}
+
+// Audit looks for configurations where names cannot resolve the way the files suggest:
+// hosts missing from nsswitch, /etc/hosts or dns left out or made unreachable by a return action, dns without usable nameservers,
+// nameservers that are ignored, and host.conf settings glibc no longer honours. _hosts may be nil if /etc/hosts could not be read
+func Audit(_xrs *Resolvdata, _xns map[string]*etcnsswitch.Nsswitchdata, _xhf *etchostconf.Hostconfdata, _hosts *etchosts.Hostsfile) []*Auditdata {
+	audits := []*Auditdata{}
+	add := func(_check, _severity, _file string, _lineno int, _detail string) {
+		audits = append(audits, &Auditdata{Check_: _check, Severity_: _severity, File_: _file, Lineno_: lineno(_lineno), Detail_: _detail})
+	}
+	hosts := etcnsswitch.Lookup(_xns, "hosts")
+	if _xns["hosts"] == nil {
+		add("nohosts", "warn", "/etc/nsswitch.conf", 0, "no hosts line, glibc uses the default: "+strings.Replace(hosts.Sources_, "|", " ", -1))
+	}
+	reachable := " " + strings.Join(hosts.Reachable(), " ") + " "
+	fallback := " " + strings.Join(hosts.Fallback(), " ") + " "
+	for _, name := range []string{"files", "dns"} {
+		switch {
+		case !hosts.Has(name) || strings.Contains(reachable, " "+name+" "):
+		case strings.Contains(fallback, " "+name+" "):
+			add("fallback", "info", "/etc/nsswitch.conf", hosts.Lineno_, fmt.Sprintf("%s is only tried when an earlier source is unavailable, hosts: %s", name, hosts.Sources_))
+		default:
+			add("unreachable", "error", "/etc/nsswitch.conf", hosts.Lineno_, fmt.Sprintf("%s comes after a return action in hosts: %s, so it is never tried", name, hosts.Sources_))
+		}
+	}
+	localNames := 0
+	if _hosts != nil {
+		for _, xho := range _hosts.Entries() {
+			if !strings.HasPrefix(xho.Name_, "localhost") {
+				localNames++
+			}
+		}
+	}
+	switch {
+	case !hosts.Has("files") && (localNames > 0):
+		add("nofiles", "error", "/etc/nsswitch.conf", hosts.Lineno_, fmt.Sprintf("hosts: %s does not use files, the %d non-localhost entries of /etc/hosts are ignored", hosts.Sources_, localNames))
+	case !hosts.Has("files"):
+		add("nofiles", "warn", "/etc/nsswitch.conf", hosts.Lineno_, fmt.Sprintf("hosts: %s does not use files, localhost may not resolve", hosts.Sources_))
+	case _hosts == nil:
+		add("nofiles", "warn", "/etc/hosts", 0, "hosts uses files but /etc/hosts cannot be read")
+	}
+	nameservers := _xrs.Nameservers()
+	switch {
+	case hosts.Has("dns") && (len(nameservers) == 0):
+		add("nonameserver", "warn", "/etc/resolv.conf", 0, "hosts uses dns but there is no nameserver, the resolver falls back to 127.0.0.1")
+	case !hosts.Has("dns") && !hosts.Has("resolve") && (len(nameservers) > 0):
+		add("nodns", "info", "/etc/nsswitch.conf", hosts.Lineno_, fmt.Sprintf("hosts: %s does not use dns, the nameservers of /etc/resolv.conf are unused", hosts.Sources_))
+	}
+	for ii, ns := range nameservers {
+		if !validNameserver(ns) {
+			add("badnameserver", "error", "/etc/resolv.conf", _xrs.Lineno_["nameserver"], ns+" is not an address")
+		}
+		if ii >= Maxns {
+			add("ignorednameserver", "warn", "/etc/resolv.conf", _xrs.Lineno_["nameserver"], fmt.Sprintf("%s is ignored, the resolver uses the first %d nameservers", ns, Maxns))
+		}
+	}
+	if search := _xrs.Searchlist(); (len(search) > 6) || (len(strings.Join(search, " ")) > 256) {
+		add("longsearch", "warn", "/etc/resolv.conf", _xrs.Lineno_["search"], fmt.Sprintf("%d search domains, resolvers older than glibc 2.26 use only 6 and 256 characters", len(search)))
+	}
+	if (_xrs.Lineno_["domain"] > 0) && (_xrs.Lineno_["search"] > 0) {
+		add("domainsearch", "info", "/etc/resolv.conf", 0, "both domain and search lines, only the last one counts")
+	}
+	if _xhf != nil {
+		if len(_xhf.Order_) > 0 {
+			add("hostconforder", "info", "/etc/host.conf", 0, "order "+_xhf.Order_+" is ignored by glibc, nsswitch.conf decides")
+		}
+		if len(_xhf.Spoof_) > 0 {
+			add("hostconfspoof", "info", "/etc/host.conf", 0, _xhf.Spoof_+" is ignored by glibc 2.25 and later")
+		}
+	}
+	return audits
+}
+
+// ResolverAudit audits the resolver files of this box
+func ResolverAudit(_verbose bool) []*Auditdata {
+	hosts, err := etchosts.ReadHosts("/etc/hosts", _verbose)
+	if err != nil {
+		hosts = nil
+	}
+	audits := Audit(Resolv(_verbose), etcnsswitch.Nsswitch(_verbose), etchostconf.Hostconf(_verbose), hosts)
+	if _verbose {
+		for _, audit := range audits {
+			audit.Print()
+		}
+	}
+	return audits
+}
@@ -0,0 +1,69 @@
+package etcresolv
+
+import (
+	"github.com/LDCS/qslinux/etcnsswitch"
+	"testing"
+)
+
+func TestAuditHostsLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts string // empty for no hosts line
+		want  map[string]string
+	}{
+		{"ubuntu", "files mdns4_minimal [NOTFOUND=return] dns", map[string]string{}},
+		{"fedora", "files myhostname resolve [!UNAVAIL=return] dns", map[string]string{"fallback": "info"}},
+		{"default", "", map[string]string{"nohosts": "warn", "fallback": "info"}},
+		{"files return", "files [NOTFOUND=return] dns", map[string]string{"fallback": "info"}},
+		{"never", "files [!SUCCESS=return] dns", map[string]string{"unreachable": "error"}},
+	}
+	xrs := ParseResolv("nameserver 192.0.2.1\n", false)
+	for _, tt := range tests {
+		text := ""
+		if len(tt.hosts) > 0 {
+			text = "hosts: " + tt.hosts + "\n"
+		}
+		got := map[string]string{}
+		for _, audit := range Audit(xrs, etcnsswitch.ParseNsswitch(text, false), nil, nil) {
+			if (audit.Check_ == "nohosts") || (audit.Check_ == "fallback") || (audit.Check_ == "unreachable") {
+				got[audit.Check_] = audit.Severity_
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for check, severity := range tt.want {
+			if got[check] != severity {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseResolvNameserver(t *testing.T) {
+	xrs := ParseResolv("nameserver 192.0.2.1 192.0.2.9\nnameserver 192.0.2.2 # office\nnameserver\nnameserver 192.0.2.3 ; lab\nnameserver 192.0.2.4\n", false)
+	want := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"}
+	got := xrs.Nameservers()
+	if len(got) != len(want) {
+		t.Fatalf("Nameservers() = %v, want %v", got, want)
+	}
+	for ii := range want {
+		if got[ii] != want[ii] {
+			t.Fatalf("Nameservers() = %v, want %v", got, want)
+		}
+	}
+	audits := Audit(xrs, etcnsswitch.ParseNsswitch("hosts: files dns\n", false), nil, nil)
+	ignored := 0
+	for _, audit := range audits {
+		if audit.Check_ == "badnameserver" {
+			t.Errorf("unexpected %s", audit.Sprint())
+		}
+		if audit.Check_ == "ignorednameserver" {
+			ignored++
+		}
+	}
+	if ignored != 1 {
+		t.Errorf("got %d ignorednameserver findings, want 1", ignored)
+	}
+}
@@ -0,0 +1,179 @@
+// Package etcresolv extracts useful info from /etc/resolv.conf on linux, and audits the resolver configuration as a whole
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package etcresolv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// Resolvdata holds resolv.conf data; lists are pipe separated for csv
+type Resolvdata struct {
+	Nameservers_ string // in file order, including any beyond the 3 the resolver uses
+	Domain_      string // from a domain line, if it came after any search line
+	Search_      string // from the last search line, if it came after any domain line
+	Sortlist_    string
+	Options_     string         // e.g. ndots:2|timeout:1|rotate
+	Lineno_      map[string]int // keyword -> last line it appeared on, from 1
+}
+
+const (
+	names     = "Nameservers,Domain,Search,Sortlist,Options"
+	hdrprefix = ",xrs."
+	semi      = ";"
+	// Maxns is how many nameservers the glibc resolver uses, later ones are ignored
+	Maxns = 3
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// Header is generic
+func Header() string { return headerString }
+
+// Csv is generic
+func (self *Resolvdata) Csv() string {
+	if self == nil {
+		return commaString
+	}
+	return fmt.Sprintf(pctString, self.Nameservers_, self.Domain_, self.Search_, self.Sortlist_, strings.Replace(self.Options_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Resolvdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctString, self.Nameservers_, self.Domain_, self.Search_, self.Sortlist_, self.Options_)
+}
+
+// Print is generic
+func (self *Resolvdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// New is generic
+func New() *Resolvdata { return &Resolvdata{Lineno_: map[string]int{}} }
+
+// splitPipes splits a pipe separated list, [] for ""
+func splitPipes(_str string) []string {
+	if len(_str) == 0 {
+		return []string{}
+	}
+	return strings.Split(_str, "|")
+}
+
+// Nameservers lists the nameservers in file order
+func (self *Resolvdata) Nameservers() []string { return splitPipes(self.Nameservers_) }
+
+// Searchlist is the effective search list: the search domains, or else the domain, as the resolver applies them
+func (self *Resolvdata) Searchlist() []string {
+	if len(self.Search_) > 0 {
+		return splitPipes(self.Search_)
+	}
+	return splitPipes(self.Domain_)
+}
+
+// Option returns the value of an option, e.g. Option("ndots") is "2" for ndots:2, and whether it is set
+func (self *Resolvdata) Option(_key string) (string, bool) {
+	for _, opt := range splitPipes(self.Options_) {
+		kv := strings.SplitN(opt, ":", 2)
+		if kv[0] == _key {
+			if len(kv) > 1 {
+				return kv[1], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// ParseResolv parses resolv.conf text; # and ; start comments, domain and search override each other, the last one winning
+func ParseResolv(_text string, _verbose bool) *Resolvdata {
+	xrs := New()
+	nameservers, options := []string{}, []string{}
+	for ii, line := range strings.Split(_text, "\n") {
+		if jj := strings.IndexAny(line, "#;"); jj >= 0 {
+			line = line[:jj]
+		}
+		items := strings.Fields(line)
+		if len(items) == 0 {
+			continue
+		}
+		switch items[0] {
+		case "nameserver":
+			if len(items) > 1 {
+				nameservers = append(nameservers, items[1]) // one address per line, as glibc reads it
+			}
+		case "domain":
+			xrs.Domain_ = strings.Join(items[1:], "|")
+			xrs.Search_ = ""
+		case "search":
+			xrs.Search_ = strings.Join(items[1:], "|")
+			xrs.Domain_ = ""
+		case "sortlist":
+			xrs.Sortlist_ = strings.Join(items[1:], "|")
+		case "options":
+			options = append(options, items[1:]...)
+		default:
+			if _verbose {
+				fmt.Printf("line%d: unknown keyword: %s\n", ii+1, line)
+			}
+			continue
+		}
+		xrs.Lineno_[items[0]] = ii + 1
+	}
+	xrs.Nameservers_ = strings.Join(nameservers, "|")
+	xrs.Options_ = strings.Join(options, "|")
+	return xrs
+}
+
+// ReadResolv reads and parses a resolv.conf file
+func ReadResolv(_path string, _verbose bool) (*Resolvdata, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResolv(string(buf), _verbose), nil
+}
+
+// Resolv extracts /etc/resolv.conf; an absent file gives an empty record, which the resolver treats as nameserver 127.0.0.1
+func Resolv(_verbose bool) *Resolvdata {
+	xrs, err := ReadResolv("/etc/resolv.conf", _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Resolv: %s\n", err)
+		}
+		return New()
+	}
+	if _verbose {
+		xrs.Print()
+	}
+	return xrs
+}
+
+// validNameserver tells whether a nameserver is an address, possibly with an ipv6 zone, e.g. fe80::1%eth0
+func validNameserver(_ns string) bool {
+	if ii := strings.Index(_ns, "%"); ii > 0 {
+		_ns = _ns[:ii]
+	}
+	return net.ParseIP(_ns) != nil
+}
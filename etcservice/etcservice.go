@@ -1,4 +1,4 @@
-// Package etcservices extracts useful info from the service command on linux
+// Package etcservices extracts useful info from the service command, or systemctl on systemd boxes, on linux
 //
 // Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
 package etcservice
@@ -12,13 +12,18 @@ import (
 
 // Servicedata hold service data
 type Servicedata struct {
-	Service_ string
-	Status_  string
-	Pid_     string
+	Service_  string
+	Status_   string // running, stopped etc as service --status-all says, mapped from the systemd states on systemd boxes
+	Pid_      string // MainPID on systemd boxes
+	Load_     string // systemd only: loaded, not-found, masked, not-loaded
+	Active_   string // systemd only: active, inactive, failed ...
+	Sub_      string // systemd only: running, exited, dead ...
+	Enabled_  string // enabled, disabled, static, masked ... on systemd; enabled or disabled in the default runlevel on SysV, see Services
+	Unitfile_ string // systemd only: e.g. /usr/lib/systemd/system/sshd.service
 }
 
 const (
-	names     = "Service,Status,Pid"
+	names     = "Service,Status,Pid,Load,Active,Sub,Enabled,Unitfile"
 	hdrprefix = ",svc."
 	semi      = ";"
 )
@@ -69,7 +74,7 @@ func (self *Servicedata) Csv() string {
 	if self == nil {
 		return commaString
 	}
-	return fmt.Sprintf(pctString, self.Service_, self.Status_, self.Pid_)
+	return fmt.Sprintf(pctString, self.Service_, self.Status_, self.Pid_, self.Load_, self.Active_, self.Sub_, self.Enabled_, self.Unitfile_)
 }
 
 // Sprint is generic
@@ -77,7 +82,7 @@ func (self *Servicedata) Sprint() string {
 	if self == nil {
 		return ""
 	}
-	return fmt.Sprintf(namePctString, self.Service_, self.Status_, self.Pid_)
+	return fmt.Sprintf(namePctString, self.Service_, self.Status_, self.Pid_, self.Load_, self.Active_, self.Sub_, self.Enabled_, self.Unitfile_)
 }
 
 // Print is generic
@@ -0,0 +1,153 @@
+package etcservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Unithealthdata holds the systemctl show properties telling a flapping or failed unit from a healthy one
+type Unithealthdata struct {
+	Unit_           string // full unit name, e.g. sshd.service
+	Active_         string // ActiveState
+	Sub_            string // SubState
+	Result_         string // success, exit-code, signal, timeout, core-dump, start-limit-hit ...
+	Nrestarts_      string // automatic restarts since boot (or since the last reset-failed); empty before systemd 235
+	Execmainstatus_ string // exit status or signal number of the main process's last run
+	Activeenter_    string // ActiveEnterTimestamp, when it last became active; recent for a flapping unit
+	Finding_        string // failed, restartloop or ok, see Report
+}
+
+const (
+	namesHealth     = "Unit,Active,Sub,Result,Nrestarts,Execmainstatus,Activeenter,Finding"
+	hdrprefixHealth = ",svh."
+)
+
+var (
+	headerStringHealth  string
+	commaStringHealth   string
+	pctStringHealth     string
+	namePctStringHealth string
+	healthProperties    = []string{"Id", "ActiveState", "SubState", "Result", "NRestarts", "ExecMainStatus", "ActiveEnterTimestamp"}
+)
+
+// init  is generic
+func init() {
+	headerStringHealth = (hdrprefixHealth + strings.Join(strings.Split(namesHealth, ","), hdrprefixHealth))[1:]
+	commaStringHealth = strings.Repeat(",", strings.Count(headerStringHealth, ","))
+	pctStringHealth = strings.Repeat(",%s", 1+strings.Count(headerStringHealth, ","))[1:]
+	namePctStringHealth = strings.Replace(namesHealth, ",", "=%s ", -1) + "=%s\n"
+}
+
+// HealthHeader is generic
+func HealthHeader() string { return headerStringHealth }
+
+// Csv is generic
+func (self *Unithealthdata) Csv() string {
+	if self == nil {
+		return commaStringHealth
+	}
+	return fmt.Sprintf(pctStringHealth, self.Unit_, self.Active_, self.Sub_, self.Result_, self.Nrestarts_, self.Execmainstatus_, strings.Replace(self.Activeenter_, ",", semi, -1), self.Finding_)
+}
+
+// Sprint is generic
+func (self *Unithealthdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringHealth, self.Unit_, self.Active_, self.Sub_, self.Result_, self.Nrestarts_, self.Execmainstatus_, self.Activeenter_, self.Finding_)
+}
+
+// Print is generic
+func (self *Unithealthdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// SortedKeys_String2PtrUnithealthdata is generic
+func SortedKeys_String2PtrUnithealthdata(_mp *map[string]*Unithealthdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ListUnitNames lists the unit names of systemctl list-units output, json or text, of any unit type
+func ListUnitNames(_out string) []string {
+	units := []string{}
+	if isJSON(_out) {
+		listed := []*listUnit{}
+		if err := json.Unmarshal([]byte(_out), &listed); err == nil {
+			for _, unit := range listed {
+				units = append(units, unit.Unit)
+			}
+			return units
+		}
+	}
+	for _, line := range strings.Split(_out, "\n") {
+		items := strings.Fields(strings.TrimLeft(line, "●* "))
+		if (len(items) >= 4) && strings.Contains(items[0], ".") && !strings.Contains(items[0], "=") {
+			units = append(units, items[0])
+		}
+	}
+	return units
+}
+
+// HealthFromShow turns parsed systemctl show output (see ParseShow) into health rows, keyed by unit
+func HealthFromShow(_show map[string]map[string]string) (smap map[string]*Unithealthdata) {
+	smap = make(map[string]*Unithealthdata)
+	for id, props := range _show {
+		uh := &Unithealthdata{Unit_: id, Active_: props["ActiveState"], Sub_: props["SubState"], Result_: props["Result"], Nrestarts_: props["NRestarts"], Execmainstatus_: props["ExecMainStatus"], Activeenter_: props["ActiveEnterTimestamp"]}
+		smap[id] = uh
+	}
+	return smap
+}
+
+// UnitHealth collects the health properties of every loaded unit from systemctl show, keyed by unit
+func UnitHealth(_verbose bool) (smap map[string]*Unithealthdata) {
+	out := systemctlList("list-units --all", "--no-legend --plain", _verbose)
+	smap = HealthFromShow(systemctlShow(ListUnitNames(out), healthProperties, _verbose))
+	if _verbose {
+		for _, kk := range SortedKeys_String2PtrUnithealthdata(&smap) {
+			smap[kk].Print()
+		}
+	}
+	return smap
+}
+
+// Report sets Finding_ and lists the units that are failed, or that systemd restarted more than _maxRestarts times, in unit order.
+// A unit that keeps crashing and being restarted looks active in a single snapshot, only NRestarts gives it away
+func Report(_smap map[string]*Unithealthdata, _maxRestarts int) []*Unithealthdata {
+	report := []*Unithealthdata{}
+	for _, kk := range SortedKeys_String2PtrUnithealthdata(&_smap) {
+		uh := _smap[kk]
+		nrestarts, _ := strconv.Atoi(uh.Nrestarts_)
+		switch {
+		case uh.Active_ == "failed":
+			uh.Finding_ = "failed"
+		case nrestarts > _maxRestarts:
+			uh.Finding_ = "restartloop"
+		default:
+			uh.Finding_ = "ok"
+			continue
+		}
+		report = append(report, uh)
+	}
+	return report
+}
+
+// FailedUnits is Report over UnitHealth, empty on boxes without systemd
+func FailedUnits(_maxRestarts int, _verbose bool) []*Unithealthdata {
+	if !IsSystemd() {
+		return []*Unithealthdata{}
+	}
+	return Report(UnitHealth(_verbose), _maxRestarts)
+}
@@ -0,0 +1,133 @@
+package etcservice
+
+import (
+	"strings"
+	"testing"
+)
+
+// healthShow is systemctl show --property=Id,ActiveState,SubState,Result,NRestarts,ExecMainStatus,ActiveEnterTimestamp for
+// a failed unit, a unit systemd keeps restarting, a healthy one and one from before systemd 235 without NRestarts
+const healthShow = `Id=kdump.service
+ActiveState=failed
+SubState=failed
+Result=exit-code
+NRestarts=0
+ExecMainStatus=1
+ActiveEnterTimestamp=
+
+Id=myapp.service
+ActiveState=active
+SubState=running
+Result=success
+NRestarts=57
+ExecMainStatus=139
+ActiveEnterTimestamp=Mon 2026-10-19 09:14:02 UTC
+
+Id=sshd.service
+ActiveState=active
+SubState=running
+Result=success
+NRestarts=0
+ExecMainStatus=0
+ActiveEnterTimestamp=Sun 2026-10-04 22:01:13 UTC
+
+Id=crond.service
+ActiveState=active
+SubState=running
+Result=success
+ExecMainStatus=0
+ActiveEnterTimestamp=Sun 2026-10-04 22:01:13 UTC
+
+Id=flappy.service
+ActiveState=activating
+SubState=auto-restart
+Result=exit-code
+NRestarts=5
+ExecMainStatus=2
+ActiveEnterTimestamp=Mon 2026-10-19 09:10:00 UTC
+`
+
+func TestListUnitNames(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"json", `[{"unit":"-.mount","load":"loaded","active":"active","sub":"mounted"},{"unit":"sshd.service","load":"loaded","active":"active","sub":"running"}]`, "-.mount sshd.service"},
+		{"plain", "-.mount loaded active mounted Root Mount\nsshd.service loaded active running OpenSSH server daemon\n", "-.mount sshd.service"},
+		{"centos7", `  UNIT                 LOAD   ACTIVE SUB     DESCRIPTION
+  -.mount              loaded active mounted /
+● kdump.service        loaded failed failed  Crash recovery kernel arming
+  sshd.service         loaded active running OpenSSH server daemon
+  sockets.target       loaded active active  Sockets
+
+LOAD   = Reflects whether the unit definition was properly loaded.
+ACTIVE = The high-level unit activation state, i.e. generalization of SUB.
+SUB    = The low-level unit activation state, values depend on unit type.
+
+4 loaded units listed. Pass --all to see loaded but inactive units, too.
+To show all installed unit files use 'systemctl list-unit-files'.
+`, "-.mount kdump.service sshd.service sockets.target"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(ListUnitNames(tt.out), " "); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHealthFromShow(t *testing.T) {
+	smap := HealthFromShow(ParseShow(healthShow))
+	if len(smap) != 5 {
+		t.Fatalf("got %d units, want 5", len(smap))
+	}
+	want := Unithealthdata{Unit_: "myapp.service", Active_: "active", Sub_: "running", Result_: "success", Nrestarts_: "57", Execmainstatus_: "139", Activeenter_: "Mon 2026-10-19 09:14:02 UTC"}
+	if got := smap["myapp.service"]; (got == nil) || (*got != want) {
+		t.Errorf("myapp = %+v, want %+v", got, want)
+	}
+	if got := smap["crond.service"].Nrestarts_; got != "" {
+		t.Errorf("crond Nrestarts = %q, want empty", got)
+	}
+	if got := smap["kdump.service"].Csv(); strings.Count(got, ",") != strings.Count(HealthHeader(), ",") {
+		t.Errorf("Csv %q does not match the header", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		maxRestarts int
+		want        string
+	}{
+		{3, "flappy.service:restartloop kdump.service:failed myapp.service:restartloop"},
+		{10, "kdump.service:failed myapp.service:restartloop"},
+		{100, "kdump.service:failed"},
+	}
+	for _, tt := range tests {
+		smap := HealthFromShow(ParseShow(healthShow))
+		got := []string{}
+		for _, uh := range Report(smap, tt.maxRestarts) {
+			got = append(got, uh.Unit_+":"+uh.Finding_)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("max %d: got %v, want %s", tt.maxRestarts, got, tt.want)
+		}
+		for _, unit := range []string{"sshd.service", "crond.service"} {
+			if smap[unit].Finding_ != "ok" {
+				t.Errorf("max %d: %s finding %q, want ok", tt.maxRestarts, unit, smap[unit].Finding_)
+			}
+		}
+	}
+	if got := Report(map[string]*Unithealthdata{}, 0); len(got) != 0 {
+		t.Errorf("Report of nothing = %v", got)
+	}
+}
+
+func TestFailedUnitsWithoutSystemd(t *testing.T) {
+	saved := sysRunSystemd
+	defer func() { sysRunSystemd = saved }()
+	sysRunSystemd = "/nonexistent/run/systemd/system"
+	if got := FailedUnits(0, false); (got == nil) || (len(got) != 0) {
+		t.Errorf("FailedUnits = %v, want empty", got)
+	}
+}
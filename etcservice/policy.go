@@ -0,0 +1,246 @@
+package etcservice
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Wanted states of a policy rule
+const (
+	WantOn       = "on"       // running and enabled at boot
+	WantOff      = "off"      // stopped and disabled at boot, or not installed
+	WantRunning  = "running"  // running, enablement not checked
+	WantEnabled  = "enabled"  // enabled at boot, current state not checked
+	WantStopped  = "stopped"  // not running, enablement not checked
+	WantDisabled = "disabled" // not enabled at boot, current state not checked
+	WantAny      = "any"      // known, so not reported as unknown, but not checked
+)
+
+// Policyrule is one line of a service policy file: role,service,want
+// Role * applies to every host; service may be a glob such as avahi*
+type Policyrule struct {
+	Role_    string
+	Service_ string
+	Want_    string
+	Lineno_  int
+}
+
+// Policy is an ordered list of rules; for a service matched by several rules of a host's roles, the last matching line wins
+type Policy struct {
+	Path_  string
+	Rules_ []*Policyrule
+}
+
+// Findingdata is one deviation of a host from the policy
+type Findingdata struct {
+	Host_     string
+	Role_     string // the role whose rule was broken, empty for unknown services
+	Service_  string
+	Want_     string
+	Status_   string // the service's Status_, or missing
+	Enabled_  string // the service's Enabled_, empty where enablement is unknown
+	Finding_  string // missing, stopped, disabled, running, enabled or unknown
+	Severity_ string // error for required services, warn for ones that must be off, info for unknown
+	Lineno_   string // the policy line
+}
+
+const (
+	namesFinding     = "Host,Role,Service,Want,Status,Enabled,Finding,Severity,Lineno"
+	hdrprefixFinding = ",svp."
+)
+
+var (
+	headerStringFinding  string
+	commaStringFinding   string
+	pctStringFinding     string
+	namePctStringFinding string
+	wants                = map[string]bool{WantOn: true, WantOff: true, WantRunning: true, WantEnabled: true, WantStopped: true, WantDisabled: true, WantAny: true}
+)
+
+// init  is generic
+func init() {
+	headerStringFinding = (hdrprefixFinding + strings.Join(strings.Split(namesFinding, ","), hdrprefixFinding))[1:]
+	commaStringFinding = strings.Repeat(",", strings.Count(headerStringFinding, ","))
+	pctStringFinding = strings.Repeat(",%s", 1+strings.Count(headerStringFinding, ","))[1:]
+	namePctStringFinding = strings.Replace(namesFinding, ",", "=%s ", -1) + "=%s\n"
+}
+
+// FindingHeader is generic
+func FindingHeader() string { return headerStringFinding }
+
+// Csv is generic
+func (self *Findingdata) Csv() string {
+	if self == nil {
+		return commaStringFinding
+	}
+	return fmt.Sprintf(pctStringFinding, self.Host_, self.Role_, self.Service_, self.Want_, self.Status_, self.Enabled_, self.Finding_, self.Severity_, self.Lineno_)
+}
+
+// Sprint is generic
+func (self *Findingdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringFinding, self.Host_, self.Role_, self.Service_, self.Want_, self.Status_, self.Enabled_, self.Finding_, self.Severity_, self.Lineno_)
+}
+
+// Print is generic
+func (self *Findingdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// ParsePolicy parses policy text, one role,service,want rule per line, e.g. *,sshd,on or server,cups*,off; # starts a comment line
+func ParsePolicy(_text string, _verbose bool) (*Policy, error) {
+	policy := new(Policy)
+	for ii, lineraw := range strings.Split(_text, "\n") {
+		line := strings.TrimSpace(lineraw)
+		if (len(line) == 0) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.Split(line, ",")
+		for jj := range items {
+			items[jj] = strings.TrimSpace(items[jj])
+		}
+		if (len(items) != 3) || (len(items[0]) == 0) || !wants[items[2]] {
+			return nil, fmt.Errorf("etcservice: line%d: bad policy rule, want role,service,on|off|running|enabled|stopped|disabled|any: %s", ii+1, line)
+		}
+		if _, err := path.Match(items[1], ""); err != nil {
+			return nil, fmt.Errorf("etcservice: line%d: bad service pattern %s: %s", ii+1, items[1], err)
+		}
+		policy.Rules_ = append(policy.Rules_, &Policyrule{Role_: items[0], Service_: items[1], Want_: items[2], Lineno_: ii + 1})
+		if _verbose {
+			fmt.Printf("ParsePolicy: line%d: %s\n", ii+1, line)
+		}
+	}
+	return policy, nil
+}
+
+// LoadPolicy reads and parses a policy file
+func LoadPolicy(_path string, _verbose bool) (*Policy, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(string(buf), _verbose)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", _path, err)
+	}
+	policy.Path_ = _path
+	return policy, nil
+}
+
+// applies tells whether a rule is for one of the roles
+func (self *Policyrule) applies(_roles []string) bool {
+	if self.Role_ == "*" {
+		return true
+	}
+	for _, role := range _roles {
+		if role == self.Role_ {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule returns the rule deciding a service for a host with these roles, nil if none
+func (self *Policy) Rule(_roles []string, _service string) *Policyrule {
+	var found *Policyrule
+	for _, rule := range self.Rules_ {
+		if matched, _ := path.Match(rule.Service_, _service); matched && rule.applies(_roles) {
+			found = rule
+		}
+	}
+	return found
+}
+
+// isRunning tells whether a service is up, as far as Status_ says
+func (self *Servicedata) isRunning() bool {
+	return (self.Status_ == "running") || (self.Active_ == "active") || (self.Active_ == "activating") || (self.Active_ == "reloading")
+}
+
+// isEnabled tells whether a service starts at boot; the second result is false when that is unknown
+func (self *Servicedata) isEnabled() (bool, bool) {
+	switch self.Enabled_ {
+	case "":
+		return false, false
+	case "enabled", "enabled-runtime", "static", "indirect", "generated", "alias", "linked", "linked-runtime":
+		return true, true
+	}
+	return false, true // disabled, masked, masked-runtime, bad
+}
+
+// Evaluate checks the services of a host against the policy rules of its roles.
+// Rules naming a literal service that is not installed report missing (unless off or any); running services no rule covers are reported as unknown
+func Evaluate(_host string, _roles []string, _policy *Policy, _smap map[string]*Servicedata) []*Findingdata {
+	findings := []*Findingdata{}
+	add := func(_rule *Policyrule, _svc *Servicedata, _finding, _severity string) {
+		fd := &Findingdata{Host_: _host, Service_: _svc.Service_, Status_: _svc.Status_, Enabled_: _svc.Enabled_, Finding_: _finding, Severity_: _severity}
+		if _rule != nil {
+			fd.Role_, fd.Want_, fd.Lineno_ = _rule.Role_, _rule.Want_, fmt.Sprint(_rule.Lineno_)
+		}
+		findings = append(findings, fd)
+	}
+	for _, rule := range _policy.Rules_ {
+		if !rule.applies(_roles) || strings.ContainsAny(rule.Service_, "*?[") || (_smap[rule.Service_] != nil) {
+			continue
+		}
+		if (rule.Want_ != WantOff) && (rule.Want_ != WantStopped) && (rule.Want_ != WantDisabled) && (rule.Want_ != WantAny) && (_policy.Rule(_roles, rule.Service_) == rule) {
+			add(rule, &Servicedata{Service_: rule.Service_, Status_: "missing"}, "missing", "error")
+		}
+	}
+	for _, kk := range SortedKeys_String2PtrServicedata(&_smap) {
+		svc := _smap[kk]
+		rule := _policy.Rule(_roles, svc.Service_)
+		if rule == nil {
+			if svc.isRunning() {
+				add(nil, svc, "unknown", "info")
+			}
+			continue
+		}
+		running := svc.isRunning()
+		enabled, known := svc.isEnabled()
+		switch rule.Want_ {
+		case WantOn, WantRunning:
+			if !running {
+				add(rule, svc, "stopped", "error")
+			}
+		case WantOff, WantStopped:
+			if running {
+				add(rule, svc, "running", "warn")
+			}
+		}
+		switch rule.Want_ {
+		case WantOn, WantEnabled:
+			if known && !enabled {
+				add(rule, svc, "disabled", "error")
+			}
+		case WantOff, WantDisabled:
+			if known && enabled && (svc.Enabled_ != "static") {
+				add(rule, svc, "enabled", "warn")
+			}
+		}
+	}
+	return findings
+}
+
+// CheckPolicy evaluates this box's services against a policy file for the given roles
+func CheckPolicy(_path string, _roles []string, _verbose bool) ([]*Findingdata, error) {
+	policy, err := LoadPolicy(_path, _verbose)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	findings := Evaluate(host, _roles, policy, Services(_verbose))
+	if _verbose {
+		for _, fd := range findings {
+			fd.Print()
+		}
+	}
+	return findings, nil
+}
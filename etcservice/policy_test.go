@@ -0,0 +1,149 @@
+package etcservice
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		text  string
+		rules int
+		err   string // part of the error, empty for none
+	}{
+		{"*,sshd,on\n", 1, ""},
+		{"# comment\n\n  *, sshd , on  \nserver,cups*,off\n", 2, ""},
+		{"*,sshd,any\n*,x,running\n*,x,enabled\n*,x,stopped\n*,x,disabled\n", 5, ""},
+		{"*,sshd\n", 0, "line1: bad policy rule"},
+		{"*,sshd,on,extra\n", 0, "line1: bad policy rule"},
+		{"*,sshd,yes\n", 0, "line1: bad policy rule"},
+		{"*,sshd,On\n", 0, "line1: bad policy rule"},
+		{"*,sshd,on\n,sshd,on\n", 0, "line2: bad policy rule"},
+		{"# ok\n*,[ab,on\n", 0, "line2: bad service pattern"},
+	}
+	for _, tt := range tests {
+		policy, err := ParsePolicy(tt.text, false)
+		if len(tt.err) > 0 {
+			if (err == nil) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: err = %v, want %s", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.text, err)
+			continue
+		}
+		if len(policy.Rules_) != tt.rules {
+			t.Errorf("%q: got %d rules, want %d", tt.text, len(policy.Rules_), tt.rules)
+		}
+	}
+	policy, _ := ParsePolicy("# c\n  *, sshd , on  \n", false)
+	if rule := policy.Rules_[0]; (rule.Role_ != "*") || (rule.Service_ != "sshd") || (rule.Want_ != WantOn) || (rule.Lineno_ != 2) {
+		t.Errorf("rule = %+v", rule)
+	}
+}
+
+func TestRule(t *testing.T) {
+	policy, err := ParsePolicy("*,avahi*,off\n*,cups,off\nprint,cups,on\ndesktop,avahi-daemon,on\n", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		roles   []string
+		service string
+		lineno  int // 0 for no rule
+	}{
+		{nil, "cups", 2},
+		{[]string{"print"}, "cups", 3},
+		{[]string{"desktop"}, "cups", 2},
+		{nil, "avahi-daemon", 1},
+		{[]string{"desktop"}, "avahi-daemon", 4},
+		{[]string{"desktop"}, "avahi-dnsconfd", 1},
+		{[]string{"print"}, "sshd", 0},
+	}
+	for _, tt := range tests {
+		rule := policy.Rule(tt.roles, tt.service)
+		switch {
+		case (rule == nil) && (tt.lineno != 0):
+			t.Errorf("%v %s: no rule, want line%d", tt.roles, tt.service, tt.lineno)
+		case (rule != nil) && (rule.Lineno_ != tt.lineno):
+			t.Errorf("%v %s: line%d, want line%d", tt.roles, tt.service, rule.Lineno_, tt.lineno)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	running := func(_name, _enabled string) *Servicedata {
+		return &Servicedata{Service_: _name, Status_: "running", Active_: "active", Sub_: "running", Enabled_: _enabled}
+	}
+	stopped := func(_name, _enabled string) *Servicedata {
+		return &Servicedata{Service_: _name, Status_: "stopped", Active_: "inactive", Sub_: "dead", Enabled_: _enabled}
+	}
+	tests := []struct {
+		name   string
+		policy string
+		svc    *Servicedata // nil for not installed
+		want   string       // finding:severity, comma separated, empty for none
+	}{
+		{"on ok", "*,sshd,on", running("sshd", "enabled"), ""},
+		{"on missing", "*,sshd,on", nil, "missing:error"},
+		{"on stopped", "*,sshd,on", stopped("sshd", "enabled"), "stopped:error"},
+		{"on stopped disabled", "*,sshd,on", stopped("sshd", "disabled"), "stopped:error,disabled:error"},
+		{"on enablement unknown", "*,sshd,on", running("sshd", ""), ""},
+		{"on static", "*,sshd,on", running("sshd", "static"), ""},
+		{"on masked", "*,sshd,on", running("sshd", "masked"), "disabled:error"},
+		{"running ignores enablement", "*,sshd,running", running("sshd", "disabled"), ""},
+		{"enabled ignores state", "*,sshd,enabled", stopped("sshd", "enabled"), ""},
+		{"enabled missing", "*,sshd,enabled", nil, "missing:error"},
+		{"off ok", "*,telnet,off", stopped("telnet", "disabled"), ""},
+		{"off not installed", "*,telnet,off", nil, ""},
+		{"off running", "*,telnet,off", running("telnet", "disabled"), "running:warn"},
+		{"off enabled", "*,telnet,off", stopped("telnet", "enabled"), "enabled:warn"},
+		{"off static", "*,telnet,off", stopped("telnet", "static"), ""},
+		{"off running enabled", "*,telnet,off", running("telnet", "enabled"), "running:warn,enabled:warn"},
+		{"stopped ignores enablement", "*,telnet,stopped", stopped("telnet", "enabled"), ""},
+		{"disabled ignores state", "*,telnet,disabled", running("telnet", "disabled"), ""},
+		{"any", "*,sshd,any", running("sshd", "enabled"), ""},
+		{"any not installed", "*,sshd,any", nil, ""},
+		{"unknown running", "*,sshd,on", running("cups", "enabled"), "missing:error,unknown:info"},
+		{"unknown stopped", "*,sshd,any", stopped("cups", "enabled"), ""},
+		{"glob not missing", "*,avahi*,on", nil, ""},
+		{"glob stopped", "*,avahi*,on", stopped("avahi-daemon", "enabled"), "stopped:error"},
+		{"other role", "desktop,cups,on", running("cups", "enabled"), "unknown:info"},
+		{"later line wins", "*,cups,on\nprint,cups,off", running("cups", "enabled"), "running:warn,enabled:warn"},
+		{"later line wins missing", "*,cups,on\nprint,cups,off", nil, ""},
+	}
+	for _, tt := range tests {
+		policy, err := ParsePolicy(tt.policy, false)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		smap := map[string]*Servicedata{}
+		if tt.svc != nil {
+			smap[tt.svc.Service_] = tt.svc
+		}
+		got := []string{}
+		for _, fd := range Evaluate("box1", []string{"print"}, policy, smap) {
+			got = append(got, fd.Finding_+":"+fd.Severity_)
+			if fd.Host_ != "box1" {
+				t.Errorf("%s: host %s", tt.name, fd.Host_)
+			}
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateFinding(t *testing.T) {
+	policy, _ := ParsePolicy("# roles\nprint,cups,on\n", false)
+	smap := map[string]*Servicedata{"cups": {Service_: "cups", Status_: "stopped", Active_: "inactive", Enabled_: "disabled"}}
+	findings := Evaluate("box1", []string{"print"}, policy, smap)
+	want := Findingdata{Host_: "box1", Role_: "print", Service_: "cups", Want_: "on", Status_: "stopped", Enabled_: "disabled", Finding_: "stopped", Severity_: "error", Lineno_: "2"}
+	if (len(findings) != 2) || (*findings[0] != want) {
+		t.Fatalf("findings = %v", findings)
+	}
+	if got := findings[0].Csv(); strings.Count(got, ",") != strings.Count(FindingHeader(), ",") {
+		t.Errorf("Csv %q does not match the header", got)
+	}
+}
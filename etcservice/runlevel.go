@@ -0,0 +1,337 @@
+package etcservice
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Runleveldata says in which runlevels a service starts at boot, and whether it runs now but will not come back after a reboot
+type Runleveldata struct {
+	Service_   string
+	Source_    string    // chkconfig, rcd (rc?.d symlinks), xinetd or systemd
+	Rl_        [7]string // runlevels 0-6: on, off, or empty when unknown
+	Targets_   string    // systemd only: the targets whose .wants dirs hold the service, pipe separated
+	Wantedby_  string    // systemd only: WantedBy and RequiredBy of the [Install] section, i.e. what enabling does
+	Bootstart_ string    // yes if it starts in the default runlevel/target, else no
+	Status_    string    // the current Status_, see Join
+	Vanishes_  string    // yes if it runs now but Bootstart_ is no
+}
+
+const (
+	namesRunlevel     = "Service,Source,Rl0,Rl1,Rl2,Rl3,Rl4,Rl5,Rl6,Targets,Wantedby,Bootstart,Status,Vanishes"
+	hdrprefixRunlevel = ",svr."
+)
+
+var (
+	headerStringRunlevel  string
+	commaStringRunlevel   string
+	pctStringRunlevel     string
+	namePctStringRunlevel string
+	etcRcd                = "/etc/rc.d"
+	etcInittab            = "/etc/inittab"
+	// systemdUnitDirs are searched in systemd's order of precedence, as in etcfstab; the generator dirs hold the wants links
+	// systemd-sysv-generator makes for SysV init scripts, e.g. network on CentOS 7
+	systemdUnitDirs = []string{
+		"/etc/systemd/system",
+		"/run/systemd/system",
+		"/run/systemd/generator.early",
+		"/run/systemd/generator",
+		"/run/systemd/generator.late",
+		"/usr/lib/systemd/system",
+		"/lib/systemd/system",
+	}
+	rcLinkRegexp         = regexp.MustCompile(`^([SK])[0-9]+(.+)$`)
+	runlevelTargetRegexp = regexp.MustCompile(`^runlevel([0-6])\.target$`)
+	// targetRunlevels maps the targets services are usually wanted by to the SysV runlevels that reach them; other targets count as multi-user
+	targetRunlevels = map[string][]int{
+		"poweroff.target":   {0},
+		"halt.target":       {0},
+		"rescue.target":     {1},
+		"emergency.target":  {1},
+		"sysinit.target":    {1, 2, 3, 4, 5},
+		"local-fs.target":   {1, 2, 3, 4, 5},
+		"multi-user.target": {2, 3, 4, 5},
+		"graphical.target":  {5},
+		"reboot.target":     {6},
+	}
+	runlevelTargets = map[string]int{"poweroff.target": 0, "rescue.target": 1, "multi-user.target": 3, "graphical.target": 5, "reboot.target": 6}
+)
+
+// init  is generic
+func init() {
+	headerStringRunlevel = (hdrprefixRunlevel + strings.Join(strings.Split(namesRunlevel, ","), hdrprefixRunlevel))[1:]
+	commaStringRunlevel = strings.Repeat(",", strings.Count(headerStringRunlevel, ","))
+	pctStringRunlevel = strings.Repeat(",%s", 1+strings.Count(headerStringRunlevel, ","))[1:]
+	namePctStringRunlevel = strings.Replace(namesRunlevel, ",", "=%s ", -1) + "=%s\n"
+}
+
+// RunlevelHeader is generic
+func RunlevelHeader() string { return headerStringRunlevel }
+
+// Csv is generic
+func (self *Runleveldata) Csv() string {
+	if self == nil {
+		return commaStringRunlevel
+	}
+	return fmt.Sprintf(pctStringRunlevel, self.Service_, self.Source_, self.Rl_[0], self.Rl_[1], self.Rl_[2], self.Rl_[3], self.Rl_[4], self.Rl_[5], self.Rl_[6], self.Targets_, self.Wantedby_, self.Bootstart_, self.Status_, self.Vanishes_)
+}
+
+// Sprint is generic
+func (self *Runleveldata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringRunlevel, self.Service_, self.Source_, self.Rl_[0], self.Rl_[1], self.Rl_[2], self.Rl_[3], self.Rl_[4], self.Rl_[5], self.Rl_[6], self.Targets_, self.Wantedby_, self.Bootstart_, self.Status_, self.Vanishes_)
+}
+
+// Print is generic
+func (self *Runleveldata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// SortedKeys_String2PtrRunleveldata is generic
+func SortedKeys_String2PtrRunleveldata(_mp *map[string]*Runleveldata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setBootstart fills Bootstart_ from the default runlevel
+func (self *Runleveldata) setBootstart(_default int) {
+	self.Bootstart_ = genutil.StrTernary((_default >= 0) && (_default <= 6) && (self.Rl_[_default] == "on"), "yes", "no")
+}
+
+// ParseChkconfig parses chkconfig --list, e.g. "sshd 0:off 1:off 2:on 3:on 4:on 5:on 6:off", and its xinetd section, e.g. "rsync: off"
+func ParseChkconfig(_out string, _default int, _verbose bool) (smap map[string]*Runleveldata) {
+	smap = make(map[string]*Runleveldata)
+	xinetd := false
+	for ii, line := range strings.Split(_out, "\n") {
+		items := strings.Fields(line)
+		switch {
+		case len(items) == 0:
+			continue
+		case strings.HasPrefix(line, "xinetd based services"):
+			xinetd = true
+		case xinetd && (len(items) == 2) && strings.HasSuffix(items[0], ":"):
+			rl := &Runleveldata{Service_: strings.TrimSuffix(items[0], ":"), Source_: "xinetd"}
+			rl.Bootstart_ = genutil.StrTernary(items[1] == "on", "yes", "no") // started on demand by xinetd, if xinetd itself starts
+			smap[rl.Service_] = rl
+		case (len(items) == 8) && strings.HasPrefix(items[1], "0:"):
+			rl := &Runleveldata{Service_: items[0], Source_: "chkconfig"}
+			for _, item := range items[1:] {
+				kv := strings.SplitN(item, ":", 2)
+				if (len(kv) == 2) && (len(kv[0]) == 1) && (kv[0][0] >= '0') && (kv[0][0] <= '6') {
+					rl.Rl_[kv[0][0]-'0'] = kv[1]
+				}
+			}
+			rl.setBootstart(_default)
+			smap[rl.Service_] = rl
+		default:
+			if _verbose {
+				fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
+			}
+		}
+	}
+	return smap
+}
+
+// ScanRcd reads the S and K symlinks of the rc0.d ... rc6.d dirs under _dir, e.g. /etc/rc.d/rc3.d/S55sshd means sshd is on in runlevel 3
+func ScanRcd(_dir string, _default int, _verbose bool) (smap map[string]*Runleveldata) {
+	smap = make(map[string]*Runleveldata)
+	for level := 0; level <= 6; level++ {
+		links, _ := filepath.Glob(filepath.Join(_dir, fmt.Sprintf("rc%d.d", level), "[SK]*"))
+		for _, link := range links {
+			mm := rcLinkRegexp.FindStringSubmatch(filepath.Base(link))
+			if mm == nil {
+				continue
+			}
+			rl := smap[mm[2]]
+			if rl == nil {
+				rl = &Runleveldata{Service_: mm[2], Source_: "rcd"}
+				smap[rl.Service_] = rl
+			}
+			rl.Rl_[level] = genutil.StrTernary(mm[1] == "S", "on", "off")
+		}
+	}
+	for _, rl := range smap {
+		rl.setBootstart(_default)
+		if _verbose {
+			fmt.Printf("ScanRcd: %s", rl.Sprint())
+		}
+	}
+	return smap
+}
+
+// SysvDefault reads the default runlevel from the initdefault line of /etc/inittab, 3 if there is none
+func SysvDefault() int {
+	buf, err := ioutil.ReadFile(etcInittab)
+	if err != nil {
+		return 3
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		items := strings.Split(strings.TrimSpace(line), ":")
+		if (len(items) >= 3) && (items[2] == "initdefault") && (len(items[1]) == 1) && (items[1][0] >= '0') && (items[1][0] <= '6') {
+			return int(items[1][0] - '0')
+		}
+	}
+	return 3
+}
+
+// SystemdDefault reads the runlevel of the default target, e.g. 5 for graphical.target, 3 if it cannot be told
+func SystemdDefault() int {
+	for _, dir := range systemdUnitDirs {
+		if target, err := os.Readlink(filepath.Join(dir, "default.target")); err == nil {
+			if level, ok := runlevelTargets[filepath.Base(target)]; ok {
+				return level
+			}
+			if mm := runlevelTargetRegexp.FindStringSubmatch(filepath.Base(target)); mm != nil {
+				return int(mm[1][0] - '0')
+			}
+			return 3
+		}
+	}
+	return 3
+}
+
+// installSection reads WantedBy and RequiredBy from the [Install] section of a unit file
+func installSection(_path string) []string {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil
+	}
+	wanted := []string{}
+	section := ""
+	for _, lineraw := range strings.Split(string(buf), "\n") {
+		line := strings.TrimSpace(lineraw)
+		switch {
+		case strings.HasPrefix(line, "["):
+			section = line
+		case section == "[Install]":
+			kv := strings.SplitN(line, "=", 2)
+			if (len(kv) == 2) && ((strings.TrimSpace(kv[0]) == "WantedBy") || (strings.TrimSpace(kv[0]) == "RequiredBy")) {
+				wanted = append(wanted, strings.Fields(kv[1])...)
+			}
+		}
+	}
+	return wanted
+}
+
+// ScanSystemd builds the matrix of systemd services from the .wants and .requires dirs of the targets, which is where enabling puts its symlinks.
+// A target's runlevels come from targetRunlevels, e.g. a service wanted by multi-user.target is on in 2, 3, 4 and 5
+func ScanSystemd(_dirs []string, _default int, _verbose bool) (smap map[string]*Runleveldata) {
+	smap = make(map[string]*Runleveldata)
+	for _, dir := range _dirs {
+		for _, pattern := range []string{"*.target.wants", "*.target.requires"} {
+			wantsdirs, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, wantsdir := range wantsdirs {
+				target := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(wantsdir), ".wants"), ".requires")
+				links, _ := filepath.Glob(filepath.Join(wantsdir, "*.service"))
+				for _, link := range links {
+					name := serviceName(filepath.Base(link))
+					rl := smap[name]
+					if rl == nil {
+						rl = &Runleveldata{Service_: name, Source_: "systemd"}
+						smap[name] = rl
+					}
+					if !strings.Contains("|"+rl.Targets_+"|", "|"+target+"|") {
+						rl.Targets_ = strings.TrimPrefix(rl.Targets_+"|"+target, "|")
+					}
+					levels, ok := targetRunlevels[target]
+					if !ok {
+						levels = targetRunlevels["multi-user.target"]
+					}
+					for _, level := range levels {
+						rl.Rl_[level] = "on"
+					}
+				}
+			}
+		}
+	}
+	for _, rl := range smap {
+		for level := range rl.Rl_ {
+			if len(rl.Rl_[level]) == 0 {
+				rl.Rl_[level] = "off"
+			}
+		}
+		rl.setBootstart(_default)
+	}
+	return smap
+}
+
+// Join fills Status_ and Vanishes_ from current service state, adding rows for running services the matrix does not know
+// (on systemd, disabled services that were started by hand or pulled in as dependencies)
+func Join(_matrix map[string]*Runleveldata, _smap map[string]*Servicedata) {
+	for _, kk := range SortedKeys_String2PtrServicedata(&_smap) {
+		svc := _smap[kk]
+		rl := _matrix[svc.Service_]
+		if rl == nil {
+			if !svc.isRunning() {
+				continue
+			}
+			rl = &Runleveldata{Service_: svc.Service_, Source_: genutil.StrTernary(len(svc.Load_) > 0, "systemd", "sysv"), Bootstart_: "no"}
+			if enabled, known := svc.isEnabled(); known && enabled {
+				rl.Bootstart_ = "yes" // e.g. static, or socket or dbus activated
+			}
+			_matrix[svc.Service_] = rl
+		}
+		rl.Status_ = svc.Status_
+		rl.Vanishes_ = genutil.StrTernary(svc.isRunning() && (rl.Bootstart_ != "yes"), "yes", "no")
+	}
+}
+
+// Runlevels extracts the boot matrix of this box: systemd wants dirs on systemd boxes, else chkconfig --list, else the rc?.d symlinks;
+// Status_ and Vanishes_ are filled from the current service state
+func Runlevels(_verbose bool) (smap map[string]*Runleveldata) {
+	if IsSystemd() {
+		svcs := Systemd(_verbose)
+		smap = ScanSystemd(systemdUnitDirs, SystemdDefault(), _verbose)
+		for name, rl := range smap {
+			if svc := svcs[name]; (svc != nil) && (len(svc.Unitfile_) > 0) {
+				rl.Wantedby_ = strings.Join(installSection(svc.Unitfile_), "|")
+			}
+		}
+		Join(smap, svcs)
+		return smap
+	}
+	svcs := Service(_verbose)
+	smap = sysvRunlevels(_verbose)
+	setSysvEnabled(svcs, smap)
+	Join(smap, svcs)
+	return smap
+}
+
+// sysvRunlevels is the SysV matrix, from chkconfig where installed, else from the rc?.d symlinks
+func sysvRunlevels(_verbose bool) map[string]*Runleveldata {
+	def := SysvDefault()
+	out := genutil.BashExecOrDie(_verbose, "/usr/bin/timeout 10 /sbin/chkconfig --list 2>/dev/null || true", ".")
+	if smap := ParseChkconfig(out, def, _verbose); len(smap) > 0 {
+		return smap
+	}
+	if _, err := os.Stat(etcRcd); err == nil {
+		return ScanRcd(etcRcd, def, _verbose)
+	}
+	return ScanRcd("/etc", def, _verbose) // debian style /etc/rc?.d
+}
+
+// setSysvEnabled fills Enabled_ of SysV services from the matrix, enabled meaning on in the default runlevel
+func setSysvEnabled(_smap map[string]*Servicedata, _matrix map[string]*Runleveldata) {
+	for name, rl := range _matrix {
+		if svc := _smap[name]; svc != nil {
+			svc.Enabled_ = genutil.StrTernary(rl.Bootstart_ == "yes", "enabled", "disabled")
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package etcservice
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mklinks makes symlinks under a temp dir, e.g. "rc3.d/S55sshd" -> "../init.d/sshd"
+func mklinks(t *testing.T, _links map[string]string) string {
+	dir, err := ioutil.TempDir("", "etcservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range _links {
+		path := filepath.Join(dir, link)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseChkconfig(t *testing.T) {
+	out := `auditd         	0:off	1:off	2:on	3:on	4:on	5:on	6:off
+netconsole     	0:off	1:off	2:off	3:off	4:off	5:off	6:off
+network        	0:off	1:off	2:on	3:on	4:on	5:on	6:off
+xdm            	0:off	1:off	2:off	3:off	4:off	5:on	6:off
+
+Note: This output shows SysV services only and does not include native
+      systemd services.
+
+xinetd based services:
+	chargen-dgram: 	off
+	rsync:         	on
+`
+	tests := []struct {
+		service, source, rl3, rl5, bootstart string
+	}{
+		{"auditd", "chkconfig", "on", "on", "yes"},
+		{"netconsole", "chkconfig", "off", "off", "no"},
+		{"network", "chkconfig", "on", "on", "yes"},
+		{"xdm", "chkconfig", "off", "on", "no"},
+		{"chargen-dgram", "xinetd", "", "", "no"},
+		{"rsync", "xinetd", "", "", "yes"},
+	}
+	smap := ParseChkconfig(out, 3, false)
+	if len(smap) != len(tests) {
+		t.Errorf("got %d services, want %d", len(smap), len(tests))
+	}
+	for _, tt := range tests {
+		rl := smap[tt.service]
+		if rl == nil {
+			t.Errorf("%s: missing", tt.service)
+			continue
+		}
+		if (rl.Source_ != tt.source) || (rl.Rl_[3] != tt.rl3) || (rl.Rl_[5] != tt.rl5) || (rl.Bootstart_ != tt.bootstart) {
+			t.Errorf("%s: got %s", tt.service, rl.Sprint())
+		}
+	}
+	if rl := ParseChkconfig(out, 5, false)["xdm"]; rl.Bootstart_ != "yes" {
+		t.Errorf("xdm in runlevel 5: got %s", rl.Sprint())
+	}
+}
+
+func TestScanRcd(t *testing.T) {
+	dir := mklinks(t, map[string]string{
+		"rc0.d/K90network": "../init.d/network",
+		"rc1.d/K90network": "../init.d/network",
+		"rc3.d/S10network": "../init.d/network",
+		"rc5.d/S10network": "../init.d/network",
+		"rc3.d/K50snmpd":   "../init.d/snmpd",
+		"rc5.d/S50snmpd":   "../init.d/snmpd",
+		"rc3.d/README":     "../README",
+		"rc7.d/S01bogus":   "../init.d/bogus",
+	})
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		service   string
+		rl        [7]string
+		bootstart string
+	}{
+		{"network", [7]string{"off", "off", "", "on", "", "on", ""}, "yes"},
+		{"snmpd", [7]string{"", "", "", "off", "", "on", ""}, "no"},
+	}
+	smap := ScanRcd(dir, 3, false)
+	if len(smap) != len(tests) {
+		t.Errorf("got %d services, want %d", len(smap), len(tests))
+	}
+	for _, tt := range tests {
+		rl := smap[tt.service]
+		if (rl == nil) || (rl.Source_ != "rcd") || (rl.Rl_ != tt.rl) || (rl.Bootstart_ != tt.bootstart) {
+			t.Errorf("%s: got %s", tt.service, rl.Sprint())
+		}
+	}
+}
+
+func TestScanSystemd(t *testing.T) {
+	dir := mklinks(t, map[string]string{
+		"etc/multi-user.target.wants/sshd.service":      "/usr/lib/systemd/system/sshd.service",
+		"etc/graphical.target.wants/gdm.service":        "/usr/lib/systemd/system/gdm.service",
+		"etc/sysinit.target.wants/lvm2-monitor.service": "/usr/lib/systemd/system/lvm2-monitor.service",
+		"etc/multi-user.target.wants/remote-fs.target":  "/usr/lib/systemd/system/remote-fs.target",
+		"gen/multi-user.target.wants/network.service":   "/etc/rc.d/init.d/network",
+		"lib/multi-user.target.requires/sshd.service":   "../sshd.service",
+		"lib/custom.target.wants/batch.service":         "../batch.service",
+		"lib/rescue.target.wants/rescue-helper.service": "../rescue-helper.service",
+		"lib/default.target.d/README":                   "../README",
+	})
+	defer os.RemoveAll(dir)
+	dirs := []string{filepath.Join(dir, "etc"), filepath.Join(dir, "gen"), filepath.Join(dir, "lib")}
+	tests := []struct {
+		service, targets string
+		rl               [7]string
+		bootstart        string
+	}{
+		{"sshd", "multi-user.target", [7]string{"off", "off", "on", "on", "on", "on", "off"}, "yes"},
+		{"gdm", "graphical.target", [7]string{"off", "off", "off", "off", "off", "on", "off"}, "no"},
+		{"lvm2-monitor", "sysinit.target", [7]string{"off", "on", "on", "on", "on", "on", "off"}, "yes"},
+		{"network", "multi-user.target", [7]string{"off", "off", "on", "on", "on", "on", "off"}, "yes"},
+		{"batch", "custom.target", [7]string{"off", "off", "on", "on", "on", "on", "off"}, "yes"},
+		{"rescue-helper", "rescue.target", [7]string{"off", "on", "off", "off", "off", "off", "off"}, "no"},
+	}
+	smap := ScanSystemd(dirs, 3, false)
+	if len(smap) != len(tests) {
+		t.Errorf("got %d services, want %d", len(smap), len(tests))
+	}
+	for _, tt := range tests {
+		rl := smap[tt.service]
+		if (rl == nil) || (rl.Source_ != "systemd") || (rl.Targets_ != tt.targets) || (rl.Rl_ != tt.rl) || (rl.Bootstart_ != tt.bootstart) {
+			t.Errorf("%s: got %s", tt.service, rl.Sprint())
+		}
+	}
+}
+
+func TestSystemdUnitDirsHaveGenerators(t *testing.T) {
+	for _, want := range []string{"/run/systemd/generator.early", "/run/systemd/generator", "/run/systemd/generator.late"} {
+		found := false
+		for _, dir := range systemdUnitDirs {
+			found = found || (dir == want)
+		}
+		if !found {
+			t.Errorf("%s not searched", want)
+		}
+	}
+}
+
+func TestJoinSysvGenerated(t *testing.T) {
+	// network comes from systemd-sysv-generator's wants link, so it must not be flagged as vanishing
+	dir := mklinks(t, map[string]string{"gen/multi-user.target.wants/network.service": "/etc/rc.d/init.d/network"})
+	defer os.RemoveAll(dir)
+	matrix := ScanSystemd([]string{filepath.Join(dir, "gen")}, 3, false)
+	Join(matrix, map[string]*Servicedata{
+		"network": {Service_: "network", Status_: "running", Load_: "loaded", Active_: "active", Sub_: "exited", Enabled_: "generated"},
+		"manual":  {Service_: "manual", Status_: "running", Load_: "loaded", Active_: "active", Sub_: "running", Enabled_: "disabled"},
+	})
+	if rl := matrix["network"]; rl.Vanishes_ != "no" {
+		t.Errorf("network: got %s", rl.Sprint())
+	}
+	if rl := matrix["manual"]; (rl == nil) || (rl.Vanishes_ != "yes") {
+		t.Errorf("manual: got %s", rl.Sprint())
+	}
+}
@@ -0,0 +1,215 @@
+package etcservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/LDCS/genutil"
+	"os"
+	"strings"
+)
+
+var (
+	sysRunSystemd = "/run/systemd/system" // exists only when systemd is pid 1
+	systemctl     = "/usr/bin/timeout 20 /usr/bin/systemctl --no-pager"
+	// showProperties are fetched for every service by Systemd
+	showProperties = []string{"Id", "LoadState", "ActiveState", "SubState", "UnitFileState", "MainPID", "FragmentPath"}
+)
+
+// listUnit is one element of systemctl list-units --output=json
+type listUnit struct {
+	Unit   string `json:"unit"`
+	Load   string `json:"load"`
+	Active string `json:"active"`
+	Sub    string `json:"sub"`
+}
+
+// listUnitFile is one element of systemctl list-unit-files --output=json
+type listUnitFile struct {
+	UnitFile string `json:"unit_file"`
+	State    string `json:"state"`
+}
+
+// serviceName drops the .service suffix, so systemd and SysV rows share keys, e.g. sshd
+func serviceName(_unit string) string { return strings.TrimSuffix(_unit, ".service") }
+
+// sysvStatus maps systemd states onto the words service --status-all uses, so Status_ means the same on both
+func sysvStatus(_active, _sub string) string {
+	switch {
+	case (_active == "active") && (_sub == "running"):
+		return "running"
+	case _active == "active":
+		return _sub // e.g. exited for oneshot services
+	case (_active == "inactive") || (len(_active) == 0):
+		return "stopped"
+	}
+	return _active // failed, activating, deactivating, reloading
+}
+
+// isJSON tells whether systemctl output is a json array; systemd before 246 takes --output=json as a journal option and prints the plain table
+func isJSON(_out string) bool { return strings.HasPrefix(strings.TrimSpace(_out), "[") }
+
+// ParseListUnits parses systemctl list-units --all --type=service, as json when systemctl supports it (systemd 246+), else as plain text
+func ParseListUnits(_out string, _verbose bool) (smap map[string]*Servicedata) {
+	smap = make(map[string]*Servicedata)
+	if isJSON(_out) {
+		units := []*listUnit{}
+		err := json.Unmarshal([]byte(_out), &units)
+		if err == nil {
+			for _, unit := range units {
+				svc := &Servicedata{Service_: serviceName(unit.Unit), Load_: unit.Load, Active_: unit.Active, Sub_: unit.Sub}
+				svc.Status_ = sysvStatus(svc.Active_, svc.Sub_)
+				smap[svc.Service_] = svc
+			}
+			return smap
+		}
+		if _verbose {
+			fmt.Printf("ParseListUnits: %s, trying text\n", err)
+		}
+	}
+	for ii, line := range strings.Split(_out, "\n") {
+		items := strings.Fields(strings.TrimLeft(line, "●* "))
+		if (len(items) < 4) || !strings.HasSuffix(items[0], ".service") {
+			if _verbose && (len(items) > 0) {
+				fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
+			}
+			continue
+		}
+		svc := &Servicedata{Service_: serviceName(items[0]), Load_: items[1], Active_: items[2], Sub_: items[3]}
+		svc.Status_ = sysvStatus(svc.Active_, svc.Sub_)
+		smap[svc.Service_] = svc
+	}
+	return smap
+}
+
+// ParseListUnitFiles parses systemctl list-unit-files --type=service, json or text, into service -> enablement, e.g. sshd -> enabled
+func ParseListUnitFiles(_out string, _verbose bool) map[string]string {
+	states := map[string]string{}
+	if isJSON(_out) {
+		files := []*listUnitFile{}
+		err := json.Unmarshal([]byte(_out), &files)
+		if err == nil {
+			for _, file := range files {
+				states[serviceName(file.UnitFile)] = file.State
+			}
+			return states
+		}
+		if _verbose {
+			fmt.Printf("ParseListUnitFiles: %s, trying text\n", err)
+		}
+	}
+	for _, line := range strings.Split(_out, "\n") {
+		items := strings.Fields(line)
+		if (len(items) >= 2) && strings.HasSuffix(items[0], ".service") {
+			states[serviceName(items[0])] = items[1]
+		}
+	}
+	return states
+}
+
+// ParseShow parses systemctl show output for several units, blocks of key=value separated by blank lines, keyed by Id
+func ParseShow(_out string) map[string]map[string]string {
+	units := map[string]map[string]string{}
+	props := map[string]string{}
+	flush := func() {
+		if id := props["Id"]; len(id) > 0 {
+			units[id] = props
+		}
+		props = map[string]string{}
+	}
+	for _, line := range strings.Split(_out, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			flush()
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	flush()
+	return units
+}
+
+// shellQuote quotes a unit name for bash, since names may hold backslash escapes such as \x2d
+func shellQuote(_str string) string { return "'" + strings.Replace(_str, "'", `'\''`, -1) + "'" }
+
+// systemctlShow runs systemctl show for a list of units and parses it, see ParseShow
+func systemctlShow(_units []string, _properties []string, _verbose bool) map[string]map[string]string {
+	if len(_units) == 0 {
+		return map[string]map[string]string{}
+	}
+	quoted := []string{}
+	for _, unit := range _units {
+		quoted = append(quoted, shellQuote(unit))
+	}
+	out := genutil.BashExecOrDie(_verbose, systemctl+" show --property="+strings.Join(_properties, ",")+" -- "+strings.Join(quoted, " ")+" 2>/dev/null || true", ".")
+	return ParseShow(out)
+}
+
+// systemctlList runs a systemctl list command as json, and again with _textArgs when the output is not json.
+// The exit status cannot tell: old systemctl exits 0 with the table, legend and all, when asked for json
+func systemctlList(_args string, _textArgs string, _verbose bool) string {
+	out := genutil.BashExecOrDie(_verbose, systemctl+" "+_args+" --output=json 2>/dev/null || true", ".")
+	if isJSON(out) {
+		return out
+	}
+	if _verbose {
+		fmt.Printf("systemctlList: %s is not json, trying text\n", _args)
+	}
+	return genutil.BashExecOrDie(_verbose, systemctl+" "+_args+" "+_textArgs+" 2>/dev/null || true", ".")
+}
+
+// Systemd extracts service state from systemctl: every loaded service, plus the unit files of services not loaded, keyed by name without .service
+func Systemd(_verbose bool) (smap map[string]*Servicedata) {
+	out := systemctlList("list-units --all --type=service", "--no-legend --plain", _verbose)
+	if _verbose {
+		fmt.Println(out)
+	}
+	smap = ParseListUnits(out, _verbose)
+	out = systemctlList("list-unit-files --type=service", "--no-legend", _verbose)
+	for name, state := range ParseListUnitFiles(out, _verbose) {
+		if smap[name] == nil {
+			smap[name] = &Servicedata{Service_: name, Status_: "stopped", Load_: "not-loaded", Active_: "inactive", Sub_: "dead"}
+		}
+		smap[name].Enabled_ = state
+	}
+	units := []string{}
+	for _, name := range SortedKeys_String2PtrServicedata(&smap) {
+		if !strings.HasSuffix(name, "@") { // templates cannot be shown, only their instances
+			units = append(units, name+".service")
+		}
+	}
+	for id, props := range systemctlShow(units, showProperties, _verbose) {
+		svc := smap[serviceName(id)]
+		if svc == nil {
+			continue
+		}
+		if pid := props["MainPID"]; (len(pid) > 0) && (pid != "0") {
+			svc.Pid_ = pid
+		}
+		svc.Unitfile_ = props["FragmentPath"]
+		if len(svc.Enabled_) == 0 {
+			svc.Enabled_ = props["UnitFileState"]
+		}
+		if _verbose {
+			fmt.Printf("show %s: %s", id, svc.Sprint())
+		}
+	}
+	return smap
+}
+
+// IsSystemd tells whether this box was booted with systemd
+func IsSystemd() bool {
+	_, err := os.Stat(sysRunSystemd)
+	return err == nil
+}
+
+// Services extracts service state with systemctl on systemd boxes and with service --status-all elsewhere, into the same csv columns.
+// On SysV boxes Enabled_ comes from the runlevel matrix, see Runlevels
+func Services(_verbose bool) (smap map[string]*Servicedata) {
+	if IsSystemd() {
+		return Systemd(_verbose)
+	}
+	smap = Service(_verbose)
+	setSysvEnabled(smap, sysvRunlevels(_verbose))
+	return smap
+}
@@ -0,0 +1,156 @@
+package etcservice
+
+import (
+	"testing"
+)
+
+// centos7Units is what systemctl 219 prints for list-units --all --type=service --output=json: the table, not json
+const centos7Units = `  UNIT                      LOAD      ACTIVE   SUB     DESCRIPTION
+  auditd.service            loaded    active   running Security Auditing Service
+● kdump.service             loaded    failed   failed  Crash recovery kernel arming
+  rhel-dmesg.service        loaded    active   exited  Dump dmesg to /var/log/dmesg
+  sshd.service              loaded    active   running OpenSSH server daemon
+  tuned.service             not-found inactive dead    tuned.service
+
+LOAD   = Reflects whether the unit definition was properly loaded.
+ACTIVE = The high-level unit activation state, i.e. generalization of SUB.
+SUB    = The low-level unit activation state, values depend on unit type.
+
+5 loaded units listed.
+To show all installed unit files use 'systemctl list-unit-files'.
+`
+
+func TestIsJSON(t *testing.T) {
+	tests := []struct {
+		out  string
+		want bool
+	}{
+		{`[{"unit":"sshd.service"}]`, true},
+		{"\n  []\n", true},
+		{centos7Units, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isJSON(tt.out); got != tt.want {
+			t.Errorf("isJSON(%.20q) = %v, want %v", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestParseListUnits(t *testing.T) {
+	jsonOut := `[{"unit":"auditd.service","load":"loaded","active":"active","sub":"running","description":"Security Auditing Service"},` +
+		`{"unit":"kdump.service","load":"loaded","active":"failed","sub":"failed","description":"Crash recovery kernel arming"},` +
+		`{"unit":"rhel-dmesg.service","load":"loaded","active":"active","sub":"exited","description":"Dump dmesg"},` +
+		`{"unit":"sshd.service","load":"loaded","active":"active","sub":"running","description":"OpenSSH server daemon"},` +
+		`{"unit":"tuned.service","load":"not-found","active":"inactive","sub":"dead","description":"tuned.service"}]`
+	plainOut := `auditd.service            loaded    active   running Security Auditing Service
+kdump.service             loaded    failed   failed  Crash recovery kernel arming
+rhel-dmesg.service        loaded    active   exited  Dump dmesg to /var/log/dmesg
+sshd.service              loaded    active   running OpenSSH server daemon
+tuned.service             not-found inactive dead    tuned.service
+`
+	want := map[string]Servicedata{
+		"auditd":     {Service_: "auditd", Status_: "running", Load_: "loaded", Active_: "active", Sub_: "running"},
+		"kdump":      {Service_: "kdump", Status_: "failed", Load_: "loaded", Active_: "failed", Sub_: "failed"},
+		"rhel-dmesg": {Service_: "rhel-dmesg", Status_: "exited", Load_: "loaded", Active_: "active", Sub_: "exited"},
+		"sshd":       {Service_: "sshd", Status_: "running", Load_: "loaded", Active_: "active", Sub_: "running"},
+		"tuned":      {Service_: "tuned", Status_: "stopped", Load_: "not-found", Active_: "inactive", Sub_: "dead"},
+	}
+	tests := []struct {
+		name string
+		out  string
+	}{
+		{"json", jsonOut},
+		{"plain", plainOut},
+		{"centos7", centos7Units},
+		{"badjson", "[{\"unit\":\n" + plainOut},
+	}
+	for _, tt := range tests {
+		smap := ParseListUnits(tt.out, false)
+		if len(smap) != len(want) {
+			t.Errorf("%s: got %d services, want %d", tt.name, len(smap), len(want))
+		}
+		for name, ww := range want {
+			if svc := smap[name]; (svc == nil) || (*svc != ww) {
+				t.Errorf("%s: %s = %+v, want %+v", tt.name, name, svc, ww)
+			}
+		}
+	}
+}
+
+func TestParseListUnitFiles(t *testing.T) {
+	jsonOut := `[{"unit_file":"auditd.service","state":"enabled","vendor_preset":"enabled"},` +
+		`{"unit_file":"getty@.service","state":"enabled","vendor_preset":"enabled"},` +
+		`{"unit_file":"kdump.service","state":"disabled","vendor_preset":"enabled"},` +
+		`{"unit_file":"systemd-journald.service","state":"static","vendor_preset":null}]`
+	textOut := `UNIT FILE                     STATE   VENDOR PRESET
+auditd.service                enabled enabled
+getty@.service                enabled enabled
+kdump.service                 disabled enabled
+systemd-journald.service      static  -
+
+4 unit files listed.
+`
+	centos7Out := `auditd.service                enabled
+getty@.service                enabled
+kdump.service                 disabled
+systemd-journald.service      static
+`
+	want := map[string]string{"auditd": "enabled", "getty@": "enabled", "kdump": "disabled", "systemd-journald": "static"}
+	for name, out := range map[string]string{"json": jsonOut, "text": textOut, "centos7": centos7Out} {
+		states := ParseListUnitFiles(out, false)
+		if len(states) != len(want) {
+			t.Errorf("%s: got %v, want %v", name, states, want)
+			continue
+		}
+		for svc, state := range want {
+			if states[svc] != state {
+				t.Errorf("%s: %s = %q, want %q", name, svc, states[svc], state)
+			}
+		}
+	}
+}
+
+func TestParseShow(t *testing.T) {
+	out := `Id=sshd.service
+LoadState=loaded
+ActiveState=active
+MainPID=1042
+FragmentPath=/usr/lib/systemd/system/sshd.service
+ExecStart={ path=/usr/sbin/sshd ; argv[]=/usr/sbin/sshd -D $OPTIONS ; }
+
+Id=dev-disk-by\x2dlabel-swap.swap
+LoadState=loaded
+ActiveState=inactive
+
+LoadState=not-found
+
+Id=kdump.service
+MainPID=0
+`
+	units := ParseShow(out)
+	if len(units) != 3 {
+		t.Fatalf("got %d units, want 3: %v", len(units), units)
+	}
+	if got := units["sshd.service"]["MainPID"]; got != "1042" {
+		t.Errorf("sshd MainPID = %q", got)
+	}
+	if got := units["sshd.service"]["ExecStart"]; got != "{ path=/usr/sbin/sshd ; argv[]=/usr/sbin/sshd -D $OPTIONS ; }" {
+		t.Errorf("sshd ExecStart = %q, values may hold =", got)
+	}
+	if got := units[`dev-disk-by\x2dlabel-swap.swap`]["ActiveState"]; got != "inactive" {
+		t.Errorf("swap ActiveState = %q", got)
+	}
+	if got := units["kdump.service"]["LoadState"]; got != "" {
+		t.Errorf("kdump picked up LoadState %q from the block without an Id", got)
+	}
+	if got := len(ParseShow("")); got != 0 {
+		t.Errorf("ParseShow of nothing = %d units", got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote(`a\x2db's`); got != `'a\x2db'\''s'` {
+		t.Errorf("shellQuote = %s", got)
+	}
+}
@@ -0,0 +1,126 @@
+package etcshadow
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hash schemes stored in Shadowdata.Scheme_
+const (
+	SchemeEmpty    = "empty"    // no password at all, anyone can log in where PAM allows nullok
+	SchemeNologin  = "nologin"  // * or similar, no password login possible
+	SchemeDes      = "des"      // traditional crypt, 8 significant characters
+	SchemeBsdi     = "bsdi"     // extended DES, _ prefix
+	SchemeMd5      = "md5"      // $1$
+	SchemeBcrypt   = "bcrypt"   // $2a$, $2b$, $2y$
+	SchemeSha256   = "sha256"   // $5$
+	SchemeSha512   = "sha512"   // $6$
+	SchemeScrypt   = "scrypt"   // $7$
+	SchemeYescrypt = "yescrypt" // $y$
+	SchemeGost     = "gost-yescrypt"
+	SchemeUnknown  = "unknown"
+)
+
+var (
+	desRegexp = regexp.MustCompile(`^[./0-9A-Za-z]{13}$`)
+	// weakSchemes can be cracked at scale, or let anyone in
+	weakSchemes = map[string]bool{SchemeEmpty: true, SchemeDes: true, SchemeBsdi: true, SchemeMd5: true, SchemeUnknown: true}
+	// now is when ages are computed from, a var so reports can be reproduced for a given day
+	now   = time.Now
+	epoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Scheme tells the hash scheme of a shadow password field and whether it is locked (a ! prefix, as passwd -l and usermod -L add).
+// The hash itself is only looked at, never returned
+func Scheme(_pw string) (string, bool) {
+	locked := strings.HasPrefix(_pw, "!")
+	pw := strings.TrimLeft(_pw, "!")
+	switch {
+	case (len(pw) == 0) && locked:
+		return SchemeNologin, true // ! or !! : never had a password
+	case len(pw) == 0:
+		return SchemeEmpty, false
+	case strings.HasPrefix(pw, "$1$"):
+		return SchemeMd5, locked
+	case strings.HasPrefix(pw, "$2a$"), strings.HasPrefix(pw, "$2b$"), strings.HasPrefix(pw, "$2y$"):
+		return SchemeBcrypt, locked
+	case strings.HasPrefix(pw, "$5$"):
+		return SchemeSha256, locked
+	case strings.HasPrefix(pw, "$6$"):
+		return SchemeSha512, locked
+	case strings.HasPrefix(pw, "$7$"):
+		return SchemeScrypt, locked
+	case strings.HasPrefix(pw, "$y$"):
+		return SchemeYescrypt, locked
+	case strings.HasPrefix(pw, "$gy$"):
+		return SchemeGost, locked
+	case strings.HasPrefix(pw, "_") && (len(pw) == 20):
+		return SchemeBsdi, locked
+	case desRegexp.MatchString(pw):
+		return SchemeDes, locked
+	case !strings.HasPrefix(pw, "$"):
+		return SchemeNologin, locked // *, *LK*, x and other strings no hash can match
+	}
+	return SchemeUnknown, locked
+}
+
+// IsWeak tells whether a scheme should be flagged to auditors
+func IsWeak(_scheme string) bool { return weakSchemes[_scheme] }
+
+// dayDate renders a shadow day number, days since 1970-01-01, as a date
+func dayDate(_day int) string { return epoch.AddDate(0, 0, _day).Format("2006-01-02") }
+
+// atoiOk parses a shadow day field, false when empty or invalid
+func atoiOk(_str string) (int, bool) {
+	nn, err := strconv.Atoi(_str)
+	return nn, err == nil
+}
+
+// setScheme fills Scheme_, Weak_ and Locked_ from the password field
+func (self *Shadowdata) setScheme(_pw string) {
+	scheme, locked := Scheme(_pw)
+	self.Scheme_ = scheme
+	self.Weak_ = yesno(IsWeak(scheme) && !locked)
+	self.Locked_ = yesno(locked)
+}
+
+// yesno renders a flag
+func yesno(_flag bool) string {
+	if _flag {
+		return "yes"
+	}
+	return "no"
+}
+
+// setAging computes the dates and flags from the day counts, as of _now.
+// Field positions follow shadow(5): Nlastchange_ is the last change day, Nmustchange_ the maximum password age,
+// Nexpire_ the inactivity period after the password expired, Nexpired_ the account expiry day
+func (self *Shadowdata) setAging(_now time.Time) {
+	today := int(_now.UTC().Sub(epoch).Hours() / 24)
+	self.Lastchange_, self.Pwexpires_, self.Daystoexpiry_, self.Inactivedate_, self.Accountexpires_ = "", "", "", "", ""
+	self.Pwexpired_, self.Accountexpired_ = "no", "no"
+	lastchange, hasLastchange := atoiOk(self.Nlastchange_)
+	switch {
+	case hasLastchange && (lastchange == 0):
+		self.Lastchange_ = "mustchange" // forced change at next login
+		self.Pwexpired_ = "yes"
+	case hasLastchange:
+		self.Lastchange_ = dayDate(lastchange)
+	}
+	if maxage, ok := atoiOk(self.Nmustchange_); ok && hasLastchange && (lastchange > 0) && (maxage < 99999) {
+		expires := lastchange + maxage
+		self.Pwexpires_ = dayDate(expires)
+		self.Daystoexpiry_ = fmt.Sprint(expires - today)
+		self.Pwexpired_ = yesno(today >= expires)
+		if inactive, ok := atoiOk(self.Nexpire_); ok && (inactive >= 0) {
+			self.Inactivedate_ = dayDate(expires + inactive)
+		}
+	}
+	if expire, ok := atoiOk(self.Nexpired_); ok {
+		self.Accountexpires_ = dayDate(expire)
+		self.Accountexpired_ = yesno(today >= expire)
+	}
+}
@@ -0,0 +1,100 @@
+package etcshadow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheme(t *testing.T) {
+	tests := []struct {
+		pw     string
+		scheme string
+		locked bool
+	}{
+		{"", SchemeEmpty, false},
+		{"!", SchemeNologin, true},
+		{"!!", SchemeNologin, true},
+		{"*", SchemeNologin, false},
+		{"*LK*", SchemeNologin, false},
+		{"x", SchemeNologin, false},
+		{"!*", SchemeNologin, true},
+		{"abJnggxhB/yWI", SchemeDes, false},
+		{"!abJnggxhB/yWI", SchemeDes, true},
+		{"abJnggxhB/yW", SchemeNologin, false}, // 12 characters, no DES hash
+		{"_J9..CCCCXBrJUJV154M", SchemeBsdi, false},
+		{"_J9..CCCC", SchemeNologin, false},
+		{"$1$saltsalt$qjXMvbEw8oaL.CzflDugX/", SchemeMd5, false},
+		{"!$1$saltsalt$qjXMvbEw8oaL.CzflDugX/", SchemeMd5, true},
+		{"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", SchemeBcrypt, false},
+		{"$2b$12$N9qo8uLOickgx2ZMRZoMye", SchemeBcrypt, false},
+		{"$2y$05$N9qo8uLOickgx2ZMRZoMye", SchemeBcrypt, false},
+		{"$5$rounds=5000$salt$hash", SchemeSha256, false},
+		{"$6$salt$hash", SchemeSha512, false},
+		{"!!$6$salt$hash", SchemeSha512, true},
+		{"$7$CU..../....salt$hash", SchemeScrypt, false},
+		{"$y$j9T$salt$hash", SchemeYescrypt, false},
+		{"$gy$j9T$salt$hash", SchemeGost, false},
+		{"$argon2id$v=19$m=65536,t=3,p=4$salt$hash", SchemeUnknown, false},
+		{"!$9$whatever", SchemeUnknown, true},
+	}
+	for _, tt := range tests {
+		scheme, locked := Scheme(tt.pw)
+		if (scheme != tt.scheme) || (locked != tt.locked) {
+			t.Errorf("%q: got %s locked=%v, want %s locked=%v", tt.pw, scheme, locked, tt.scheme, tt.locked)
+		}
+	}
+}
+
+func TestSetScheme(t *testing.T) {
+	tests := []struct {
+		pw, scheme, weak, locked string
+	}{
+		{"", SchemeEmpty, "yes", "no"},
+		{"!", SchemeNologin, "no", "yes"},
+		{"$1$salt$hash", SchemeMd5, "yes", "no"},
+		{"!$1$salt$hash", SchemeMd5, "no", "yes"}, // weak but locked
+		{"abJnggxhB/yWI", SchemeDes, "yes", "no"},
+		{"$6$salt$hash", SchemeSha512, "no", "no"},
+		{"$argon2id$v=19$salt$hash", SchemeUnknown, "yes", "no"},
+	}
+	for _, tt := range tests {
+		xsh := new(Shadowdata)
+		xsh.setScheme(tt.pw)
+		if (xsh.Scheme_ != tt.scheme) || (xsh.Weak_ != tt.weak) || (xsh.Locked_ != tt.locked) {
+			t.Errorf("%q: got %s weak=%s locked=%s, want %s weak=%s locked=%s", tt.pw, xsh.Scheme_, xsh.Weak_, xsh.Locked_, tt.scheme, tt.weak, tt.locked)
+		}
+	}
+}
+
+func TestSetAging(t *testing.T) {
+	today := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) // day 19723
+	tests := []struct {
+		name string
+		// shadow(5) fields 3 to 8: last change, min age, max age, warning, inactivity, account expiry
+		lastchange, canchange, mustchange, warn, inactive, expired string
+		// wanted Lastchange_, Pwexpires_, Daystoexpiry_, Pwexpired_, Inactivedate_, Accountexpires_, Accountexpired_
+		want [7]string
+	}{
+		{"max age and inactivity", "19700", "1", "90", "14", "7", "", [7]string{"2023-12-09", "2024-03-08", "67", "no", "2024-03-15", "", "no"}},
+		{"never expires", "19700", "0", "99999", "7", "", "", [7]string{"2023-12-09", "", "", "no", "", "", "no"}},
+		{"no max age", "19700", "", "", "", "", "", [7]string{"2023-12-09", "", "", "no", "", "", "no"}},
+		{"password expired", "19600", "0", "90", "7", "", "", [7]string{"2023-08-31", "2023-11-29", "-33", "yes", "", "", "no"}},
+		{"expires today", "19633", "0", "90", "7", "30", "", [7]string{"2023-10-03", "2024-01-01", "0", "yes", "2024-01-31", "", "no"}},
+		{"inactivity -1 is off", "19700", "0", "90", "7", "-1", "", [7]string{"2023-12-09", "2024-03-08", "67", "no", "", "", "no"}},
+		{"must change", "0", "0", "90", "7", "7", "", [7]string{"mustchange", "", "", "yes", "", "", "no"}},
+		{"account expired", "19700", "0", "99999", "7", "", "19713", [7]string{"2023-12-09", "", "", "no", "", "2023-12-22", "yes"}},
+		{"account expires later", "19700", "0", "99999", "7", "", "19800", [7]string{"2023-12-09", "", "", "no", "", "2024-03-18", "no"}},
+		{"account expired 1970", "", "", "", "", "", "0", [7]string{"", "", "", "no", "", "1970-01-01", "yes"}},
+		{"all empty", "", "", "", "", "", "", [7]string{"", "", "", "no", "", "", "no"}},
+		{"max age without last change", "", "", "90", "", "7", "", [7]string{"", "", "", "no", "", "", "no"}},
+	}
+	for _, tt := range tests {
+		xsh := &Shadowdata{Nlastchange_: tt.lastchange, Ncanchanges_: tt.canchange, Nmustchange_: tt.mustchange, Nwarn_: tt.warn, Nexpire_: tt.inactive, Nexpired_: tt.expired}
+		xsh.Pwexpires_ = "stale" // setAging starts afresh
+		xsh.setAging(today)
+		got := [7]string{xsh.Lastchange_, xsh.Pwexpires_, xsh.Daystoexpiry_, xsh.Pwexpired_, xsh.Inactivedate_, xsh.Accountexpires_, xsh.Accountexpired_}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
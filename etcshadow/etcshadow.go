@@ -4,29 +4,37 @@
 package etcshadow
 
 import (
-	"crypto/md5"
 	"fmt"
 	"github.com/LDCS/genutil"
-	"io"
 	"sort"
 	"strings"
 )
 
 // Shadowdata holds etc shadow info
 type Shadowdata struct {
-	Shadowname_  string
-	PwMd5sum_    string
-	Nlastchange_ string
-	Ncanchanges_ string
-	Nmustchange_ string
-	Nwarn_       string
-	Nexpire_     string
-	Nexpired_    string
-	Nreserved_   string
+	Shadowname_     string
+	Pwfingerprint_  string // see Fingerprint, empty without a site secret
+	Nlastchange_    string
+	Ncanchanges_    string
+	Nmustchange_    string
+	Nwarn_          string
+	Nexpire_        string // shadow(5) inactivity period: days an expired password still allows a change
+	Nexpired_       string // shadow(5) account expiration day
+	Nreserved_      string
+	Scheme_         string // hash scheme, e.g. sha512, see Scheme
+	Weak_           string // yes if the scheme is weak (or the password empty) and the account not locked
+	Locked_         string // yes if the hash has a ! prefix
+	Lastchange_     string // date of Nlastchange, or mustchange when it is 0
+	Pwexpires_      string // date the password expires, empty if it never does
+	Daystoexpiry_   string // negative once expired
+	Pwexpired_      string
+	Inactivedate_   string // date the account is disabled if the expired password is not changed
+	Accountexpires_ string // date of Nexpired
+	Accountexpired_ string
 }
 
 const (
-	names     = "Shadowname,PwMd5sum,Nlastchange,Ncanchanges,Nmustchange,Nwarn,Nexpire,Nexpired,Nreserved"
+	names     = "Shadowname,Pwfingerprint,Nlastchange,Ncanchanges,Nmustchange,Nwarn,Nexpire,Nexpired,Nreserved,Scheme,Weak,Locked,Lastchange,Pwexpires,Daystoexpiry,Pwexpired,Inactivedate,Accountexpires,Accountexpired"
 	hdrprefix = ",xsh."
 	semi      = ";"
 )
@@ -77,7 +85,8 @@ func (self *Shadowdata) Csv() string {
 	if self == nil {
 		return commaString
 	}
-	return fmt.Sprintf(pctString, self.Shadowname_, self.PwMd5sum_, self.Nlastchange_, self.Ncanchanges_, self.Nmustchange_, self.Nwarn_, self.Nexpire_, self.Nexpired_, self.Nreserved_)
+	return fmt.Sprintf(pctString, self.Shadowname_, self.Pwfingerprint_, self.Nlastchange_, self.Ncanchanges_, self.Nmustchange_, self.Nwarn_, self.Nexpire_, self.Nexpired_, self.Nreserved_,
+		self.Scheme_, self.Weak_, self.Locked_, self.Lastchange_, self.Pwexpires_, self.Daystoexpiry_, self.Pwexpired_, self.Inactivedate_, self.Accountexpires_, self.Accountexpired_)
 }
 
 // Sprint is generic
@@ -85,7 +94,8 @@ func (self *Shadowdata) Sprint() string {
 	if self == nil {
 		return ""
 	}
-	return fmt.Sprintf(namePctString, self.Shadowname_, self.PwMd5sum_, self.Nlastchange_, self.Ncanchanges_, self.Nmustchange_, self.Nwarn_, self.Nexpire_, self.Nexpired_, self.Nreserved_)
+	return fmt.Sprintf(namePctString, self.Shadowname_, self.Pwfingerprint_, self.Nlastchange_, self.Ncanchanges_, self.Nmustchange_, self.Nwarn_, self.Nexpire_, self.Nexpired_, self.Nreserved_,
+		self.Scheme_, self.Weak_, self.Locked_, self.Lastchange_, self.Pwexpires_, self.Daystoexpiry_, self.Pwexpired_, self.Inactivedate_, self.Accountexpires_, self.Accountexpired_)
 }
 
 // SprintShort is generic
@@ -105,33 +115,32 @@ func (self *Shadowdata) Print() {
 	fmt.Printf(fmt.Sprint())
 }
 
-// Shadow extracts info from etc shadow
+// Shadow extracts info from etc shadow, fingerprinting with the secret of the QSLINUX_SHADOW_SECRET environment variable, if set
 func Shadow(_verbose bool) (smap map[string]*Shadowdata) {
-	smap = make(map[string]*Shadowdata)
-	out := genutil.BashExecOrDie(_verbose, "/bin/cat /etc/shadow| sed -e 's/[ ]/_/g'", ".")
-	if _verbose {
-		fmt.Println(out)
+	secret, err := envSecret()
+	if (err != nil) && _verbose {
+		fmt.Printf("Shadow: %s\n", err)
 	}
+	return ShadowKeyed(secret, _verbose)
+}
+
+// ShadowKeyed extracts info from etc shadow, fingerprinting the hashes with a site secret, or not at all with FingerprintNone
+func ShadowKeyed(_secret string, _verbose bool) (smap map[string]*Shadowdata) {
+	smap = make(map[string]*Shadowdata)
+	out := genutil.BashExecOrDie(false, "/bin/cat /etc/shadow| sed -e 's/[ ]/_/g'", ".") // never echo the hashes
 	lines := genutil.CleanAndSplitOnSeparator(out, ":", ",")
 	for ii, line := range lines {
 		items := strings.Split(line, ",")
 		num := len(items)
-		if _verbose {
-			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
-		}
 		if num < 6 {
 			continue
 		}
 		lastxsh := new(Shadowdata)
 		lastxsh.Shadowname_ = items[0]
 		smap[lastxsh.Shadowname_] = lastxsh
-		switch items[1] {
-		case "", "*":
-			lastxsh.PwMd5sum_ = items[1]
-		default:
-			h := md5.New()
-			io.WriteString(h, items[1])
-			lastxsh.PwMd5sum_ = fmt.Sprintf("%x", h.Sum(nil))
+		lastxsh.setScheme(items[1])
+		if (lastxsh.Scheme_ != SchemeEmpty) && (lastxsh.Scheme_ != SchemeNologin) {
+			lastxsh.Pwfingerprint_ = Fingerprint(_secret, lastxsh.Shadowname_, items[1])
 		}
 		lastxsh.Nlastchange_ = items[2]
 		lastxsh.Ncanchanges_ = items[3]
@@ -146,8 +155,9 @@ func Shadow(_verbose bool) (smap map[string]*Shadowdata) {
 		if num > 8 {
 			lastxsh.Nreserved_ = items[8]
 		}
+		lastxsh.setAging(now())
 		if _verbose {
-			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
+			fmt.Printf("line%d: item0(%s) %s", ii, items[0], lastxsh.Sprint())
 		}
 	}
 	return smap
@@ -0,0 +1,51 @@
+package etcshadow
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// FingerprintNone as the secret leaves Pwfingerprint_ empty
+const FingerprintNone = "none"
+
+// SecretEnv names the environment variable Shadow reads the site secret from
+const SecretEnv = "QSLINUX_SHADOW_SECRET"
+
+// Fingerprint is a keyed, non-reversible fingerprint of a shadow hash: hex HMAC-SHA256 under the site secret of user:hash.
+// The user is mixed in so that a hash copied to several accounts gives unrelated fingerprints; the host is not, so that the fingerprint
+// of one account does not change when a box is renamed, and a hash copied between hosts shows up as the same fingerprint.
+// The ! lock prefix is left out, so locking an account does not look like a password change (see Locked_)
+func Fingerprint(_secret, _user, _pw string) string {
+	if (len(_secret) == 0) || (_secret == FingerprintNone) {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(_secret))
+	mac.Write([]byte(_user + ":" + strings.TrimLeft(_pw, "!")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadSecret reads a site secret from a file, which should be readable by root only, trimming the trailing newline
+func LoadSecret(_path string) (string, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(buf), "\r\n")
+	if len(secret) == 0 {
+		return "", errors.New("etcshadow: empty secret in " + _path)
+	}
+	return secret, nil
+}
+
+// envSecret is the secret from SecretEnv; if unset it is FingerprintNone, with an error saying fingerprints will be left empty
+func envSecret() (string, error) {
+	if secret := os.Getenv(SecretEnv); len(secret) > 0 {
+		return secret, nil
+	}
+	return FingerprintNone, errors.New("etcshadow: " + SecretEnv + " is not set, password fingerprints are left empty")
+}
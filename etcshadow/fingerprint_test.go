@@ -0,0 +1,96 @@
+package etcshadow
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	const hash = "$6$salt$hash"
+	// python3 -c "import hmac,hashlib; print(hmac.new(b'sitesecret', b'alice:\$6\$salt\$hash', hashlib.sha256).hexdigest())"
+	const want = "3e38529282d3e7004923f7452bc948d27d75c8a6378f73efc91e71dfad58633f"
+	if got := Fingerprint("sitesecret", "alice", hash); got != want {
+		t.Errorf("Fingerprint = %s, want %s", got, want)
+	}
+	tests := []struct {
+		name           string
+		secret, user   string
+		pw             string
+		same, nonempty bool // same as want, and not empty
+	}{
+		{"locked", "sitesecret", "alice", "!" + hash, true, true},
+		{"double locked", "sitesecret", "alice", "!!" + hash, true, true},
+		{"other user", "sitesecret", "bob", hash, false, true},
+		{"other secret", "othersecret", "alice", hash, false, true},
+		{"other hash", "sitesecret", "alice", "$6$salt$hash2", false, true},
+		{"none", FingerprintNone, "alice", hash, false, false},
+		{"no secret", "", "alice", hash, false, false},
+	}
+	for _, tt := range tests {
+		got := Fingerprint(tt.secret, tt.user, tt.pw)
+		if (got == want) != tt.same {
+			t.Errorf("%s: %s, same=%v", tt.name, got, tt.same)
+		}
+		if (len(got) > 0) != tt.nonempty {
+			t.Errorf("%s: %q, nonempty=%v", tt.name, got, tt.nonempty)
+		}
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcshadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		text string
+		want string
+		err  bool
+	}{
+		{"sitesecret\n", "sitesecret", false},
+		{"sitesecret\r\n", "sitesecret", false},
+		{"sitesecret", "sitesecret", false},
+		{" site secret \n\n", " site secret ", false}, // only line ends are trimmed
+		{"\n", "", true},
+		{"", "", true},
+	}
+	for ii, tt := range tests {
+		path := filepath.Join(dir, "secret")
+		if err := ioutil.WriteFile(path, []byte(tt.text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadSecret(path)
+		if (got != tt.want) || ((err != nil) != tt.err) {
+			t.Errorf("test%d %q: got %q, %v", ii, tt.text, got, err)
+		}
+	}
+	if _, err := LoadSecret(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("LoadSecret of a missing file: no error")
+	}
+}
+
+func TestEnvSecret(t *testing.T) {
+	saved, had := os.LookupEnv(SecretEnv)
+	defer func() {
+		if had {
+			os.Setenv(SecretEnv, saved)
+		} else {
+			os.Unsetenv(SecretEnv)
+		}
+	}()
+	os.Setenv(SecretEnv, "sitesecret")
+	if secret, err := envSecret(); (secret != "sitesecret") || (err != nil) {
+		t.Errorf("set: %q, %v", secret, err)
+	}
+	os.Setenv(SecretEnv, "")
+	if secret, err := envSecret(); (secret != FingerprintNone) || (err == nil) {
+		t.Errorf("empty: %q, %v", secret, err)
+	}
+	os.Unsetenv(SecretEnv)
+	if secret, err := envSecret(); (secret != FingerprintNone) || (err == nil) {
+		t.Errorf("unset: %q, %v", secret, err)
+	}
+}
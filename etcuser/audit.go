@@ -0,0 +1,211 @@
+package etcuser
+
+import (
+	"fmt"
+	"github.com/LDCS/genutil"
+	"github.com/LDCS/qslinux/etcgroup"
+	"github.com/LDCS/qslinux/etcshadow"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Auditdata is one finding of the pwck/grpck style consistency checks
+type Auditdata struct {
+	Check_    string // noshadow, nopasswd, duplicatename, duplicateuid, duplicategroup, duplicategid, nogshadow, noprimarygroup, nohome, badshell or systemlogin
+	Severity_ string // error, warn or info
+	File_     string // passwd, shadow, group or gshadow
+	Name_     string // user or group name
+	Id_       string // uid or gid
+	Detail_   string
+}
+
+const (
+	namesAudit     = "Check,Severity,File,Name,Id,Detail"
+	hdrprefixAudit = ",xua."
+)
+
+var (
+	headerStringAudit  string
+	commaStringAudit   string
+	pctStringAudit     string
+	namePctStringAudit string
+	// nologinShells refuse logins, they need not be in /etc/shells
+	nologinShells = []string{"/sbin/nologin", "/usr/sbin/nologin", "/bin/false", "/usr/bin/false", "/bin/true", "/usr/bin/true", "/bin/sync", "/sbin/shutdown", "/sbin/halt"}
+)
+
+// init  is generic
+func init() {
+	headerStringAudit = (hdrprefixAudit + strings.Join(strings.Split(namesAudit, ","), hdrprefixAudit))[1:]
+	commaStringAudit = strings.Repeat(",", strings.Count(headerStringAudit, ","))
+	pctStringAudit = strings.Repeat(",%s", 1+strings.Count(headerStringAudit, ","))[1:]
+	namePctStringAudit = strings.Replace(namesAudit, ",", "=%s ", -1) + "=%s\n"
+}
+
+// AuditHeader is generic
+func AuditHeader() string { return headerStringAudit }
+
+// Csv is generic
+func (self *Auditdata) Csv() string {
+	if self == nil {
+		return commaStringAudit
+	}
+	return fmt.Sprintf(pctStringAudit, self.Check_, self.Severity_, self.File_, self.Name_, self.Id_, strings.Replace(self.Detail_, ",", semi, -1))
+}
+
+// Sprint is generic
+func (self *Auditdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringAudit, self.Check_, self.Severity_, self.File_, self.Name_, self.Id_, self.Detail_)
+}
+
+// Print is generic
+func (self *Auditdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// Auditinput holds what the checks look at besides passwd, so they can be run on another host's files
+type Auditinput struct {
+	Shadow_ map[string]*etcshadow.Shadowdata // empty when shadow could not be read, the shadow checks are then skipped
+	Groups_ []*etcgroup.Groupdata            // empty skips the group checks
+	Shells_ []string                         // from /etc/shells, empty skips the shell check
+	Uidmin_ int                              // first uid of ordinary users, UID_MIN of login.defs
+	Isdir_  func(path string) bool           // nil skips the home check
+}
+
+// ParseShells lists the shells of /etc/shells text
+func ParseShells(_text string) []string {
+	shells := []string{}
+	for _, line := range strings.Split(_text, "\n") {
+		if line = strings.TrimSpace(line); (len(line) > 0) && !strings.HasPrefix(line, "#") {
+			shells = append(shells, line)
+		}
+	}
+	return shells
+}
+
+// ParseUidmin reads UID_MIN from login.defs text, 1000 when unset
+func ParseUidmin(_text string) int {
+	for _, line := range strings.Split(_text, "\n") {
+		items := strings.Fields(line)
+		if (len(items) >= 2) && (items[0] == "UID_MIN") {
+			if uidmin, err := strconv.Atoi(items[1]); err == nil {
+				return uidmin
+			}
+		}
+	}
+	return 1000
+}
+
+// IsLoginShell tells whether a shell lets the user log in; an empty shell means /bin/sh
+func IsLoginShell(_shell string) bool {
+	return !genutil.SliceContainsStr(nologinShells, _shell)
+}
+
+// isdir tells whether a path is an existing directory
+func isdir(_path string) bool {
+	info, err := os.Stat(_path)
+	return (err == nil) && info.IsDir()
+}
+
+// Audit runs the pwck/grpck style checks over passwd entries in file order (see Passwd) and _in
+func Audit(_users []*Userdata, _in *Auditinput) []*Auditdata {
+	audits := []*Auditdata{}
+	add := func(_check, _severity, _file, _name, _id, _detail string) {
+		audits = append(audits, &Auditdata{Check_: _check, Severity_: _severity, File_: _file, Name_: _name, Id_: _id, Detail_: _detail})
+	}
+	byName := map[string]*Userdata{}
+	byUid := map[string]*Userdata{}
+	gids := map[string]bool{}
+	hasGshadow := false // gshadow was read
+	for _, xgr := range _in.Groups_ {
+		gids[xgr.Gid_] = true
+		hasGshadow = hasGshadow || (xgr.Ingshadow_ == "yes")
+	}
+	for _, xus := range _users {
+		if prev := byName[xus.Username_]; prev != nil {
+			add("duplicatename", "error", "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("%s already has uid %s, only the first entry is used", xus.Username_, prev.Uid_))
+		} else {
+			byName[xus.Username_] = xus
+		}
+		if prev := byUid[xus.Uid_]; (prev != nil) && (prev.Username_ != xus.Username_) {
+			add("duplicateuid", genutil.StrTernary(xus.Uid_ == "0", "error", "warn"), "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("uid %s already used by %s, files are shared", xus.Uid_, prev.Username_))
+		} else if prev == nil {
+			byUid[xus.Uid_] = xus
+		}
+		if (len(_in.Shadow_) > 0) && (_in.Shadow_[xus.Username_] == nil) {
+			add("noshadow", "error", "passwd", xus.Username_, xus.Uid_, "no shadow entry")
+		}
+		if (len(_in.Groups_) > 0) && !gids[xus.Gid_] {
+			add("noprimarygroup", "warn", "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("gid %s not in group", xus.Gid_))
+		}
+		login := IsLoginShell(xus.Shell_)
+		if (_in.Isdir_ != nil) && !_in.Isdir_(xus.Home_) {
+			add("nohome", genutil.StrTernary(login, "warn", "info"), "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("home %s does not exist", xus.Home_))
+		}
+		if (len(_in.Shells_) > 0) && (len(xus.Shell_) > 0) && login && !genutil.SliceContainsStr(_in.Shells_, xus.Shell_) {
+			add("badshell", "warn", "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("shell %s not in /etc/shells", xus.Shell_))
+		}
+		if uid, err := strconv.Atoi(xus.Uid_); (err == nil) && (uid > 0) && (uid < _in.Uidmin_) && login {
+			add("systemlogin", "warn", "passwd", xus.Username_, xus.Uid_, fmt.Sprintf("system account with login shell %s", genutil.StrTernary(len(xus.Shell_) > 0, xus.Shell_, "/bin/sh")))
+		}
+	}
+	for _, kk := range etcshadow.SortedKeys_String2PtrShadowdata(&_in.Shadow_) {
+		if byName[kk] == nil {
+			add("nopasswd", "warn", "shadow", kk, "", "no passwd entry")
+		}
+	}
+	groupNames := map[string]*etcgroup.Groupdata{}
+	groupGids := map[string]*etcgroup.Groupdata{}
+	for _, xgr := range _in.Groups_ {
+		if prev := groupNames[xgr.Groupname_]; prev != nil {
+			add("duplicategroup", "error", "group", xgr.Groupname_, xgr.Gid_, fmt.Sprintf("%s already has gid %s on line%d", xgr.Groupname_, prev.Gid_, prev.Lineno_))
+		} else {
+			groupNames[xgr.Groupname_] = xgr
+			if hasGshadow && (xgr.Ingshadow_ != "yes") {
+				add("nogshadow", "warn", "group", xgr.Groupname_, xgr.Gid_, "no gshadow entry")
+			}
+		}
+		if prev := groupGids[xgr.Gid_]; (prev != nil) && (prev.Groupname_ != xgr.Groupname_) {
+			add("duplicategid", "warn", "group", xgr.Groupname_, xgr.Gid_, fmt.Sprintf("gid %s already used by %s", xgr.Gid_, prev.Groupname_))
+		} else if prev == nil {
+			groupGids[xgr.Gid_] = xgr
+		}
+	}
+	return audits
+}
+
+// UserAudit checks this host's passwd, shadow, group and gshadow files; shadow needs root
+func UserAudit(_verbose bool) []*Auditdata {
+	in := &Auditinput{Uidmin_: 1000, Isdir_: isdir}
+	if file, err := os.Open("/etc/shadow"); err == nil {
+		file.Close()
+		in.Shadow_ = etcshadow.Shadow(_verbose)
+	} else if _verbose {
+		fmt.Printf("UserAudit: %s, shadow checks skipped\n", err)
+	}
+	if groups, err := etcgroup.ReadGroups(_verbose); err == nil {
+		in.Groups_ = groups
+	} else if _verbose {
+		fmt.Printf("UserAudit: %s\n", err)
+	}
+	if buf, err := ioutil.ReadFile("/etc/shells"); err == nil {
+		in.Shells_ = ParseShells(string(buf))
+	}
+	if buf, err := ioutil.ReadFile("/etc/login.defs"); err == nil {
+		in.Uidmin_ = ParseUidmin(string(buf))
+	}
+	audits := Audit(Passwd(_verbose), in)
+	if _verbose {
+		for _, audit := range audits {
+			audit.Print()
+		}
+	}
+	return audits
+}
@@ -0,0 +1,91 @@
+package etcuser
+
+import (
+	"github.com/LDCS/qslinux/etcgroup"
+	"github.com/LDCS/qslinux/etcshadow"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// user is a passwd entry for the tests
+func user(_name, _uid, _gid, _home, _shell string) *Userdata {
+	return &Userdata{Username_: _name, Uid_: _uid, Gid_: _gid, Home_: _home, Shell_: _shell}
+}
+
+// shadow is a shadow map with entries for _names
+func shadow(_names ...string) map[string]*etcshadow.Shadowdata {
+	smap := map[string]*etcshadow.Shadowdata{}
+	for _, name := range _names {
+		smap[name] = &etcshadow.Shadowdata{Shadowname_: name}
+	}
+	return smap
+}
+
+func TestAudit(t *testing.T) {
+	groups := etcgroup.ParseGroup("root:x:0:\ndaemon:x:2:\nusers:x:100:alice\n", false)
+	gshadowed := etcgroup.ParseGroup("root:x:0:\ndaemon:x:2:\nusers:x:100:\n", false)
+	etcgroup.MergeGshadow(gshadowed, "root:!::\nusers:!::\n")
+	duplicated := etcgroup.ParseGroup("root:x:0:\nroot:x:5:\nwheel:x:0:\n", false)
+	dirs := map[string]bool{"/root": true, "/home/alice": true, "/sbin": true}
+	isdir := func(_path string) bool { return dirs[_path] }
+	shells := ParseShells("# /etc/shells: valid login shells\n/bin/sh\n/bin/bash\n\n/usr/bin/zsh\n")
+	root := user("root", "0", "0", "/root", "/bin/bash")
+	alice := user("alice", "1000", "100", "/home/alice", "/bin/bash")
+	daemon := user("daemon", "2", "2", "/sbin", "/usr/sbin/nologin")
+	tests := []struct {
+		name  string
+		users []*Userdata
+		in    *Auditinput
+		want  []string // check/severity/name, sorted
+	}{
+		{"clean", []*Userdata{root, daemon, alice}, &Auditinput{Shadow_: shadow("root", "daemon", "alice"), Groups_: groups, Shells_: shells, Uidmin_: 1000, Isdir_: isdir}, []string{}},
+		{"nothing readable", []*Userdata{root, user("bob", "1001", "999", "/home/bob", "/bin/csh")}, &Auditinput{Uidmin_: 1000}, []string{}},
+		{"shadow mismatch", []*Userdata{root, alice}, &Auditinput{Shadow_: shadow("root", "ghost")},
+			[]string{"noshadow/error/alice", "nopasswd/warn/ghost"}},
+		{"duplicate names", []*Userdata{root, alice, user("alice", "1001", "100", "/home/alice", "/bin/bash")}, &Auditinput{Uidmin_: 1000},
+			[]string{"duplicatename/error/alice"}},
+		{"duplicate uids", []*Userdata{root, user("toor", "0", "0", "/root", "/bin/bash"), alice, user("alice2", "1000", "100", "/home/alice", "/bin/bash")}, &Auditinput{Uidmin_: 1000},
+			[]string{"duplicateuid/error/toor", "duplicateuid/warn/alice2"}},
+		{"missing primary group", []*Userdata{root, user("bob", "1001", "999", "/home/alice", "/bin/bash")}, &Auditinput{Groups_: groups, Uidmin_: 1000},
+			[]string{"noprimarygroup/warn/bob"}},
+		{"missing homes", []*Userdata{root, user("bob", "1001", "100", "/home/bob", "/bin/bash"), user("nobody", "65534", "100", "/nonexistent", "/usr/sbin/nologin")}, &Auditinput{Uidmin_: 1000, Isdir_: isdir},
+			[]string{"nohome/info/nobody", "nohome/warn/bob"}},
+		{"shells", []*Userdata{root, user("bob", "1001", "100", "/home/bob", "/bin/csh"), user("carol", "1002", "100", "/home/carol", ""), user("sync", "5", "0", "/sbin", "/bin/sync")}, &Auditinput{Shells_: shells, Uidmin_: 1000},
+			[]string{"badshell/warn/bob"}},
+		{"system logins", []*Userdata{root, daemon, user("postgres", "26", "26", "/var/lib/pgsql", "/bin/bash"), user("legacy", "300", "100", "/home/legacy", ""), alice}, &Auditinput{Uidmin_: 500},
+			[]string{"systemlogin/warn/legacy", "systemlogin/warn/postgres"}},
+		{"groups", []*Userdata{root}, &Auditinput{Groups_: duplicated, Uidmin_: 1000},
+			[]string{"duplicategid/warn/wheel", "duplicategroup/error/root"}},
+		{"gshadow", []*Userdata{root, daemon}, &Auditinput{Groups_: gshadowed, Uidmin_: 1000},
+			[]string{"nogshadow/warn/daemon"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, audit := range Audit(tt.users, tt.in) {
+			got = append(got, audit.Check_+"/"+audit.Severity_+"/"+audit.Name_)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseUidmin(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"# UID_MIN 10\nPASS_MAX_DAYS 99999\nUID_MIN\t\t\t  500\nUID_MAX 60000\n", 500},
+		{"SYS_UID_MIN 201\n", 1000},
+		{"UID_MIN lots\n", 1000},
+		{"", 1000},
+	}
+	for _, tt := range tests {
+		if got := ParseUidmin(tt.text); got != tt.want {
+			t.Errorf("ParseUidmin(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
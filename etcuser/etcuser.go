@@ -6,6 +6,7 @@ package etcuser
 import (
 	"fmt"
 	"github.com/LDCS/genutil"
+	"github.com/LDCS/qslinux/etcgroup"
 	"sort"
 	"strings"
 )
@@ -95,21 +96,54 @@ func (self *Userdata) Print() {
 	fmt.Printf(fmt.Sprint())
 }
 
-// User extracts user info from etc passwd
+// User extracts user info from etc passwd and group; Lastlogin_, HomeFS_ and HomeUsedGB_ are left empty, see UserWith
 func User(_verbose bool) (smap map[string]*Userdata) {
+	return UserWith(false, false, _verbose)
+}
+
+// UserWith is User, also filling Lastlogin_ from lastlog and wtmp if _lastlogin,
+// and HomeFS_ and HomeUsedGB_ if _homes, which runs repquota and may walk home directories for up to HomeWalkTimeout
+func UserWith(_lastlogin, _homes, _verbose bool) (smap map[string]*Userdata) {
 	smap = make(map[string]*Userdata)
+	for _, lastxus := range Passwd(_verbose) {
+		if smap[lastxus.Username_] == nil { // the first entry wins, as for getpwnam
+			smap[lastxus.Username_] = lastxus
+		}
+	}
+	if groups, err := etcgroup.ReadGroups(_verbose); err == nil {
+		SetGroups(smap, groups)
+	} else if _verbose {
+		fmt.Printf("User: %s\n", err)
+	}
+	if _lastlogin {
+		SetLastlogin(smap, LoginHistory(_verbose), _verbose)
+	}
+	if _homes {
+		setHomes(smap, _verbose)
+	}
+	return smap
+}
+
+// Passwd lists the /etc/passwd entries in file order, repeated names and uids included
+func Passwd(_verbose bool) []*Userdata {
 	out := genutil.BashExecOrDie(_verbose, "/bin/cat /etc/passwd | sed -e 's/[ ]/_/g'", ".")
 	if _verbose {
 		fmt.Println(out)
 	}
-	lines := genutil.CleanAndSplitOnSeparator(out, ":", ",")
+	return ParsePasswd(out, _verbose)
+}
+
+// ParsePasswd parses passwd text; lines without 7 fields are skipped
+func ParsePasswd(_out string, _verbose bool) []*Userdata {
+	users := []*Userdata{}
+	lines := genutil.CleanAndSplitOnSeparator(_out, ":", ",")
 	for ii, line := range lines {
 		items := strings.Split(line, ",")
 		num := len(items)
 		if _verbose {
 			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
 		}
-		if num < 6 {
+		if num < 7 {
 			continue
 		}
 		lastxus := new(Userdata)
@@ -123,10 +157,14 @@ func User(_verbose bool) (smap map[string]*Userdata) {
 		lastxus.Lastlogin_ = ""
 		lastxus.HomeFS_ = ""
 		lastxus.HomeUsedGB_ = ""
-		smap[lastxus.Username_] = lastxus
-		if _verbose {
-			fmt.Printf("line%d: item0(%s) %s\n", ii, items[0], strings.Join(items, "#"))
-		}
+		users = append(users, lastxus)
+	}
+	return users
+}
+
+// SetGroups fills Groups_ with the primary group then the supplementary groups of each user, pipe separated
+func SetGroups(_smap map[string]*Userdata, _groups []*etcgroup.Groupdata) {
+	for _, xus := range _smap {
+		xus.Groups_ = strings.Join(etcgroup.UserGroups(_groups, xus.Username_, xus.Gid_), "|")
 	}
-	return smap
 }
@@ -0,0 +1,143 @@
+package etcuser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LDCS/genutil"
+	"github.com/LDCS/qslinux/mounts"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// HomeWalkTimeout bounds the directory walks of all homes together, homes not reached in time get no usage
+	HomeWalkTimeout = 60 * time.Second
+	// HomeWalkMaxEntries bounds the walk of one home, the usage of a home cut short is reported with a > prefix
+	HomeWalkMaxEntries = 1000000
+)
+
+// ParseRepquota parses repquota -a -u -n output into used KB by device, then uid
+func ParseRepquota(_out string) map[string]map[string]int64 {
+	quotas := map[string]map[string]int64{}
+	device := ""
+	for _, line := range strings.Split(_out, "\n") {
+		if strings.HasPrefix(line, "*** Report for user quotas on device ") {
+			device = strings.TrimSpace(strings.TrimPrefix(line, "*** Report for user quotas on device "))
+			quotas[device] = map[string]int64{}
+			continue
+		}
+		items := strings.Fields(line)
+		if (len(device) == 0) || (len(items) < 3) || !strings.HasPrefix(items[0], "#") {
+			continue
+		}
+		if kb, err := strconv.ParseInt(strings.TrimSuffix(items[2], "*"), 10, 64); err == nil {
+			quotas[device][strings.TrimPrefix(items[0], "#")] = kb
+		}
+	}
+	return quotas
+}
+
+// Repquota collects the user quota usage of all filesystems with quotas on, empty without quota tools or quotas
+func Repquota(_verbose bool) map[string]map[string]int64 {
+	out := genutil.BashExecOrDie(_verbose, "/usr/bin/timeout 60 repquota -a -u -n 2>/dev/null || true", ".")
+	return ParseRepquota(out)
+}
+
+// DirUsageKB adds up the disk usage of a directory tree in KB, staying on its filesystem and counting hard links once.
+// The walk stops early when _ctx is done or after _maxEntries entries, complete is then false and kb a lower bound
+func DirUsageKB(_ctx context.Context, _path string, _maxEntries int) (kb int64, complete bool, err error) {
+	top, err := os.Lstat(_path)
+	if err != nil {
+		return 0, false, err
+	}
+	dev := top.Sys().(*syscall.Stat_t).Dev
+	seen := map[uint64]bool{}
+	entries := 0
+	var blocks int64
+	errStop := errors.New("stop")
+	err = filepath.Walk(_path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped, as du does
+		}
+		if entries++; (entries > _maxEntries) || (_ctx.Err() != nil) {
+			return errStop
+		}
+		st := info.Sys().(*syscall.Stat_t)
+		if st.Dev != dev {
+			return filepath.SkipDir
+		}
+		if (st.Nlink > 1) && !info.IsDir() {
+			if seen[st.Ino] {
+				return nil
+			}
+			seen[st.Ino] = true
+		}
+		blocks += st.Blocks // 512 byte units
+		return nil
+	})
+	if err == errStop {
+		return blocks / 2, false, nil
+	}
+	return blocks / 2, err == nil, err
+}
+
+// homeMount resolves a home directory through symlinks to the mount it lives on, nil if the home does not exist
+func homeMount(_tree *mounts.Mounttree, _home string) (string, *mounts.Mountdata) {
+	home, err := filepath.EvalSymlinks(_home)
+	if err != nil {
+		return _home, nil
+	}
+	return home, _tree.Find(home)
+}
+
+// SetHomes fills HomeFS_ with the mountpoint|fstype of each existing home, and HomeUsedGB_ with the quota usage of the user on that filesystem,
+// or else with a walk of the home bounded by _ctx and _maxEntries. Homes shared by several users, and /, are not walked
+func SetHomes(_ctx context.Context, _smap map[string]*Userdata, _tree *mounts.Mounttree, _quotas map[string]map[string]int64, _maxEntries int, _verbose bool) {
+	owners := map[string]int{}
+	for _, xus := range _smap {
+		owners[filepath.Clean(xus.Home_)]++
+	}
+	for _, kk := range SortedKeys_String2PtrUserdata(&_smap) {
+		xus := _smap[kk]
+		xus.HomeFS_, xus.HomeUsedGB_ = "", ""
+		home, md := homeMount(_tree, xus.Home_)
+		if md == nil {
+			continue
+		}
+		xus.HomeFS_ = md.Mountpoint_ + "|" + md.Fstype_
+		if kb, ok := _quotas[md.Source_][xus.Uid_]; ok {
+			xus.HomeUsedGB_ = genutil.KB2GB(fmt.Sprint(kb))
+			continue
+		}
+		if (home == "/") || (owners[filepath.Clean(xus.Home_)] > 1) || (_ctx.Err() != nil) {
+			continue
+		}
+		kb, complete, err := DirUsageKB(_ctx, home, _maxEntries)
+		if err != nil {
+			if _verbose {
+				fmt.Printf("SetHomes: %s: %s\n", xus.Username_, err)
+			}
+			continue
+		}
+		xus.HomeUsedGB_ = genutil.StrTernary(complete, "", ">") + genutil.KB2GB(fmt.Sprint(kb))
+	}
+}
+
+// setHomes is SetHomes over this host's mounts and quotas, walking for at most HomeWalkTimeout
+func setHomes(_smap map[string]*Userdata, _verbose bool) {
+	tree, err := mounts.Tree(_verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("setHomes: %s\n", err)
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), HomeWalkTimeout)
+	defer cancel()
+	SetHomes(ctx, _smap, tree, Repquota(_verbose), HomeWalkMaxEntries, _verbose)
+}
@@ -0,0 +1,110 @@
+package etcuser
+
+import (
+	"context"
+	"github.com/LDCS/qslinux/mounts"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// repquotaOut is repquota -a -u -n output of a box with quotas on two filesystems;
+// +- and -+ flag users over their block or inode soft limit, and older quota tools mark such usage with a * suffix
+const repquotaOut = `*** Report for user quotas on device /dev/mapper/vg0-home
+Block grace time: 7days; Inode grace time: 7days
+                        Block limits                File limits
+User            used    soft    hard  grace    used  soft  hard  grace
+----------------------------------------------------------------------
+#0        --      20       0       0              2     0     0       
+#1000     +-  5242880 5000000 6000000  6days    1203     0     0       
+#1001     -+     1024       0       0          10001 10000 12000  none
+#1002     +-    20480*  10240   40960  none      12     0     0       
+
+*** Report for user quotas on device /dev/sdc1
+Block grace time: 7days; Inode grace time: 7days
+                        Block limits                File limits
+User            used    soft    hard  grace    used  soft  hard  grace
+----------------------------------------------------------------------
+#1000     --   102400       0       0             40     0     0       
+
+`
+
+func TestParseRepquota(t *testing.T) {
+	quotas := ParseRepquota(repquotaOut)
+	tests := []struct {
+		device, uid string
+		kb          int64
+		ok          bool
+	}{
+		{"/dev/mapper/vg0-home", "0", 20, true},
+		{"/dev/mapper/vg0-home", "1000", 5242880, true},
+		{"/dev/mapper/vg0-home", "1001", 1024, true},
+		{"/dev/mapper/vg0-home", "1002", 20480, true},
+		{"/dev/sdc1", "1000", 102400, true},
+		{"/dev/sdc1", "1001", 0, false},
+		{"/dev/sdd1", "1000", 0, false},
+	}
+	for _, tt := range tests {
+		kb, ok := quotas[tt.device][tt.uid]
+		if (kb != tt.kb) || (ok != tt.ok) {
+			t.Errorf("%s uid %s = %d %v, want %d %v", tt.device, tt.uid, kb, ok, tt.kb, tt.ok)
+		}
+	}
+	if len(quotas) != 2 {
+		t.Errorf("%d devices, want 2", len(quotas))
+	}
+	if quotas := ParseRepquota(""); len(quotas) != 0 {
+		t.Errorf("empty output gave %v", quotas)
+	}
+}
+
+func TestSetHomes(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), make([]byte, 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tree := mounts.NewTree(mounts.ParseMountinfo("1 1 8:1 / / rw - ext4 /dev/sda1 rw\n", false))
+	smap := map[string]*Userdata{
+		"alice":  {Username_: "alice", Uid_: "1000", Home_: dir},
+		"bob":    {Username_: "bob", Uid_: "1001", Home_: dir + "/../" + filepath.Base(dir)},
+		"nobody": {Username_: "nobody", Uid_: "65534", Home_: "/nonexistent"},
+		"quota":  {Username_: "quota", Uid_: "1002", Home_: dir},
+		"root":   {Username_: "root", Uid_: "0", Home_: "/"},
+	}
+	SetHomes(context.Background(), smap, tree, map[string]map[string]int64{"/dev/sda1": {"1002": 2048}}, 1000, false)
+	if xus := smap["nobody"]; (xus.HomeFS_ != "") || (xus.HomeUsedGB_ != "") {
+		t.Errorf("nonexistent home: %s", xus.Sprint())
+	}
+	if xus := smap["root"]; (xus.HomeFS_ != "/|ext4") || (xus.HomeUsedGB_ != "") {
+		t.Errorf("/ must not be walked: %s", xus.Sprint())
+	}
+	if xus := smap["quota"]; (xus.HomeFS_ != "/|ext4") || (len(xus.HomeUsedGB_) == 0) {
+		t.Errorf("quota user: %s", xus.Sprint())
+	}
+	if xus := smap["alice"]; xus.HomeFS_ != "/|ext4" {
+		t.Errorf("alice: %s", xus.Sprint())
+	}
+}
+
+func TestDirUsageKB(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), make([]byte, 256*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	kb, complete, err := DirUsageKB(context.Background(), dir, 100)
+	if (err != nil) || !complete || (kb < 256) || (kb >= 512) {
+		t.Errorf("DirUsageKB = %d %v %v, want the hard linked file counted once", kb, complete, err)
+	}
+	if _, complete, _ := DirUsageKB(context.Background(), dir, 1); complete {
+		t.Errorf("walk of 3 entries bounded to 1 reported complete")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, complete, _ := DirUsageKB(ctx, dir, 100); complete {
+		t.Errorf("cancelled walk reported complete")
+	}
+}
@@ -0,0 +1,314 @@
+package etcuser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record sizes of the glibc lastlog and utmp structs, the same on 32 and 64 bit linux since times are kept as int32
+const (
+	LastlogSize = 292 // int32 ll_time, char ll_line[32], char ll_host[256]
+	UtmpSize    = 384 // see utmp(5)
+)
+
+// ut_type values, see utmp(5)
+const (
+	UtRunlevel = 1
+	UtBoot     = 2
+	UtLogin    = 6
+	UtUser     = 7
+	UtDead     = 8
+)
+
+// Logindata holds one login session of the login history, or the lastlog entry of a user
+type Logindata struct {
+	User_      string
+	Line_      string // terminal, e.g. pts/0 or tty1
+	Host_      string // remote host, empty for console logins
+	Addr_      string // remote address when wtmp has one
+	Login_     string
+	Logout_    string // empty while logged in
+	Status_    string // loggedin, logout, gone (no logout record), down (shutdown), crash (boot without shutdown), lastlog
+	Logintime_ time.Time
+}
+
+// Utmprecord holds one record of utmp or wtmp
+type Utmprecord struct {
+	Type_ int
+	Pid_  int
+	Line_ string
+	Id_   string
+	User_ string
+	Host_ string
+	Time_ time.Time
+	Addr_ string
+}
+
+const (
+	namesLogin     = "User,Line,Host,Addr,Login,Logout,Status"
+	hdrprefixLogin = ",xul."
+	loginFormat    = "2006-01-02 15:04:05"
+)
+
+var (
+	headerStringLogin  string
+	commaStringLogin   string
+	pctStringLogin     string
+	namePctStringLogin string
+	lastlogPath        = "/var/log/lastlog"
+	wtmpPath           = "/var/log/wtmp"
+)
+
+// init  is generic
+func init() {
+	headerStringLogin = (hdrprefixLogin + strings.Join(strings.Split(namesLogin, ","), hdrprefixLogin))[1:]
+	commaStringLogin = strings.Repeat(",", strings.Count(headerStringLogin, ","))
+	pctStringLogin = strings.Repeat(",%s", 1+strings.Count(headerStringLogin, ","))[1:]
+	namePctStringLogin = strings.Replace(namesLogin, ",", "=%s ", -1) + "=%s\n"
+}
+
+// LoginHeader is generic
+func LoginHeader() string { return headerStringLogin }
+
+// Csv is generic
+func (self *Logindata) Csv() string {
+	if self == nil {
+		return commaStringLogin
+	}
+	return fmt.Sprintf(pctStringLogin, self.User_, self.Line_, strings.Replace(self.Host_, ",", semi, -1), self.Addr_, self.Login_, self.Logout_, self.Status_)
+}
+
+// Sprint is generic
+func (self *Logindata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctStringLogin, self.User_, self.Line_, self.Host_, self.Addr_, self.Login_, self.Logout_, self.Status_)
+}
+
+// Print is generic
+func (self *Logindata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// Lastlogin renders a login for Userdata.Lastlogin_ as time|terminal|host
+func (self *Logindata) Lastlogin() string {
+	if self == nil {
+		return ""
+	}
+	return strings.Join([]string{self.Login_, self.Line_, strings.Replace(self.Host_, ",", semi, -1)}, "|")
+}
+
+// cstring is a NUL padded char array as a string
+func cstring(_buf []byte) string {
+	if ii := bytes.IndexByte(_buf, 0); ii >= 0 {
+		_buf = _buf[:ii]
+	}
+	return strings.TrimSpace(string(_buf))
+}
+
+// ParseLastlog parses one lastlog record, nil if the user never logged in
+func ParseLastlog(_buf []byte) *Logindata {
+	if len(_buf) < LastlogSize {
+		return nil
+	}
+	sec := int32(binary.LittleEndian.Uint32(_buf[0:4]))
+	if sec == 0 {
+		return nil
+	}
+	xul := &Logindata{Line_: cstring(_buf[4:36]), Host_: cstring(_buf[36:292]), Status_: "lastlog", Logintime_: time.Unix(int64(sec), 0)}
+	xul.Login_ = xul.Logintime_.Format(loginFormat)
+	return xul
+}
+
+// ReadLastlog reads the lastlog record of a uid; the file is sparse and indexed by uid, a uid past its end never logged in
+func ReadLastlog(_file io.ReaderAt, _uid int) (*Logindata, error) {
+	buf := make([]byte, LastlogSize)
+	_, err := _file.ReadAt(buf, int64(_uid)*LastlogSize)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseLastlog(buf), nil
+}
+
+// utmpAddr renders ut_addr_v6, which holds an IPv4 address in its first word only
+func utmpAddr(_buf []byte) string {
+	if bytes.Equal(_buf, make([]byte, 16)) {
+		return ""
+	}
+	if bytes.Equal(_buf[4:], make([]byte, 12)) {
+		return net.IP(_buf[:4]).String()
+	}
+	return net.IP(_buf).String()
+}
+
+// parseUtmpRecord parses one utmp record of UtmpSize bytes
+func parseUtmpRecord(_rec []byte) *Utmprecord {
+	ut := new(Utmprecord)
+	ut.Type_ = int(int16(binary.LittleEndian.Uint16(_rec[0:2])))
+	ut.Pid_ = int(int32(binary.LittleEndian.Uint32(_rec[4:8])))
+	ut.Line_ = cstring(_rec[8:40])
+	ut.Id_ = cstring(_rec[40:44])
+	ut.User_ = cstring(_rec[44:76])
+	ut.Host_ = cstring(_rec[76:332])
+	ut.Time_ = time.Unix(int64(int32(binary.LittleEndian.Uint32(_rec[340:344]))), 0)
+	ut.Addr_ = utmpAddr(_rec[348:364])
+	return ut
+}
+
+// ParseUtmp parses utmp or wtmp contents into records in file order; a truncated last record is dropped
+func ParseUtmp(_buf []byte) []*Utmprecord {
+	records := []*Utmprecord{}
+	for off := 0; off+UtmpSize <= len(_buf); off += UtmpSize {
+		records = append(records, parseUtmpRecord(_buf[off:off+UtmpSize]))
+	}
+	return records
+}
+
+// ScanUtmp reads utmp format records one at a time and hands them to _fn in file order, so that a large wtmp is never held in memory.
+// A truncated last record is dropped
+func ScanUtmp(_rd io.Reader, _fn func(ut *Utmprecord)) error {
+	rd := bufio.NewReaderSize(_rd, 64*UtmpSize)
+	rec := make([]byte, UtmpSize)
+	for {
+		_, err := io.ReadFull(rd, rec)
+		if (err == io.EOF) || (err == io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_fn(parseUtmpRecord(rec))
+	}
+}
+
+// ReadWtmp reads a utmp format file
+func ReadWtmp(_path string) ([]*Utmprecord, error) {
+	file, err := os.Open(_path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := []*Utmprecord{}
+	err = ScanUtmp(file, func(ut *Utmprecord) { records = append(records, ut) })
+	return records, err
+}
+
+// sessions pairs login and logout records into sessions as they come, as last(1) does
+type sessions struct {
+	history []*Logindata
+	open    map[string]*Logindata // by terminal
+}
+
+// closeAll ends every open session
+func (self *sessions) closeAll(_when time.Time, _status string) {
+	for line, xul := range self.open {
+		xul.Logout_, xul.Status_ = _when.Format(loginFormat), _status
+		delete(self.open, line)
+	}
+}
+
+// add takes the next wtmp record
+func (self *sessions) add(_ut *Utmprecord) {
+	switch {
+	case _ut.Type_ == UtUser:
+		if xul := self.open[_ut.Line_]; xul != nil {
+			xul.Status_ = "gone"
+		}
+		xul := &Logindata{User_: _ut.User_, Line_: _ut.Line_, Host_: _ut.Host_, Addr_: _ut.Addr_, Login_: _ut.Time_.Format(loginFormat), Status_: "loggedin", Logintime_: _ut.Time_}
+		self.open[_ut.Line_] = xul
+		self.history = append(self.history, xul)
+	case _ut.Type_ == UtDead:
+		if xul := self.open[_ut.Line_]; xul != nil {
+			xul.Logout_, xul.Status_ = _ut.Time_.Format(loginFormat), "logout"
+			delete(self.open, _ut.Line_)
+		}
+	case (_ut.Type_ == UtRunlevel) && (_ut.User_ == "shutdown"):
+		self.closeAll(_ut.Time_, "down")
+	case _ut.Type_ == UtBoot:
+		self.closeAll(_ut.Time_, "crash")
+	}
+}
+
+// History pairs the login and logout records of wtmp into sessions in login order, as last(1) does
+func History(_records []*Utmprecord) []*Logindata {
+	xs := &sessions{history: []*Logindata{}, open: map[string]*Logindata{}}
+	for _, ut := range _records {
+		xs.add(ut)
+	}
+	return xs.history
+}
+
+// ReadHistory is History over a utmp format file, streamed record by record
+func ReadHistory(_path string) ([]*Logindata, error) {
+	file, err := os.Open(_path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	xs := &sessions{history: []*Logindata{}, open: map[string]*Logindata{}}
+	if err := ScanUtmp(file, xs.add); err != nil {
+		return nil, err
+	}
+	return xs.history, nil
+}
+
+// LoginHistory extracts the login sessions of /var/log/wtmp in login order
+func LoginHistory(_verbose bool) []*Logindata {
+	history, err := ReadHistory(wtmpPath)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("LoginHistory: %s\n", err)
+		}
+		return []*Logindata{}
+	}
+	if _verbose {
+		for _, xul := range history {
+			xul.Print()
+		}
+	}
+	return history
+}
+
+// SetLastlogin fills Lastlogin_ with the later of the lastlog entry and the latest session of the user in _history,
+// since some distributions no longer write lastlog
+func SetLastlogin(_smap map[string]*Userdata, _history []*Logindata, _verbose bool) {
+	latest := map[string]*Logindata{}
+	for _, xul := range _history {
+		if (latest[xul.User_] == nil) || xul.Logintime_.After(latest[xul.User_].Logintime_) {
+			latest[xul.User_] = xul
+		}
+	}
+	file, err := os.Open(lastlogPath)
+	if err == nil {
+		defer file.Close()
+	} else if _verbose {
+		fmt.Printf("SetLastlogin: %s\n", err)
+	}
+	for _, kk := range SortedKeys_String2PtrUserdata(&_smap) {
+		xus := _smap[kk]
+		last := latest[xus.Username_]
+		if uid, err := strconv.Atoi(xus.Uid_); (err == nil) && (file != nil) {
+			if xul, err := ReadLastlog(file, uid); (xul != nil) && ((last == nil) || !last.Logintime_.After(xul.Logintime_)) {
+				last = xul
+			} else if (err != nil) && _verbose {
+				fmt.Printf("SetLastlogin: %s uid %d: %s\n", xus.Username_, uid, err)
+			}
+		}
+		xus.Lastlogin_ = last.Lastlogin()
+	}
+}
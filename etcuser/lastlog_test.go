@@ -0,0 +1,200 @@
+package etcuser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// utmpBytes lays out one glibc struct utmp of 384 bytes: ut_type at 0, ut_pid at 4, ut_line[32] at 8, ut_id[4] at 40,
+// ut_user[32] at 44, ut_host[256] at 76, ut_exit at 332, ut_session at 336, ut_tv at 340 and ut_addr_v6[16] at 348
+func utmpBytes(_type int16, _pid int32, _line, _id, _user, _host string, _sec int32, _addr []byte) []byte {
+	rec := make([]byte, UtmpSize)
+	binary.LittleEndian.PutUint16(rec[0:], uint16(_type))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(_pid))
+	copy(rec[8:40], _line)
+	copy(rec[40:44], _id)
+	copy(rec[44:76], _user)
+	copy(rec[76:332], _host)
+	binary.LittleEndian.PutUint32(rec[336:], 0xdeadbeef) // ut_session, must not leak into the time
+	binary.LittleEndian.PutUint32(rec[340:], uint32(_sec))
+	binary.LittleEndian.PutUint32(rec[344:], 999999) // ut_tv.tv_usec
+	copy(rec[348:364], _addr)
+	return rec
+}
+
+// lastlogBytes lays out one struct lastlog of 292 bytes: ll_time at 0, ll_line[32] at 4, ll_host[256] at 36
+func lastlogBytes(_sec int32, _line, _host string) []byte {
+	rec := make([]byte, LastlogSize)
+	binary.LittleEndian.PutUint32(rec[0:], uint32(_sec))
+	copy(rec[4:36], _line)
+	copy(rec[36:292], _host)
+	return rec
+}
+
+func TestParseUtmp(t *testing.T) {
+	longHost := strings.Repeat("h", 256) // fills ut_host with no NUL
+	buf := bytes.Join([][]byte{
+		utmpBytes(UtBoot, 0, "~", "~~", "reboot", "5.15.0-91-generic", 1700000000, nil),
+		utmpBytes(UtUser, 4242, "pts/0", "ts/0", "alice", "bastion.example.com", 1700000100, []byte{192, 0, 2, 7}),
+		utmpBytes(UtUser, 4243, "pts/1", "ts/1", strings.Repeat("u", 32), longHost, -1, []byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}),
+		utmpBytes(UtDead, 4242, "pts/0", "ts/0", "", "", 1700000200, nil),
+	}, nil)
+	tests := []struct {
+		name string
+		want Utmprecord
+	}{
+		{"boot", Utmprecord{Type_: UtBoot, Line_: "~", Id_: "~~", User_: "reboot", Host_: "5.15.0-91-generic", Time_: time.Unix(1700000000, 0)}},
+		{"ipv4", Utmprecord{Type_: UtUser, Pid_: 4242, Line_: "pts/0", Id_: "ts/0", User_: "alice", Host_: "bastion.example.com", Time_: time.Unix(1700000100, 0), Addr_: "192.0.2.7"}},
+		{"full fields, ipv6", Utmprecord{Type_: UtUser, Pid_: 4243, Line_: "pts/1", Id_: "ts/1", User_: strings.Repeat("u", 32), Host_: longHost, Time_: time.Unix(-1, 0), Addr_: "2001:db8::1"}},
+		{"dead", Utmprecord{Type_: UtDead, Pid_: 4242, Line_: "pts/0", Id_: "ts/0", Time_: time.Unix(1700000200, 0)}},
+	}
+	for _, data := range [][]byte{buf, append(buf, make([]byte, UtmpSize-1)...)} { // a truncated last record is dropped
+		records := ParseUtmp(data)
+		streamed := []*Utmprecord{}
+		if err := ScanUtmp(bytes.NewReader(data), func(ut *Utmprecord) { streamed = append(streamed, ut) }); err != nil {
+			t.Fatalf("ScanUtmp: %s", err)
+		}
+		if (len(records) != len(tests)) || (len(streamed) != len(tests)) {
+			t.Fatalf("got %d parsed, %d streamed records, want %d", len(records), len(streamed), len(tests))
+		}
+		for ii, tt := range tests {
+			for _, got := range []*Utmprecord{records[ii], streamed[ii]} {
+				if !got.Time_.Equal(tt.want.Time_) {
+					t.Errorf("%s: time %v, want %v", tt.name, got.Time_, tt.want.Time_)
+				}
+				got.Time_ = tt.want.Time_
+				if *got != tt.want {
+					t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseLastlog(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  []byte
+		want *Logindata // nil for never logged in
+	}{
+		{"never", make([]byte, LastlogSize), nil},
+		{"short", lastlogBytes(1700000000, "tty1", "")[:LastlogSize-1], nil},
+		{"console", lastlogBytes(1700000000, "tty1", ""), &Logindata{Line_: "tty1", Status_: "lastlog", Logintime_: time.Unix(1700000000, 0)}},
+		{"remote", lastlogBytes(1700000300, "pts/3", "10.1.2.3"), &Logindata{Line_: "pts/3", Host_: "10.1.2.3", Status_: "lastlog", Logintime_: time.Unix(1700000300, 0)}},
+		{"full fields", lastlogBytes(1700000400, strings.Repeat("l", 32), strings.Repeat("h", 256)), &Logindata{Line_: strings.Repeat("l", 32), Host_: strings.Repeat("h", 256), Status_: "lastlog", Logintime_: time.Unix(1700000400, 0)}},
+	}
+	for _, tt := range tests {
+		got := ParseLastlog(tt.rec)
+		if (got == nil) || (tt.want == nil) {
+			if got != tt.want {
+				t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			}
+			continue
+		}
+		if (got.Line_ != tt.want.Line_) || (got.Host_ != tt.want.Host_) || (got.Status_ != tt.want.Status_) || !got.Logintime_.Equal(tt.want.Logintime_) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got.Login_ != tt.want.Logintime_.Format(loginFormat) {
+			t.Errorf("%s: Login_ %s", tt.name, got.Login_)
+		}
+	}
+}
+
+func TestReadLastlog(t *testing.T) {
+	// sparse file indexed by uid: uid 0 never logged in, uid 2 did, uid 3 is past the end
+	file := bytes.NewReader(bytes.Join([][]byte{make([]byte, LastlogSize), make([]byte, LastlogSize), lastlogBytes(1700000000, "pts/0", "gw")}, nil))
+	tests := []struct {
+		uid  int
+		line string // "" for never logged in
+	}{
+		{0, ""},
+		{2, "pts/0"},
+		{3, ""},
+		{1000, ""},
+	}
+	for _, tt := range tests {
+		got, err := ReadLastlog(file, tt.uid)
+		if err != nil {
+			t.Errorf("uid %d: %s", tt.uid, err)
+			continue
+		}
+		if ((got == nil) != (len(tt.line) == 0)) || ((got != nil) && (got.Line_ != tt.line)) {
+			t.Errorf("uid %d: got %+v, want line %q", tt.uid, got, tt.line)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	records := []*Utmprecord{
+		{Type_: UtUser, Line_: "pts/0", User_: "alice", Time_: time.Unix(100, 0)},
+		{Type_: UtUser, Line_: "pts/1", User_: "bob", Time_: time.Unix(110, 0)},
+		{Type_: UtDead, Line_: "pts/0", Time_: time.Unix(120, 0)},
+		{Type_: UtUser, Line_: "pts/1", User_: "carol", Time_: time.Unix(130, 0)},
+		{Type_: UtRunlevel, User_: "shutdown", Time_: time.Unix(140, 0)},
+		{Type_: UtBoot, User_: "reboot", Time_: time.Unix(150, 0)},
+		{Type_: UtUser, Line_: "tty1", User_: "root", Time_: time.Unix(160, 0)},
+		{Type_: UtBoot, User_: "reboot", Time_: time.Unix(170, 0)},
+		{Type_: UtUser, Line_: "pts/0", User_: "alice", Time_: time.Unix(180, 0)},
+		{Type_: UtDead, Line_: "pts/9", Time_: time.Unix(190, 0)}, // no login on pts/9
+	}
+	tests := []struct {
+		user, line, status string
+		logout             int64 // -1 for none
+	}{
+		{"alice", "pts/0", "logout", 120},
+		{"bob", "pts/1", "gone", -1},
+		{"carol", "pts/1", "down", 140},
+		{"root", "tty1", "crash", 170},
+		{"alice", "pts/0", "loggedin", -1},
+	}
+	history := History(records)
+	if len(history) != len(tests) {
+		t.Fatalf("got %d sessions, want %d", len(history), len(tests))
+	}
+	for ii, tt := range tests {
+		got := history[ii]
+		logout := ""
+		if tt.logout >= 0 {
+			logout = time.Unix(tt.logout, 0).Format(loginFormat)
+		}
+		if (got.User_ != tt.user) || (got.Line_ != tt.line) || (got.Status_ != tt.status) || (got.Logout_ != logout) {
+			t.Errorf("session %d: got %s", ii, got.Sprint())
+		}
+	}
+}
+
+func TestReadHistory(t *testing.T) {
+	buf := bytes.Join([][]byte{
+		utmpBytes(UtUser, 1, "pts/0", "ts/0", "alice", "gw", 100, nil),
+		utmpBytes(UtDead, 1, "pts/0", "ts/0", "", "", 200, nil),
+		utmpBytes(UtUser, 2, "pts/0", "ts/0", "bob", "", 300, nil),
+	}, nil)
+	file, err := ioutil.TempFile("", "wtmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(buf)
+	file.Close()
+	history, err := ReadHistory(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := History(ParseUtmp(buf))
+	if len(history) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(history), len(want))
+	}
+	for ii := range want {
+		if history[ii].Sprint() != want[ii].Sprint() {
+			t.Errorf("session %d: got %s want %s", ii, history[ii].Sprint(), want[ii].Sprint())
+		}
+	}
+	if _, err := ReadHistory(file.Name() + ".missing"); err == nil {
+		t.Errorf("missing file: no error")
+	}
+}
@@ -0,0 +1,256 @@
+// Package memory extracts a per DIMM inventory on linux, joining SMBIOS memory devices with EDAC error counters
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package memory
+
+import (
+	"fmt"
+	"github.com/LDCS/qslinux/dmidecode"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimmdata holds one DIMM slot
+type Dimmdata struct {
+	Dmi_          *dmidecode.Memorydevicedata // nil if EDAC knows a dimm that SMBIOS does not
+	Edacmc_       string                      // e.g. mc0
+	Edacdimm_     string                      // e.g. dimm3 or csrow1/ch0
+	Edaclabel_    string                      // e.g. CPU_SrcID#0_Ha#0_Chan#1_DIMM#0, or DIMM_A1 once labels are set
+	Edaclocation_ string                      // e.g. channel 1 slot 0
+	Cecount_      string                      // corrected errors since boot
+	Uecount_      string                      // uncorrected errors since boot
+}
+
+const (
+	names     = "Edacmc,Edacdimm,Edaclabel,Edaclocation,Cecount,Uecount"
+	hdrprefix = ",mem."
+	semi      = ";"
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+	sysEdacMc     = "/sys/devices/system/edac/mc"
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// SortedKeys_String2PtrDimmdata is generic
+func SortedKeys_String2PtrDimmdata(_mp *map[string]*Dimmdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys_String2PtrDimmdata is generic
+func Keys_String2PtrDimmdata(_mp *map[string]*Dimmdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	return keys
+}
+
+// Header is generic
+func Header() string { return dmidecode.MemorydeviceHeader() + "," + headerString }
+
+// Csv is generic
+func (self *Dimmdata) Csv() string {
+	if self == nil {
+		return (*dmidecode.Memorydevicedata)(nil).Csv() + "," + commaString
+	}
+	return self.Dmi_.Csv() + "," + fmt.Sprintf(pctString, self.Edacmc_, self.Edacdimm_, self.Edaclabel_, self.Edaclocation_, self.Cecount_, self.Uecount_)
+}
+
+// Sprint is generic
+func (self *Dimmdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return strings.TrimSuffix(self.Dmi_.Sprint(), "\n") + " " + fmt.Sprintf(namePctString, self.Edacmc_, self.Edacdimm_, self.Edaclabel_, self.Edaclocation_, self.Cecount_, self.Uecount_)
+}
+
+// Print is generic
+func (self *Dimmdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// readEdac reads one edac sysfs file, "" if absent
+func readEdac(_path ...string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(_path...))
+	if err != nil {
+		return ""
+	}
+	return strings.Replace(strings.TrimSpace(string(buf)), ",", semi, -1)
+}
+
+// Edac reads the per dimm counters of all memory controllers, using the dimmN dirs of newer kernels and the csrowN/chN files of older ones
+func Edac(_verbose bool) []*Dimmdata {
+	dimms := []*Dimmdata{}
+	mcs, _ := filepath.Glob(filepath.Join(sysEdacMc, "mc[0-9]*"))
+	sort.Strings(mcs)
+	for _, mc := range mcs {
+		mcname := filepath.Base(mc)
+		dimmdirs, _ := filepath.Glob(filepath.Join(mc, "dimm[0-9]*"))
+		if len(dimmdirs) == 0 {
+			dimmdirs, _ = filepath.Glob(filepath.Join(mc, "rank[0-9]*"))
+		}
+		sort.Strings(dimmdirs)
+		for _, dir := range dimmdirs {
+			dimm := &Dimmdata{Edacmc_: mcname, Edacdimm_: filepath.Base(dir)}
+			dimm.Edaclabel_ = readEdac(dir, "dimm_label")
+			dimm.Edaclocation_ = readEdac(dir, "dimm_location")
+			dimm.Cecount_ = readEdac(dir, "dimm_ce_count")
+			dimm.Uecount_ = readEdac(dir, "dimm_ue_count")
+			dimms = append(dimms, dimm)
+		}
+		if len(dimmdirs) > 0 {
+			continue
+		}
+		csrows, _ := filepath.Glob(filepath.Join(mc, "csrow[0-9]*"))
+		sort.Strings(csrows)
+		for _, csrow := range csrows {
+			labels, _ := filepath.Glob(filepath.Join(csrow, "ch[0-9]*_dimm_label"))
+			sort.Strings(labels)
+			for _, label := range labels {
+				ch := strings.TrimSuffix(filepath.Base(label), "_dimm_label")
+				dimm := &Dimmdata{Edacmc_: mcname, Edacdimm_: filepath.Base(csrow) + "/" + ch}
+				dimm.Edaclabel_ = readEdac(label)
+				dimm.Edaclocation_ = filepath.Base(csrow) + " " + ch
+				dimm.Cecount_ = readEdac(csrow, ch+"_ce_count")
+				dimm.Uecount_ = readEdac(csrow, "ue_count") // uncorrected errors are only counted per csrow
+				dimms = append(dimms, dimm)
+			}
+		}
+	}
+	if _verbose {
+		for _, dimm := range dimms {
+			fmt.Printf("Edac: %s", dimm.Sprint())
+		}
+	}
+	return dimms
+}
+
+// chunks splits a string into runs of digits and runs of other characters, e.g. DIMM_A, 10
+func chunks(_str string) []string {
+	out := []string{}
+	for ii := 0; ii < len(_str); {
+		jj := ii + 1
+		digit := (_str[ii] >= '0') && (_str[ii] <= '9')
+		for (jj < len(_str)) && (((_str[jj] >= '0') && (_str[jj] <= '9')) == digit) {
+			jj++
+		}
+		out = append(out, _str[ii:jj])
+		ii = jj
+	}
+	return out
+}
+
+// naturalLess orders strings with their numbers compared as numbers, so DIMM_A2 sorts before DIMM_A10 and DIMM_B1, and mc2 before mc10
+func naturalLess(_aa, _bb string) bool {
+	ca, cb := chunks(_aa), chunks(_bb)
+	for ii := 0; (ii < len(ca)) && (ii < len(cb)); ii++ {
+		if ca[ii] == cb[ii] {
+			continue
+		}
+		na, erra := strconv.Atoi(ca[ii])
+		nb, errb := strconv.Atoi(cb[ii])
+		if (erra == nil) && (errb == nil) && (na != nb) {
+			return na < nb
+		}
+		return ca[ii] < cb[ii]
+	}
+	return len(ca) < len(cb)
+}
+
+// isPopulated tells whether an SMBIOS slot holds a module
+func isPopulated(_dev *dmidecode.Memorydevicedata) bool {
+	return (len(_dev.Size_) > 0) && !strings.HasPrefix(_dev.Size_, "No Module")
+}
+
+// Join pairs SMBIOS memory devices with EDAC dimms whose label equals, or ends with, the SMBIOS locator.
+// The default kernel labels, e.g. CPU_SrcID#0_Ha#0_Chan#1_DIMM#0, never match a locator such as DIMM_A1; the populated slots left over
+// are then paired in order with the EDAC dimms left over, ordered by mc, channel and slot, provided there are as many of each.
+// SMBIOS slots without an EDAC match and EDAC dimms without an SMBIOS match keep their own rows.
+func Join(_devices map[string]*dmidecode.Memorydevicedata, _edac []*Dimmdata) (smap map[string]*Dimmdata) {
+	smap = make(map[string]*Dimmdata)
+	used := map[*Dimmdata]bool{}
+	unmatched := []string{}
+	for _, kk := range dmidecode.SortedKeys_String2PtrMemorydevicedata(&_devices) {
+		dev := _devices[kk]
+		for _, dimm := range _edac {
+			if used[dimm] || (len(dimm.Edaclabel_) == 0) || (len(dev.Locator_) == 0) {
+				continue
+			}
+			if (dimm.Edaclabel_ == dev.Locator_) || strings.HasSuffix(dimm.Edaclabel_, "_"+dev.Locator_) || strings.HasSuffix(dimm.Edaclabel_, "#"+dev.Locator_) {
+				used[dimm] = true
+				dimm.Dmi_ = dev
+				smap[kk] = dimm
+				break
+			}
+		}
+		if (smap[kk] == nil) && isPopulated(dev) {
+			unmatched = append(unmatched, kk)
+		}
+	}
+	rest := []*Dimmdata{}
+	for _, dimm := range _edac {
+		if !used[dimm] {
+			rest = append(rest, dimm)
+		}
+	}
+	if (len(unmatched) > 0) && (len(unmatched) == len(rest)) {
+		sort.SliceStable(unmatched, func(ii, jj int) bool {
+			return naturalLess(_devices[unmatched[ii]].Locator_, _devices[unmatched[jj]].Locator_)
+		})
+		sort.SliceStable(rest, func(ii, jj int) bool {
+			if rest[ii].Edacmc_ != rest[jj].Edacmc_ {
+				return naturalLess(rest[ii].Edacmc_, rest[jj].Edacmc_)
+			}
+			return naturalLess(rest[ii].Edaclocation_, rest[jj].Edaclocation_)
+		})
+		for ii, kk := range unmatched {
+			used[rest[ii]] = true
+			rest[ii].Dmi_ = _devices[kk]
+			smap[kk] = rest[ii]
+		}
+	}
+	for _, kk := range dmidecode.SortedKeys_String2PtrMemorydevicedata(&_devices) {
+		if smap[kk] == nil {
+			smap[kk] = &Dimmdata{Dmi_: _devices[kk]}
+		}
+	}
+	for _, dimm := range _edac {
+		if !used[dimm] {
+			smap[dimm.Edacmc_+"/"+dimm.Edacdimm_] = dimm
+		}
+	}
+	return smap
+}
+
+// Dimms is the per DIMM inventory: SMBIOS type 17 records (read via dmidecode.Smbios) with their EDAC error counters
+func Dimms(_verbose bool) map[string]*Dimmdata {
+	devices := dmidecode.MemorydevicesFromSections(dmidecode.Smbios(_verbose))
+	return Join(devices, Edac(_verbose))
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"github.com/LDCS/qslinux/dmidecode"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mksysfs writes files under a temp dir, e.g. "mc0/dimm0/dimm_label" -> "DIMM_A1\n"
+func mksysfs(t *testing.T, _files map[string]string) string {
+	dir, err := ioutil.TempDir("", "edac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range _files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// dimmFiles are the files of a dimmN dir of a newer kernel
+func dimmFiles(_files map[string]string, _dir, _label, _location, _ce, _ue string) {
+	_files[_dir+"/dimm_label"] = _label + "\n"
+	_files[_dir+"/dimm_location"] = _location + "\n"
+	_files[_dir+"/dimm_ce_count"] = _ce + "\n"
+	_files[_dir+"/dimm_ue_count"] = _ue + "\n"
+}
+
+func TestEdac(t *testing.T) {
+	files := map[string]string{}
+	dimmFiles(files, "mc0/dimm0", "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0", "channel 0 slot 0", "0", "0")
+	dimmFiles(files, "mc0/dimm3", "CPU_SrcID#0_Ha#0_Chan#1_DIMM#0", "channel 1 slot 0", "12", "0")
+	dimmFiles(files, "mc1/rank0", "mc#1csrow#0channel#0", "csrow 0 channel 0", "1", "0")
+	files["mc2/csrow0/ch0_dimm_label"] = "DIMM_A1\n"
+	files["mc2/csrow0/ch0_ce_count"] = "5\n"
+	files["mc2/csrow0/ch1_dimm_label"] = "DIMM_B1\n"
+	files["mc2/csrow0/ch1_ce_count"] = "0\n"
+	files["mc2/csrow0/ue_count"] = "2\n"
+	files["mc2/csrow0/size_mb"] = "4096\n"
+	files["power/control"] = "auto\n"
+	dir := mksysfs(t, files)
+	defer os.RemoveAll(dir)
+	saved := sysEdacMc
+	sysEdacMc = dir
+	defer func() { sysEdacMc = saved }()
+	tests := []Dimmdata{
+		{Edacmc_: "mc0", Edacdimm_: "dimm0", Edaclabel_: "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0", Edaclocation_: "channel 0 slot 0", Cecount_: "0", Uecount_: "0"},
+		{Edacmc_: "mc0", Edacdimm_: "dimm3", Edaclabel_: "CPU_SrcID#0_Ha#0_Chan#1_DIMM#0", Edaclocation_: "channel 1 slot 0", Cecount_: "12", Uecount_: "0"},
+		{Edacmc_: "mc1", Edacdimm_: "rank0", Edaclabel_: "mc#1csrow#0channel#0", Edaclocation_: "csrow 0 channel 0", Cecount_: "1", Uecount_: "0"},
+		{Edacmc_: "mc2", Edacdimm_: "csrow0/ch0", Edaclabel_: "DIMM_A1", Edaclocation_: "csrow0 ch0", Cecount_: "5", Uecount_: "2"},
+		{Edacmc_: "mc2", Edacdimm_: "csrow0/ch1", Edaclabel_: "DIMM_B1", Edaclocation_: "csrow0 ch1", Cecount_: "0", Uecount_: "2"},
+	}
+	dimms := Edac(false)
+	if len(dimms) != len(tests) {
+		t.Fatalf("got %d dimms, want %d", len(dimms), len(tests))
+	}
+	for ii, tt := range tests {
+		if *dimms[ii] != tt {
+			t.Errorf("dimm %d: got %+v, want %+v", ii, *dimms[ii], tt)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	device := func(_locator, _size string) *dmidecode.Memorydevicedata {
+		return &dmidecode.Memorydevicedata{Locator_: _locator, Size_: _size}
+	}
+	edac := func(_mc, _dimm, _label, _location string) *Dimmdata {
+		return &Dimmdata{Edacmc_: _mc, Edacdimm_: _dimm, Edaclabel_: _label, Edaclocation_: _location}
+	}
+	tests := []struct {
+		name    string
+		devices map[string]*dmidecode.Memorydevicedata
+		edac    []*Dimmdata
+		want    map[string]string // row key -> edac mc/dimm, "" for an SMBIOS row without EDAC, or locator for an EDAC row without SMBIOS
+	}{
+		{"labels set",
+			map[string]*dmidecode.Memorydevicedata{"DIMM_A1": device("DIMM_A1", "8192 MB"), "DIMM_B1": device("DIMM_B1", "8192 MB")},
+			[]*Dimmdata{edac("mc0", "dimm0", "CPU0_DIMM_B1", "channel 1 slot 0"), edac("mc0", "dimm1", "DIMM_A1", "channel 0 slot 0")},
+			map[string]string{"DIMM_A1": "mc0/dimm1", "DIMM_B1": "mc0/dimm0"}},
+		{"default labels, by mc, channel and slot",
+			map[string]*dmidecode.Memorydevicedata{
+				"P1-DIMMA1": device("P1-DIMMA1", "16 GB"), "P1-DIMMA2": device("P1-DIMMA2", "No Module Installed"), "P1-DIMMB1": device("P1-DIMMB1", "16 GB"),
+				"P2-DIMMA1": device("P2-DIMMA1", "16 GB"), "P2-DIMMA10": device("P2-DIMMA10", "16 GB")},
+			[]*Dimmdata{
+				edac("mc10", "dimm1", "CPU_SrcID#1_Ha#0_Chan#0_DIMM#9", "channel 0 slot 9"),
+				edac("mc2", "dimm0", "CPU_SrcID#1_Ha#0_Chan#0_DIMM#0", "channel 0 slot 0"),
+				edac("mc0", "dimm3", "CPU_SrcID#0_Ha#0_Chan#1_DIMM#0", "channel 1 slot 0"),
+				edac("mc0", "dimm0", "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0", "channel 0 slot 0")},
+			map[string]string{"P1-DIMMA1": "mc0/dimm0", "P1-DIMMA2": "", "P1-DIMMB1": "mc0/dimm3", "P2-DIMMA1": "mc2/dimm0", "P2-DIMMA10": "mc10/dimm1"}},
+		{"counts differ, no guessing",
+			map[string]*dmidecode.Memorydevicedata{"DIMM_A1": device("DIMM_A1", "8192 MB"), "DIMM_A2": device("DIMM_A2", "8192 MB")},
+			[]*Dimmdata{edac("mc0", "dimm0", "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0", "channel 0 slot 0")},
+			map[string]string{"DIMM_A1": "", "DIMM_A2": "", "mc0/dimm0": "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0"}},
+		{"one label set, the rest by order",
+			map[string]*dmidecode.Memorydevicedata{"DIMM_A1": device("DIMM_A1", "8192 MB"), "DIMM_A2": device("DIMM_A2", "8192 MB"), "DIMM_B1": device("DIMM_B1", "8192 MB")},
+			[]*Dimmdata{edac("mc0", "dimm0", "DIMM_B1", "channel 1 slot 0"), edac("mc0", "dimm2", "CPU_SrcID#0_Ha#0_Chan#0_DIMM#1", "channel 0 slot 1"), edac("mc0", "dimm1", "CPU_SrcID#0_Ha#0_Chan#0_DIMM#0", "channel 0 slot 0")},
+			map[string]string{"DIMM_A1": "mc0/dimm1", "DIMM_A2": "mc0/dimm2", "DIMM_B1": "mc0/dimm0"}},
+		{"no edac",
+			map[string]*dmidecode.Memorydevicedata{"DIMM_A1": device("DIMM_A1", "8192 MB")},
+			nil,
+			map[string]string{"DIMM_A1": ""}},
+	}
+	for _, tt := range tests {
+		smap := Join(tt.devices, tt.edac)
+		if len(smap) != len(tt.want) {
+			t.Errorf("%s: got %d rows, want %d", tt.name, len(smap), len(tt.want))
+		}
+		for kk, want := range tt.want {
+			dimm := smap[kk]
+			switch {
+			case dimm == nil:
+				t.Errorf("%s: no row %s", tt.name, kk)
+			case tt.devices[kk] == nil:
+				if (dimm.Dmi_ != nil) || (dimm.Edaclabel_ != want) {
+					t.Errorf("%s: %s: got %+v", tt.name, kk, *dimm)
+				}
+			case dimm.Dmi_ != tt.devices[kk]:
+				t.Errorf("%s: %s: wrong SMBIOS device %+v", tt.name, kk, dimm.Dmi_)
+			case (len(want) == 0) != (len(dimm.Edacmc_) == 0):
+				t.Errorf("%s: %s: got %s/%s, want %q", tt.name, kk, dimm.Edacmc_, dimm.Edacdimm_, want)
+			case (len(want) > 0) && (dimm.Edacmc_+"/"+dimm.Edacdimm_ != want):
+				t.Errorf("%s: %s: got %s/%s, want %s", tt.name, kk, dimm.Edacmc_, dimm.Edacdimm_, want)
+			}
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		aa, bb string
+		want   bool
+	}{
+		{"DIMM_A2", "DIMM_A10", true},
+		{"DIMM_A10", "DIMM_B1", true},
+		{"DIMM_B1", "DIMM_A2", false},
+		{"mc2", "mc10", true},
+		{"channel 1 slot 0", "channel 0 slot 1", false},
+		{"DIMM_A1", "DIMM_A1", false},
+		{"DIMM_A", "DIMM_A1", true},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.aa, tt.bb); got != tt.want {
+			t.Errorf("naturalLess(%s, %s) = %v, want %v", tt.aa, tt.bb, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package mounts extracts what is actually mounted on linux, from /proc/self/mountinfo
+//
+// Csv output is particularly supported, so that a csvfile-based enterprise's ETL tools can also monitor its servers and desktops
+package mounts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mountdata holds one line of mountinfo, e.g.
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+type Mountdata struct {
+	Id_          int
+	Parentid_    int    // the mount this one sits on, itself for the root of the namespace
+	Majmin_      string // e.g. 8:1, shared by bind mounts and subvolumes of one filesystem
+	Root_        string // the directory of the filesystem seen at the mountpoint, / unless a bind mount or subvolume
+	Mountpoint_  string // octal escapes decoded
+	Mntopts_     string // per mount options, e.g. rw|noatime, commas turned to pipes for csv
+	Propagation_ string // optional fields, e.g. shared:1|master:2, empty for private mounts
+	Fstype_      string
+	Source_      string // e.g. /dev/sda1, or none
+	Superopts_   string // per filesystem options, commas turned to pipes for csv
+}
+
+const (
+	names     = "Id,Parentid,Majmin,Root,Mountpoint,Mntopts,Propagation,Fstype,Source,Superopts"
+	hdrprefix = ",mnt."
+	semi      = ";"
+)
+
+var (
+	headerString  string
+	commaString   string
+	pctString     string
+	namePctString string
+	procMountinfo = "/proc/self/mountinfo"
+)
+
+// init  is generic
+func init() {
+	headerString = (hdrprefix + strings.Join(strings.Split(names, ","), hdrprefix))[1:]
+	commaString = strings.Repeat(",", strings.Count(headerString, ","))
+	pctString = strings.Repeat(",%s", 1+strings.Count(headerString, ","))[1:]
+	namePctString = strings.Replace(names, ",", "=%s ", -1) + "=%s\n"
+}
+
+// SortedKeys_String2PtrMountdata is generic
+func SortedKeys_String2PtrMountdata(_mp *map[string]*Mountdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys_String2PtrMountdata is generic
+func Keys_String2PtrMountdata(_mp *map[string]*Mountdata) []string {
+	keys := make([]string, len(*_mp))
+	ii := 0
+	for kk := range *_mp {
+		keys[ii] = kk
+		ii++
+	}
+	return keys
+}
+
+// Header is generic
+func Header() string { return headerString }
+
+// Csv is generic
+func (self *Mountdata) Csv() string {
+	if self == nil {
+		return commaString
+	}
+	return fmt.Sprintf(pctString, strconv.Itoa(self.Id_), strconv.Itoa(self.Parentid_), self.Majmin_, strings.Replace(self.Root_, ",", semi, -1), strings.Replace(self.Mountpoint_, ",", semi, -1), self.Mntopts_, self.Propagation_, self.Fstype_, strings.Replace(self.Source_, ",", semi, -1), self.Superopts_)
+}
+
+// Sprint is generic
+func (self *Mountdata) Sprint() string {
+	if self == nil {
+		return ""
+	}
+	return fmt.Sprintf(namePctString, strconv.Itoa(self.Id_), strconv.Itoa(self.Parentid_), self.Majmin_, self.Root_, self.Mountpoint_, self.Mntopts_, self.Propagation_, self.Fstype_, self.Source_, self.Superopts_)
+}
+
+// Print is generic
+func (self *Mountdata) Print() {
+	if self == nil {
+		return
+	}
+	fmt.Printf(self.Sprint())
+}
+
+// New is generic
+func New() *Mountdata { return new(Mountdata) }
+
+// Unescape decodes the octal escapes (\040 etc) the kernel uses in mountinfo paths
+func Unescape(_str string) string {
+	if !strings.Contains(_str, `\`) {
+		return _str
+	}
+	out := []byte{}
+	for ii := 0; ii < len(_str); ii++ {
+		if (_str[ii] == '\\') && (ii+3 < len(_str)) {
+			if vv, err := strconv.ParseUint(_str[ii+1:ii+4], 8, 8); err == nil {
+				out = append(out, byte(vv))
+				ii += 3
+				continue
+			}
+		}
+		out = append(out, _str[ii])
+	}
+	return string(out)
+}
+
+// Options splits the per mount and per filesystem options back into a list, e.g. [rw noatime errors=continue]
+func (self *Mountdata) Options() []string {
+	opts := []string{}
+	for _, mntops := range []string{self.Mntopts_, self.Superopts_} {
+		for _, opt := range strings.Split(mntops, "|") {
+			if len(opt) > 0 {
+				opts = append(opts, opt)
+			}
+		}
+	}
+	return opts
+}
+
+// HasOption tells whether an option, e.g. ro or subvol=/home, is set on the mount or its filesystem
+func (self *Mountdata) HasOption(_opt string) bool {
+	for _, opt := range self.Options() {
+		if opt == _opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Peergroup returns the peer group of a propagation tag, e.g. Peergroup("shared") is 1 for shared:1, 0 if absent
+func (self *Mountdata) Peergroup(_tag string) int {
+	for _, item := range strings.Split(self.Propagation_, "|") {
+		if strings.HasPrefix(item, _tag+":") {
+			nn, _ := strconv.Atoi(strings.TrimPrefix(item, _tag+":"))
+			return nn
+		}
+	}
+	return 0
+}
+
+// ParseMountinfo parses mountinfo text in kernel order, which is the order the mounts were made in
+func ParseMountinfo(_text string, _verbose bool) []*Mountdata {
+	mds := []*Mountdata{}
+	for ii, line := range strings.Split(_text, "\n") {
+		items := strings.Fields(line)
+		sep := -1
+		for jj, item := range items {
+			if item == "-" {
+				sep = jj
+				break
+			}
+		}
+		if (sep < 6) || (len(items) < sep+3) {
+			if _verbose && (len(items) > 0) {
+				fmt.Printf("line%d: lenitems=%d %s\n", ii, len(items), strings.Join(items, "#"))
+			}
+			continue
+		}
+		md := new(Mountdata)
+		md.Id_, _ = strconv.Atoi(items[0])
+		md.Parentid_, _ = strconv.Atoi(items[1])
+		md.Majmin_ = items[2]
+		md.Root_ = Unescape(items[3])
+		md.Mountpoint_ = Unescape(items[4])
+		md.Mntopts_ = strings.Replace(items[5], ",", "|", -1)
+		md.Propagation_ = strings.Join(items[6:sep], "|")
+		md.Fstype_ = items[sep+1]
+		md.Source_ = Unescape(items[sep+2])
+		if len(items) > sep+3 {
+			md.Superopts_ = strings.Replace(items[sep+3], ",", "|", -1)
+		}
+		mds = append(mds, md)
+		if _verbose {
+			fmt.Printf("line%d: %s", ii, md.Sprint())
+		}
+	}
+	return mds
+}
+
+// ReadMountinfo reads and parses a mountinfo file, e.g. /proc/1/mountinfo for the host namespace
+func ReadMountinfo(_path string, _verbose bool) ([]*Mountdata, error) {
+	buf, err := ioutil.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMountinfo(string(buf), _verbose), nil
+}
+
+// Mounts extracts the mounts of this process, keyed by mountpoint; where mounts are stacked the visible (latest) one is kept
+func Mounts(_verbose bool) (smap map[string]*Mountdata) {
+	smap = make(map[string]*Mountdata)
+	mds, err := ReadMountinfo(procMountinfo, _verbose)
+	if err != nil {
+		if _verbose {
+			fmt.Printf("Mounts: %s\n", err)
+		}
+		return smap
+	}
+	for _, md := range mds {
+		smap[md.Mountpoint_] = md
+	}
+	return smap
+}
+
+// Mounttree indexes mounts by id so the mount tree can be walked
+type Mounttree struct {
+	Mounts_   []*Mountdata // kernel order
+	byId      map[int]*Mountdata
+	childrens map[int][]*Mountdata
+}
+
+// NewTree builds the tree of a list of mounts
+func NewTree(_mds []*Mountdata) *Mounttree {
+	tree := &Mounttree{Mounts_: _mds, byId: map[int]*Mountdata{}, childrens: map[int][]*Mountdata{}}
+	for _, md := range _mds {
+		tree.byId[md.Id_] = md
+	}
+	for _, md := range _mds {
+		if md.Parentid_ != md.Id_ {
+			tree.childrens[md.Parentid_] = append(tree.childrens[md.Parentid_], md)
+		}
+	}
+	return tree
+}
+
+// Tree reads this process's mount tree
+func Tree(_verbose bool) (*Mounttree, error) {
+	mds, err := ReadMountinfo(procMountinfo, _verbose)
+	if err != nil {
+		return nil, err
+	}
+	return NewTree(mds), nil
+}
+
+// Get returns the mount with an id, nil if none
+func (self *Mounttree) Get(_id int) *Mountdata { return self.byId[_id] }
+
+// Parent returns the mount a mount sits on, nil for a root
+func (self *Mounttree) Parent(_md *Mountdata) *Mountdata {
+	if _md.Parentid_ == _md.Id_ {
+		return nil
+	}
+	return self.byId[_md.Parentid_]
+}
+
+// Children lists the mounts made directly on a mount, in kernel order
+func (self *Mounttree) Children(_md *Mountdata) []*Mountdata { return self.childrens[_md.Id_] }
+
+// Roots lists the mounts whose parent is not in the table, normally just /; in a chroot or container the parent is often outside
+func (self *Mounttree) Roots() []*Mountdata {
+	roots := []*Mountdata{}
+	for _, md := range self.Mounts_ {
+		if self.Parent(md) == nil {
+			roots = append(roots, md)
+		}
+	}
+	return roots
+}
+
+// Ancestors lists the mounts below a mount, from its parent down to its root
+func (self *Mounttree) Ancestors(_md *Mountdata) []*Mountdata {
+	ancestors := []*Mountdata{}
+	seen := map[int]bool{_md.Id_: true}
+	for parent := self.Parent(_md); (parent != nil) && !seen[parent.Id_]; parent = self.Parent(parent) {
+		seen[parent.Id_] = true
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// Walk visits a mount and everything mounted under it depth first, stopping early if fn returns false
+func (self *Mounttree) Walk(_md *Mountdata, _fn func(md *Mountdata, depth int) bool) {
+	self.walk(_md, 0, map[int]bool{}, _fn)
+}
+
+// walk is Walk, guarding against id loops in a corrupt table
+func (self *Mounttree) walk(_md *Mountdata, _depth int, _seen map[int]bool, _fn func(md *Mountdata, depth int) bool) bool {
+	if _seen[_md.Id_] {
+		return true
+	}
+	_seen[_md.Id_] = true
+	if !_fn(_md, _depth) {
+		return false
+	}
+	for _, child := range self.Children(_md) {
+		if !self.walk(child, _depth+1, _seen, _fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Submounts lists everything mounted under a mount, depth first, not including itself
+func (self *Mounttree) Submounts(_md *Mountdata) []*Mountdata {
+	subs := []*Mountdata{}
+	self.Walk(_md, func(md *Mountdata, depth int) bool {
+		if depth > 0 {
+			subs = append(subs, md)
+		}
+		return true
+	})
+	return subs
+}
+
+// Find returns the mount a path lives on: the visible mount with the longest mountpoint containing the path
+func (self *Mounttree) Find(_path string) *Mountdata {
+	path := filepath.Clean(_path)
+	var best *Mountdata
+	for _, md := range self.Mounts_ { // later mounts hide earlier ones on the same mountpoint
+		mp := md.Mountpoint_
+		if (path != mp) && (mp != "/") && !strings.HasPrefix(path, mp+"/") {
+			continue
+		}
+		if (best == nil) || (len(mp) >= len(best.Mountpoint_)) {
+			best = md
+		}
+	}
+	return best
+}
+
+// Peers lists the other mounts in the same shared peer group, i.e. the ones mount and umount events propagate to
+func (self *Mounttree) Peers(_md *Mountdata) []*Mountdata {
+	peers := []*Mountdata{}
+	group := _md.Peergroup("shared")
+	if group == 0 {
+		return peers
+	}
+	for _, md := range self.Mounts_ {
+		if (md != _md) && (md.Peergroup("shared") == group) {
+			peers = append(peers, md)
+		}
+	}
+	return peers
+}
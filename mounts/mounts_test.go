@@ -0,0 +1,201 @@
+package mounts
+
+import (
+	"strings"
+	"testing"
+)
+
+// treeMountinfo is the mount table of a small box:
+//
+//	/ (1)
+//	├── /proc (2)
+//	├── /home (3), with /home/my disk (7) on it
+//	├── /foo (4)
+//	├── /foobar (5)
+//	├── /mnt (6), hidden by a second /mnt (8), with /mnt/sub (9) on that
+//	└── /srv/a (10) and /srv/b (11), bind mounts of one filesystem in peer group 2
+const treeMountinfo = `1 0 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+2 1 0:4 / /proc rw,nosuid - proc proc rw
+3 1 8:2 / /home rw,relatime shared:3 - xfs /dev/sda2 rw,attr2
+4 1 8:3 / /foo rw shared:4 - ext4 /dev/sda3 rw
+5 1 8:4 / /foobar rw shared:5 - ext4 /dev/sda4 rw
+6 1 8:5 / /mnt rw shared:6 - ext4 /dev/sda5 rw
+7 3 0:50 / /home/my\040disk rw - fuse.sshfs me@host:/my\040disk rw
+8 1 8:6 / /mnt rw shared:7 - ext4 /dev/sda6 rw
+9 8 8:7 / /mnt/sub rw - ext4 /dev/sda7 rw
+10 1 8:8 /data /srv/a rw shared:2 - ext4 /dev/sda8 rw
+11 1 8:8 /data /srv/b rw shared:2 master:1 - ext4 /dev/sda8 rw
+`
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/mnt/plain", "/mnt/plain"},
+		{`/mnt/my\040disk`, "/mnt/my disk"},
+		{`\040`, " "},
+		{`a\011b\012c\134d`, "a\tb\nc\\d"},
+		{`/mnt/trailing\`, `/mnt/trailing\`},
+		{`/mnt/short\04`, `/mnt/short\04`},
+		{`/mnt/bad\999`, `/mnt/bad\999`},
+		{`/mnt/big\400`, `/mnt/big\400`},
+		{`/mnt/hex\x20`, `/mnt/hex\x20`},
+		{`\\040`, `\ `}, // only the second backslash starts an escape
+	}
+	for _, tt := range tests {
+		if got := Unescape(tt.in); got != tt.want {
+			t.Errorf("Unescape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMountinfo(t *testing.T) {
+	text := `36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+37 35 98:0 / /no\040optional rw - ext4 /dev/sda1 rw
+38 35 0:30 / /two\040optional ro shared:5 master:2 - nfs4 srv:/export\040dir rw,vers=4.2
+39 35 0:31 / /no-superopts rw - tmpfs tmpfs
+40 35 0:32 / /dash-source rw - fuse.x -
+41 35 0:33 / /short rw -
+42 35 0:34 / /no-separator rw ext4 /dev/sdb1 rw
+
+garbage
+`
+	tests := []Mountdata{
+		{Id_: 36, Parentid_: 35, Majmin_: "98:0", Root_: "/mnt1", Mountpoint_: "/mnt2", Mntopts_: "rw|noatime", Propagation_: "master:1", Fstype_: "ext3", Source_: "/dev/root", Superopts_: "rw|errors=continue"},
+		{Id_: 37, Parentid_: 35, Majmin_: "98:0", Root_: "/", Mountpoint_: "/no optional", Mntopts_: "rw", Fstype_: "ext4", Source_: "/dev/sda1", Superopts_: "rw"},
+		{Id_: 38, Parentid_: 35, Majmin_: "0:30", Root_: "/", Mountpoint_: "/two optional", Mntopts_: "ro", Propagation_: "shared:5|master:2", Fstype_: "nfs4", Source_: "srv:/export dir", Superopts_: "rw|vers=4.2"},
+		{Id_: 39, Parentid_: 35, Majmin_: "0:31", Root_: "/", Mountpoint_: "/no-superopts", Mntopts_: "rw", Fstype_: "tmpfs", Source_: "tmpfs"},
+		{Id_: 40, Parentid_: 35, Majmin_: "0:32", Root_: "/", Mountpoint_: "/dash-source", Mntopts_: "rw", Fstype_: "fuse.x", Source_: "-"},
+	}
+	mds := ParseMountinfo(text, false)
+	if len(mds) != len(tests) {
+		t.Fatalf("got %d mounts, want %d", len(mds), len(tests))
+	}
+	for ii, tt := range tests {
+		if *mds[ii] != tt {
+			t.Errorf("line%d: got %+v, want %+v", ii+1, *mds[ii], tt)
+		}
+	}
+	if got := mds[2].Peergroup("master"); got != 2 {
+		t.Errorf("Peergroup(master) = %d, want 2", got)
+	}
+	if !mds[2].HasOption("vers=4.2") || !mds[2].HasOption("ro") || mds[2].HasOption("vers") {
+		t.Errorf("HasOption: options %v", mds[2].Options())
+	}
+	if got := mds[0].Csv(); strings.Count(got, ",") != strings.Count(Header(), ",") {
+		t.Errorf("Csv %q does not match the header", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	tree := NewTree(ParseMountinfo(treeMountinfo, false))
+	tests := []struct {
+		path string
+		id   int
+	}{
+		{"/", 1},
+		{"/etc/fstab", 1},
+		{"/foo", 4},
+		{"/foo/x", 4},
+		{"/foobar", 5},
+		{"/foobar/x", 5},
+		{"/foob", 1},
+		{"/mnt", 8}, // the later of the stacked mounts is the visible one
+		{"/mnt/file", 8},
+		{"/mnt/sub/x", 9},
+		{"/home/my disk/a", 7},
+		{"/home/my", 3},
+		{"/srv/a/", 10},
+		{"/proc/../home", 3},
+	}
+	for _, tt := range tests {
+		md := tree.Find(tt.path)
+		if (md == nil) || (md.Id_ != tt.id) {
+			t.Errorf("Find(%s) = %+v, want id %d", tt.path, md, tt.id)
+		}
+	}
+	if md := NewTree(nil).Find("/"); md != nil {
+		t.Errorf("Find on an empty tree = %+v", md)
+	}
+}
+
+// ids lists the ids of mounts, for comparing
+func ids(_mds []*Mountdata) []int {
+	out := []int{}
+	for _, md := range _mds {
+		out = append(out, md.Id_)
+	}
+	return out
+}
+
+func TestTree(t *testing.T) {
+	tree := NewTree(ParseMountinfo(treeMountinfo, false))
+	same := func(_got []int, _want ...int) bool {
+		if len(_got) != len(_want) {
+			return false
+		}
+		for ii := range _got {
+			if _got[ii] != _want[ii] {
+				return false
+			}
+		}
+		return true
+	}
+	if got := ids(tree.Roots()); !same(got, 1) {
+		t.Errorf("Roots = %v", got)
+	}
+	if got := ids(tree.Children(tree.Get(1))); !same(got, 2, 3, 4, 5, 6, 8, 10, 11) {
+		t.Errorf("Children(/) = %v", got)
+	}
+	if got := ids(tree.Ancestors(tree.Get(7))); !same(got, 3, 1) {
+		t.Errorf("Ancestors(/home/my disk) = %v", got)
+	}
+	if got := ids(tree.Ancestors(tree.Get(1))); !same(got) {
+		t.Errorf("Ancestors(/) = %v", got)
+	}
+	if got := ids(tree.Submounts(tree.Get(1))); !same(got, 2, 3, 7, 4, 5, 6, 8, 9, 10, 11) {
+		t.Errorf("Submounts(/) = %v", got)
+	}
+	if got := ids(tree.Submounts(tree.Get(8))); !same(got, 9) {
+		t.Errorf("Submounts(/mnt) = %v", got)
+	}
+	depths := []int{}
+	tree.Walk(tree.Get(3), func(md *Mountdata, depth int) bool {
+		depths = append(depths, depth)
+		return true
+	})
+	if !same(depths, 0, 1) {
+		t.Errorf("Walk(/home) depths = %v", depths)
+	}
+	visited := []int{}
+	tree.Walk(tree.Get(1), func(md *Mountdata, depth int) bool {
+		visited = append(visited, md.Id_)
+		return md.Id_ != 3
+	})
+	if !same(visited, 1, 2, 3) {
+		t.Errorf("Walk stopping at /home visited %v", visited)
+	}
+	if got := ids(tree.Peers(tree.Get(10))); !same(got, 11) {
+		t.Errorf("Peers(/srv/a) = %v", got)
+	}
+	if got := ids(tree.Peers(tree.Get(2))); !same(got) {
+		t.Errorf("Peers(/proc) = %v", got)
+	}
+	if got := ids(tree.Peers(tree.Get(4))); !same(got) {
+		t.Errorf("Peers(/foo) = %v", got)
+	}
+}
+
+func TestTreeLoop(t *testing.T) {
+	// a corrupt table where 2 and 3 sit on each other must not hang
+	tree := NewTree(ParseMountinfo("2 3 0:1 / /a rw - tmpfs t rw\n3 2 0:2 / /a/b rw - tmpfs t rw\n", false))
+	if got := ids(tree.Ancestors(tree.Get(2))); (len(got) != 1) || (got[0] != 3) {
+		t.Errorf("Ancestors = %v", got)
+	}
+	if got := ids(tree.Submounts(tree.Get(2))); (len(got) != 1) || (got[0] != 3) {
+		t.Errorf("Submounts = %v", got)
+	}
+	if got := tree.Roots(); len(got) != 0 {
+		t.Errorf("Roots = %v", ids(got))
+	}
+}
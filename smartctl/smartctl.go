@@ -158,19 +158,20 @@ func SmartctlOne(_df *df.Dfdata, _scsi *scsi.Scsidata, _parted *parted.Parteddat
 		if _verbose {
 			fmt.Println("SmartctlOne: found default\n")
 		}
-		if (_dmidecode.Manufacturer_ == "HP") && (_scsi.Devicetype_ == "storage") && (_scsi.Vendor_ == "HP") { // Storage controller such as P410
+		boxVendor, diskVendor := dmidecode.NormalizeVendor(_dmidecode.Manufacturer_), dmidecode.NormalizeVendor(_scsi.Vendor_)
+		if (boxVendor == "HP") && (_scsi.Devicetype_ == "storage") && (diskVendor == "HP") { // Storage controller such as P410
 			if _verbose {
 				fmt.Println("SmartctlOne: found HP controller \n")
 			}
 			return SmartctlOneHP(_df, _scsi, _parted, _dmidecode, _verbose)
 		}
-		if (_dmidecode.Manufacturer_ == "HP") && (_scsi.Devicetype_ == "disk") && (_scsi.Vendor_ == "HP") && (len(_scsi.Generic_) > 0) && (_parted != nil) /* && (_parted.Type_ == "rawdevice")*/ { // Logical device on a storage controller such as P410
+		if (boxVendor == "HP") && (_scsi.Devicetype_ == "disk") && (diskVendor == "HP") && (len(_scsi.Generic_) > 0) && (_parted != nil) /* && (_parted.Type_ == "rawdevice")*/ { // Logical device on a storage controller such as P410
 			if _verbose {
 				fmt.Println("SmartctlOne: found HP logical disk\n")
 			}
 			return SmartctlOneHPDisk(_df, _scsi, _parted, _dmidecode, _verbose)
 		}
-		if (_dmidecode.Manufacturer_ == "Supermicro") && (_scsi.Devicetype_ == "disk") && (_parted != nil) /* && (!genutil.StrSin(_parted.Type_, "partition|softraid"))*/ {
+		if (boxVendor == "Supermicro") && (_scsi.Devicetype_ == "disk") && (_parted != nil) /* && (!genutil.StrSin(_parted.Type_, "partition|softraid"))*/ {
 			if _verbose {
 				fmt.Println("SmartctlOne: found Supermicro \n")
 			}